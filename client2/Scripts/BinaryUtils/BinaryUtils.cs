@@ -2,12 +2,14 @@ namespace BinaryUtils;
 
 using System;
 using System.Buffers;
+using System.Collections.Generic;
 using C = Command;
 using D = Direction;
 using Data;
 using System.IO;
 using System.Runtime.CompilerServices;
 using System.Runtime.InteropServices;
+using System.Text;
 
 public static class BinaryUtils
 {
@@ -335,4 +337,1219 @@ public static class BinaryUtils
 
 	#endregion
 
+	#region InterestEvent
+
+	[MethodImpl(MethodImplOptions.AggressiveInlining)]
+	public static InterestEvent DeserializeInterestEvent(in byte[] byteArray)
+	{
+		if (byteArray.Length < 2) // (1 + 1) bytes
+		{
+			throw new ArgumentException("Byte array is too short to deserialize InterestEvent.");
+		}
+
+		ReadOnlySpan<byte> dataSpan = byteArray;
+
+		return new InterestEvent()
+		{
+			CommandID = (C.Command)dataSpan[0],
+			UserID = dataSpan[1],
+		};
+	}
+
+	#endregion
+
+	#region Rooms
+
+	[MethodImpl(MethodImplOptions.AggressiveInlining)]
+	public static byte[] SerializeRoomCreate(in RoomCreate roomCreate)
+	{
+		byte[] name = Encoding.UTF8.GetBytes(roomCreate.Name ?? string.Empty);
+		if (name.Length > 255)
+		{
+			throw new ArgumentException("Room name is too long.");
+		}
+
+		byte[] result = new byte[4 + name.Length]; // 1 + 1 + 1 + 1 + name
+
+		result[0] = (byte)roomCreate.CommandID;
+		result[1] = roomCreate.UserID;
+		result[2] = roomCreate.Capacity;
+		result[3] = (byte)name.Length;
+		name.CopyTo(result, 4);
+
+		return result;
+	}
+
+	[MethodImpl(MethodImplOptions.AggressiveInlining)]
+	public static byte[] SerializeRoomJoin(in RoomJoin roomJoin)
+	{
+		return [(byte)roomJoin.CommandID, roomJoin.UserID, roomJoin.RoomID];
+	}
+
+	[MethodImpl(MethodImplOptions.AggressiveInlining)]
+	public static byte[] SerializeRoomRequest(in RoomRequest roomRequest)
+	{
+		return [(byte)roomRequest.CommandID, roomRequest.UserID];
+	}
+
+	public static RoomList DeserializeRoomList(in byte[] byteArray)
+	{
+		if (byteArray.Length < 2) // (1 + 1) bytes
+		{
+			throw new ArgumentException("Byte array is too short to deserialize RoomList.");
+		}
+
+		ReadOnlySpan<byte> dataSpan = byteArray;
+		var rooms = new RoomEntry[dataSpan[1]];
+		int offset = 2;
+
+		for (int i = 0; i < rooms.Length; i++)
+		{
+			if (offset + 4 > dataSpan.Length) // (1 + 1 + 1 + 1) bytes before the name
+			{
+				throw new ArgumentException("Byte array is too short to deserialize RoomEntry.");
+			}
+
+			int nameLength = dataSpan[offset + 3];
+			if (offset + 4 + nameLength > dataSpan.Length)
+			{
+				throw new ArgumentException("Byte array is too short to deserialize room name.");
+			}
+
+			rooms[i] = new RoomEntry()
+			{
+				RoomID = dataSpan[offset],
+				PlayerCount = dataSpan[offset + 1],
+				Capacity = dataSpan[offset + 2],
+				Name = Encoding.UTF8.GetString(dataSpan.Slice(offset + 4, nameLength))
+			};
+			offset += 4 + nameLength;
+		}
+
+		return new RoomList()
+		{
+			CommandID = (C.Command)dataSpan[0],
+			Rooms = rooms
+		};
+	}
+
+	[MethodImpl(MethodImplOptions.AggressiveInlining)]
+	public static RoomAssignment DeserializeRoomAssignment(in byte[] byteArray)
+	{
+		if (byteArray.Length < 4) // (1 + 1 + 1 + 1) bytes
+		{
+			throw new ArgumentException("Byte array is too short to deserialize RoomAssignment.");
+		}
+
+		ReadOnlySpan<byte> dataSpan = byteArray;
+
+		return new RoomAssignment()
+		{
+			CommandID = (C.Command)dataSpan[0],
+			UserID = dataSpan[1],
+			RoomID = dataSpan[2],
+			Status = (RoomStatus)dataSpan[3]
+		};
+	}
+
+	#endregion
+
+	#region Matchmaking
+
+	[MethodImpl(MethodImplOptions.AggressiveInlining)]
+	public static byte[] SerializeMatchRequest(in MatchRequest matchRequest)
+	{
+		byte[] result = new byte[7]; // Total size: 1 + 1 + 2 + 2 + 1 = 7 bytes
+
+		result[0] = (byte)matchRequest.CommandID;
+		result[1] = matchRequest.UserID;
+		BitConverter.TryWriteBytes(new Span<byte>(result, 2, 2), matchRequest.RTTMillis);
+		BitConverter.TryWriteBytes(new Span<byte>(result, 4, 2), matchRequest.Skill);
+		result[6] = matchRequest.HasSkill ? (byte)1 : (byte)0;
+
+		return result;
+	}
+
+	[MethodImpl(MethodImplOptions.AggressiveInlining)]
+	public static MatchAssignment DeserializeMatchAssignment(in byte[] byteArray)
+	{
+		if (byteArray.Length < 4) // (1 + 1 + 1 + 1) bytes
+		{
+			throw new ArgumentException("Byte array is too short to deserialize MatchAssignment.");
+		}
+
+		ReadOnlySpan<byte> dataSpan = byteArray;
+
+		return new MatchAssignment()
+		{
+			CommandID = (C.Command)dataSpan[0],
+			UserID = dataSpan[1],
+			RoomID = dataSpan[2],
+			PlayerCount = dataSpan[3]
+		};
+	}
+
+	#endregion
+
+	#region Snapshot
+
+	public static SnapshotData DeserializeSnapshotData(in byte[] byteArray)
+	{
+		if (byteArray.Length < 11) // (1 + 4 + 4 + 2) bytes
+		{
+			throw new ArgumentException("Byte array is too short to deserialize SnapshotData.");
+		}
+
+		ReadOnlySpan<byte> dataSpan = byteArray;
+		var entries = new SnapshotEntry[BitConverter.ToUInt16(dataSpan.Slice(9, 2))];
+		int offset = 11;
+
+		for (int i = 0; i < entries.Length; i++)
+		{
+			if (offset + 3 > dataSpan.Length) // (2 + 1) bytes before the fields
+			{
+				throw new ArgumentException("Byte array is too short to deserialize SnapshotEntry.");
+			}
+
+			var entry = new SnapshotEntry()
+			{
+				EntityID = BitConverter.ToUInt16(dataSpan.Slice(offset, 2)),
+				Mask = (SnapshotMask)dataSpan[offset + 2]
+			};
+			offset += 3;
+
+			if ((entry.Mask & SnapshotMask.Removed) == 0)
+			{
+				// Fields follow in Kind, X, Y, Z, RotY order when flagged in the mask
+				if ((entry.Mask & SnapshotMask.Kind) != 0) { entry.Kind = (EntityKind)dataSpan[offset]; offset += 1; }
+				if ((entry.Mask & SnapshotMask.X) != 0) { entry.X = BitConverter.ToSingle(dataSpan.Slice(offset, 4)); offset += 4; }
+				if ((entry.Mask & SnapshotMask.Y) != 0) { entry.Y = BitConverter.ToSingle(dataSpan.Slice(offset, 4)); offset += 4; }
+				if ((entry.Mask & SnapshotMask.Z) != 0) { entry.Z = BitConverter.ToSingle(dataSpan.Slice(offset, 4)); offset += 4; }
+				if ((entry.Mask & SnapshotMask.RotY) != 0) { entry.RotY = BitConverter.ToSingle(dataSpan.Slice(offset, 4)); offset += 4; }
+			}
+
+			entries[i] = entry;
+		}
+
+		return new SnapshotData()
+		{
+			CommandID = (C.Command)dataSpan[0],
+			Sequence = BitConverter.ToUInt32(dataSpan.Slice(1, 4)),
+			Baseline = BitConverter.ToUInt32(dataSpan.Slice(5, 4)),
+			Entries = entries
+		};
+	}
+
+	[MethodImpl(MethodImplOptions.AggressiveInlining)]
+	public static byte[] SerializeSnapshotAck(in SnapshotAck ack)
+	{
+		byte[] result = new byte[6]; // Total size: 1 + 1 + 4 = 6 bytes
+
+		result[0] = (byte)ack.CommandID;
+		result[1] = ack.UserID;
+		BitConverter.TryWriteBytes(new Span<byte>(result, 2, 4), ack.Sequence);
+
+		return result;
+	}
+
+	#endregion
+
+	#region CompactPosition
+
+	/// <summary>
+	/// Decodes a POSITION_COMPACT broadcast. The quantizer must match the server's CompactPositions.
+	/// </summary>
+	public static PositionData DeserializeCompactPosition(in byte[] byteArray, Quantizer quantizer)
+	{
+		if (byteArray.Length < quantizer.MessageSize) // (1 + 1) bytes plus the packed payload
+		{
+			throw new ArgumentException("Byte array is too short to deserialize CompactPosition.");
+		}
+
+		ReadOnlySpan<byte> dataSpan = byteArray;
+		quantizer.Decode(dataSpan.Slice(2), out float x, out float y, out float z, out float rotY);
+
+		return new PositionData()
+		{
+			CommandID = (C.Command)dataSpan[0],
+			UserID = dataSpan[1],
+			X = x,
+			Y = y,
+			Z = z,
+			RotY = rotY
+		};
+	}
+
+	#endregion
+
+	#region Batch
+
+	// BATCH command byte plus the message count
+	private const int _batchHeaderSize = 2;
+
+	/// <summary>
+	/// Frames messages into as few datagrams of at most mtu bytes as possible.
+	/// A datagram that would carry a single message is sent unframed.
+	/// </summary>
+	public static List<byte[]> PackBatch(IReadOnlyList<byte[]> messages, int mtu)
+	{
+		var datagrams = new List<byte[]>();
+		var current = new List<byte[]>();
+		int size = _batchHeaderSize;
+
+		void Flush()
+		{
+			if (current.Count == 1)
+			{
+				datagrams.Add(current[0]);
+			}
+			else if (current.Count > 1)
+			{
+				byte[] datagram = new byte[size];
+				datagram[0] = (byte)C.Command.BATCH;
+				datagram[1] = (byte)current.Count;
+
+				int offset = _batchHeaderSize;
+				foreach (var message in current)
+				{
+					BitConverter.TryWriteBytes(new Span<byte>(datagram, offset, 2), (ushort)message.Length);
+					message.CopyTo(datagram, offset + 2);
+					offset += 2 + message.Length;
+				}
+				datagrams.Add(datagram);
+			}
+
+			current.Clear();
+			size = _batchHeaderSize;
+		}
+
+		foreach (var message in messages)
+		{
+			if (message.Length > mtu)
+			{
+				throw new ArgumentException($"Message of {message.Length} bytes exceeds MTU of {mtu}.");
+			}
+
+			int framed = 2 + message.Length;
+			if (current.Count > 0 && (size + framed > mtu || current.Count == 255))
+			{
+				Flush();
+			}
+			current.Add(message);
+			size += framed;
+		}
+		Flush();
+
+		return datagrams;
+	}
+
+	/// <summary>
+	/// Splits a datagram into its messages. Datagrams that are not a BATCH are returned as a single message.
+	/// </summary>
+	public static List<byte[]> UnpackBatch(in byte[] byteArray)
+	{
+		if (GetCommand(byteArray) != C.Command.BATCH)
+		{
+			return new List<byte[]> { byteArray };
+		}
+
+		if (byteArray.Length < _batchHeaderSize)
+		{
+			throw new ArgumentException("Byte array is too short to deserialize Batch.");
+		}
+
+		ReadOnlySpan<byte> dataSpan = byteArray;
+		int count = dataSpan[1];
+		var messages = new List<byte[]>(count);
+		int offset = _batchHeaderSize;
+
+		for (int i = 0; i < count; i++)
+		{
+			if (offset + 2 > dataSpan.Length)
+			{
+				throw new ArgumentException("Byte array is too short to deserialize Batch length.");
+			}
+
+			int length = BitConverter.ToUInt16(dataSpan.Slice(offset, 2));
+			offset += 2;
+
+			if (offset + length > dataSpan.Length)
+			{
+				throw new ArgumentException("Byte array is too short to deserialize Batch message.");
+			}
+
+			messages.Add(dataSpan.Slice(offset, length).ToArray());
+			offset += length;
+		}
+
+		return messages;
+	}
+
+	#endregion
+
+	#region Fragment
+
+	// Command, message ID, index and count: (1 + 2 + 1 + 1) bytes
+	public const int FragmentHeaderSize = 5;
+
+	[MethodImpl(MethodImplOptions.AggressiveInlining)]
+	public static Fragment DeserializeFragment(in byte[] byteArray)
+	{
+		if (byteArray.Length < FragmentHeaderSize)
+		{
+			throw new ArgumentException("Byte array is too short to deserialize Fragment.");
+		}
+
+		ReadOnlySpan<byte> dataSpan = byteArray;
+		var fragment = new Fragment()
+		{
+			CommandID = (C.Command)dataSpan[0],
+			MessageID = BitConverter.ToUInt16(dataSpan.Slice(1, 2)),
+			Index = dataSpan[3],
+			Count = dataSpan[4],
+			Payload = dataSpan.Slice(FragmentHeaderSize).ToArray()
+		};
+
+		if (fragment.Count == 0 || fragment.Index >= fragment.Count)
+		{
+			throw new ArgumentException($"Invalid fragment {fragment.Index} of {fragment.Count}.");
+		}
+
+		return fragment;
+	}
+
+	#endregion
+
+	#region ConnectChallenge
+
+	public const int CookieSize = 16;
+	public const int SecretSize = 16;
+
+	[MethodImpl(MethodImplOptions.AggressiveInlining)]
+	public static ConnectChallenge DeserializeConnectChallenge(in byte[] byteArray)
+	{
+		if (byteArray.Length < 1 + CookieSize) // (1 + 16) bytes
+		{
+			throw new ArgumentException("Byte array is too short to deserialize ConnectChallenge.");
+		}
+
+		ReadOnlySpan<byte> dataSpan = byteArray;
+
+		return new ConnectChallenge()
+		{
+			CommandID = (C.Command)dataSpan[0],
+			Cookie = dataSpan.Slice(1, CookieSize).ToArray()
+		};
+	}
+
+	/// <summary>
+	/// Builds a PORT_REQUEST echoing the cookie from the last challenge (all zeros to ask for one),
+	/// followed by the player's profile. An empty name registers under a default name.
+	/// The request is at least as large as the challenge so the server never has to answer with more bytes.
+	/// </summary>
+	public static byte[] SerializePortRequest(in byte[] cookie, string name = "", uint color = 0, byte skin = 0, byte[] secret = null)
+	{
+		byte[] nameBytes = Encoding.UTF8.GetBytes(name ?? string.Empty);
+		if (nameBytes.Length > 255)
+		{
+			throw new ArgumentException("Player name is too long.");
+		}
+
+		int secretSize = secret != null ? SecretSize : 0;
+		byte[] result = new byte[1 + CookieSize + 6 + nameBytes.Length + secretSize]; // (1 + 16 + 4 + 1 + 1) bytes plus the name and secret
+
+		result[0] = (byte)C.Command.PORT_REQUEST;
+		cookie?.AsSpan(0, Math.Min(cookie.Length, CookieSize)).CopyTo(result.AsSpan(1));
+		BitConverter.TryWriteBytes(new Span<byte>(result, 1 + CookieSize, 4), color);
+		result[1 + CookieSize + 4] = skin;
+		result[1 + CookieSize + 5] = (byte)nameBytes.Length;
+		nameBytes.CopyTo(result, 1 + CookieSize + 6);
+		secret?.AsSpan(0, Math.Min(secret.Length, SecretSize)).CopyTo(result.AsSpan(1 + CookieSize + 6 + nameBytes.Length));
+
+		return result;
+	}
+
+	#endregion
+
+	#region KeyExchange
+
+	// A request is padded to the size of the reply, (1 + 32 + 64) bytes
+	public const int KeyExchangeSize = 1 + X25519.KeySize + Ed25519.SignatureSize;
+
+	/// <summary>
+	/// Builds a KEY_EXCHANGE request echoing the cookie from the last challenge (all zeros to ask for one).
+	/// Replacing an established session requires its rekey proof.
+	/// </summary>
+	public static byte[] SerializeKeyExchange(in byte[] publicKey, in byte[] cookie, byte[] proof = null)
+	{
+		byte[] result = new byte[KeyExchangeSize]; // (1 + 32 + 16 + 1 + 32) bytes, then padding
+
+		result[0] = (byte)C.Command.KEY_EXCHANGE;
+		publicKey.CopyTo(result, 1);
+		cookie?.AsSpan(0, Math.Min(cookie.Length, CookieSize)).CopyTo(result.AsSpan(1 + X25519.KeySize));
+		if (proof != null)
+		{
+			result[1 + X25519.KeySize + CookieSize] = 1;
+			proof.AsSpan(0, 32).CopyTo(result.AsSpan(1 + X25519.KeySize + CookieSize + 1));
+		}
+
+		return result;
+	}
+
+	public static KeyExchange DeserializeKeyExchange(in byte[] byteArray)
+	{
+		if (byteArray.Length < 1 + X25519.KeySize + Ed25519.SignatureSize) // (1 + 32 + 64) bytes
+		{
+			throw new ArgumentException("Byte array is too short to deserialize KeyExchange.");
+		}
+
+		ReadOnlySpan<byte> dataSpan = byteArray;
+
+		return new KeyExchange()
+		{
+			CommandID = (C.Command)dataSpan[0],
+			PublicKey = dataSpan.Slice(1, X25519.KeySize).ToArray(),
+			Signature = dataSpan.Slice(1 + X25519.KeySize, Ed25519.SignatureSize).ToArray()
+		};
+	}
+
+	#endregion
+
+	#region Input
+
+	// Sequence, MoveX, MoveZ, RotY and Delta
+	private const int _inputFrameSize = 20;
+
+	public static byte[] SerializeInputData(in InputData inputData)
+	{
+		if (inputData.Frames.Length > byte.MaxValue)
+		{
+			throw new ArgumentException($"Too many input frames: {inputData.Frames.Length}");
+		}
+
+		byte[] result = new byte[3 + inputData.Frames.Length * _inputFrameSize]; // (1 + 1 + 1) bytes plus the frames
+
+		result[0] = (byte)inputData.CommandID;
+		result[1] = inputData.UserID;
+		result[2] = (byte)inputData.Frames.Length;
+
+		Span<byte> span = result;
+		int offset = 3;
+		foreach (var frame in inputData.Frames)
+		{
+			BitConverter.TryWriteBytes(span.Slice(offset, 4), frame.Sequence);
+			BitConverter.TryWriteBytes(span.Slice(offset + 4, 4), frame.MoveX);
+			BitConverter.TryWriteBytes(span.Slice(offset + 8, 4), frame.MoveZ);
+			BitConverter.TryWriteBytes(span.Slice(offset + 12, 4), frame.RotY);
+			BitConverter.TryWriteBytes(span.Slice(offset + 16, 4), frame.Delta);
+			offset += _inputFrameSize;
+		}
+
+		return result;
+	}
+
+	[MethodImpl(MethodImplOptions.AggressiveInlining)]
+	public static AuthState DeserializeAuthState(in byte[] byteArray)
+	{
+		if (byteArray.Length < 22) // (1 + 1 + 4 + 4 + 4 + 4 + 4) bytes
+		{
+			throw new ArgumentException("Byte array is too short to deserialize AuthState.");
+		}
+
+		ReadOnlySpan<byte> dataSpan = byteArray;
+
+		return new AuthState()
+		{
+			CommandID = (C.Command)dataSpan[0],
+			UserID = dataSpan[1],
+			LastSequence = BitConverter.ToUInt32(dataSpan.Slice(2, 4)),
+			X = BitConverter.ToSingle(dataSpan.Slice(6, 4)),
+			Y = BitConverter.ToSingle(dataSpan.Slice(10, 4)),
+			Z = BitConverter.ToSingle(dataSpan.Slice(14, 4)),
+			RotY = BitConverter.ToSingle(dataSpan.Slice(18, 4))
+		};
+	}
+
+	#endregion
+
+	#region Entity
+
+	[MethodImpl(MethodImplOptions.AggressiveInlining)]
+	public static EntitySpawn DeserializeEntitySpawn(in byte[] byteArray)
+	{
+		if (byteArray.Length < 33) // (1 + 2 + 1 + 1 + 4 * 7) bytes
+		{
+			throw new ArgumentException("Byte array is too short to deserialize EntitySpawn.");
+		}
+
+		ReadOnlySpan<byte> dataSpan = byteArray;
+
+		return new EntitySpawn()
+		{
+			CommandID = (C.Command)dataSpan[0],
+			EntityID = BitConverter.ToUInt16(dataSpan.Slice(1, 2)),
+			Kind = (EntityKind)dataSpan[3],
+			OwnerID = dataSpan[4],
+			X = BitConverter.ToSingle(dataSpan.Slice(5, 4)),
+			Y = BitConverter.ToSingle(dataSpan.Slice(9, 4)),
+			Z = BitConverter.ToSingle(dataSpan.Slice(13, 4)),
+			RotY = BitConverter.ToSingle(dataSpan.Slice(17, 4)),
+			VX = BitConverter.ToSingle(dataSpan.Slice(21, 4)),
+			VY = BitConverter.ToSingle(dataSpan.Slice(25, 4)),
+			VZ = BitConverter.ToSingle(dataSpan.Slice(29, 4))
+		};
+	}
+
+	[MethodImpl(MethodImplOptions.AggressiveInlining)]
+	public static EntityDespawn DeserializeEntityDespawn(in byte[] byteArray)
+	{
+		if (byteArray.Length < 3) // (1 + 2) bytes
+		{
+			throw new ArgumentException("Byte array is too short to deserialize EntityDespawn.");
+		}
+
+		ReadOnlySpan<byte> dataSpan = byteArray;
+
+		return new EntityDespawn()
+		{
+			CommandID = (C.Command)dataSpan[0],
+			EntityID = BitConverter.ToUInt16(dataSpan.Slice(1, 2))
+		};
+	}
+
+	public static EntityTransforms DeserializeEntityTransforms(in byte[] byteArray)
+	{
+		if (byteArray.Length < 3) // (1 + 2) bytes
+		{
+			throw new ArgumentException("Byte array is too short to deserialize EntityTransforms.");
+		}
+
+		ReadOnlySpan<byte> dataSpan = byteArray;
+		var entities = new EntityTransform[BitConverter.ToUInt16(dataSpan.Slice(1, 2))];
+		if (byteArray.Length < 3 + entities.Length * 30) // (2 + 4 * 7) bytes per entity
+		{
+			throw new ArgumentException("Byte array is too short to deserialize EntityTransform.");
+		}
+
+		for (int i = 0, offset = 3; i < entities.Length; i++, offset += 30)
+		{
+			entities[i] = new EntityTransform()
+			{
+				EntityID = BitConverter.ToUInt16(dataSpan.Slice(offset, 2)),
+				X = BitConverter.ToSingle(dataSpan.Slice(offset + 2, 4)),
+				Y = BitConverter.ToSingle(dataSpan.Slice(offset + 6, 4)),
+				Z = BitConverter.ToSingle(dataSpan.Slice(offset + 10, 4)),
+				RotY = BitConverter.ToSingle(dataSpan.Slice(offset + 14, 4)),
+				VX = BitConverter.ToSingle(dataSpan.Slice(offset + 18, 4)),
+				VY = BitConverter.ToSingle(dataSpan.Slice(offset + 22, 4)),
+				VZ = BitConverter.ToSingle(dataSpan.Slice(offset + 26, 4))
+			};
+		}
+
+		return new EntityTransforms()
+		{
+			CommandID = (C.Command)dataSpan[0],
+			Entities = entities
+		};
+	}
+
+	#endregion
+
+	#region Properties
+
+	public static PropertyUpdate DeserializePropertyUpdate(in byte[] byteArray)
+	{
+		if (byteArray.Length < 3) // (1 + 2) bytes
+		{
+			throw new ArgumentException("Byte array is too short to deserialize PropertyUpdate.");
+		}
+
+		ReadOnlySpan<byte> dataSpan = byteArray;
+		var entities = new EntityProperties[BitConverter.ToUInt16(dataSpan.Slice(1, 2))];
+		int offset = 3;
+
+		for (int i = 0; i < entities.Length; i++)
+		{
+			if (offset + 3 > dataSpan.Length) // (2 + 1) bytes before the properties
+			{
+				throw new ArgumentException("Byte array is too short to deserialize EntityProperties.");
+			}
+
+			var values = new PropertyValue[dataSpan[offset + 2]];
+			entities[i] = new EntityProperties()
+			{
+				EntityID = BitConverter.ToUInt16(dataSpan.Slice(offset, 2)),
+				Values = values
+			};
+			offset += 3;
+
+			for (int j = 0; j < values.Length; j++)
+			{
+				values[j] = ReadPropertyValue(dataSpan, ref offset);
+			}
+		}
+
+		return new PropertyUpdate()
+		{
+			CommandID = (C.Command)dataSpan[0],
+			Entities = entities
+		};
+	}
+
+	/// <summary>
+	/// Reads a property's ID, type and value, advancing offset past them
+	/// </summary>
+	private static PropertyValue ReadPropertyValue(ReadOnlySpan<byte> dataSpan, ref int offset)
+	{
+		if (offset + 2 > dataSpan.Length) // (1 + 1) bytes before the value
+		{
+			throw new ArgumentException("Byte array is too short to deserialize PropertyValue.");
+		}
+
+		var property = new PropertyValue()
+		{
+			ID = dataSpan[offset],
+			Type = (PropertyType)dataSpan[offset + 1]
+		};
+		offset += 2;
+
+		switch (property.Type)
+		{
+			case PropertyType.Bool:
+				property.Value = dataSpan[offset] != 0;
+				offset += 1;
+				break;
+			case PropertyType.UInt8:
+				property.Value = dataSpan[offset];
+				offset += 1;
+				break;
+			case PropertyType.Int32:
+				property.Value = BitConverter.ToInt32(dataSpan.Slice(offset, 4));
+				offset += 4;
+				break;
+			case PropertyType.Float32:
+				property.Value = BitConverter.ToSingle(dataSpan.Slice(offset, 4));
+				offset += 4;
+				break;
+			case PropertyType.Vec3:
+				property.Value = new System.Numerics.Vector3(
+					BitConverter.ToSingle(dataSpan.Slice(offset, 4)),
+					BitConverter.ToSingle(dataSpan.Slice(offset + 4, 4)),
+					BitConverter.ToSingle(dataSpan.Slice(offset + 8, 4)));
+				offset += 12;
+				break;
+			case PropertyType.String:
+				int length = dataSpan[offset];
+				property.Value = Encoding.UTF8.GetString(dataSpan.Slice(offset + 1, length));
+				offset += 1 + length;
+				break;
+			default:
+				throw new ArgumentException($"Unknown property type {property.Type}.");
+		}
+
+		return property;
+	}
+
+	#endregion
+
+	#region Reliable
+
+	[MethodImpl(MethodImplOptions.AggressiveInlining)]
+	public static byte[] SerializeReliable(in Reliable reliable)
+	{
+		byte[] result = new byte[3 + reliable.Payload.Length]; // (1 + 2) bytes plus the payload
+
+		result[0] = (byte)reliable.CommandID;
+		BitConverter.TryWriteBytes(new Span<byte>(result, 1, 2), reliable.Sequence);
+		reliable.Payload.CopyTo(result, 3);
+
+		return result;
+	}
+
+	[MethodImpl(MethodImplOptions.AggressiveInlining)]
+	public static Reliable DeserializeReliable(in byte[] byteArray)
+	{
+		if (byteArray.Length < 4) // (1 + 2) bytes and at least a command byte of payload
+		{
+			throw new ArgumentException("Byte array is too short to deserialize Reliable.");
+		}
+
+		ReadOnlySpan<byte> dataSpan = byteArray;
+
+		return new Reliable()
+		{
+			CommandID = (C.Command)dataSpan[0],
+			Sequence = BitConverter.ToUInt16(dataSpan.Slice(1, 2)),
+			Payload = dataSpan.Slice(3).ToArray()
+		};
+	}
+
+	[MethodImpl(MethodImplOptions.AggressiveInlining)]
+	public static byte[] SerializeReliableAck(in ReliableAck ack)
+	{
+		byte[] result = new byte[3]; // Total size: 1 + 2 = 3 bytes
+
+		result[0] = (byte)ack.CommandID;
+		BitConverter.TryWriteBytes(new Span<byte>(result, 1, 2), ack.Sequence);
+
+		return result;
+	}
+
+	[MethodImpl(MethodImplOptions.AggressiveInlining)]
+	public static ReliableAck DeserializeReliableAck(in byte[] byteArray)
+	{
+		if (byteArray.Length < 3) // (1 + 2) bytes
+		{
+			throw new ArgumentException("Byte array is too short to deserialize ReliableAck.");
+		}
+
+		ReadOnlySpan<byte> dataSpan = byteArray;
+
+		return new ReliableAck()
+		{
+			CommandID = (C.Command)dataSpan[0],
+			Sequence = BitConverter.ToUInt16(dataSpan.Slice(1, 2))
+		};
+	}
+
+	#endregion
+
+	#region Chat
+
+	public static byte[] SerializeChatMessage(in ChatMessage chat)
+	{
+		byte[] text = Encoding.UTF8.GetBytes(chat.Text ?? string.Empty);
+		if (text.Length > 255)
+		{
+			throw new ArgumentException("Chat message is too long.");
+		}
+
+		byte[] result = new byte[9 + text.Length]; // (1 + 1 + 1 + 1 + 4 + 1) bytes plus the text
+
+		result[0] = (byte)chat.CommandID;
+		result[1] = chat.UserID;
+		result[2] = (byte)chat.Channel;
+		result[3] = chat.Target;
+		BitConverter.TryWriteBytes(new Span<byte>(result, 4, 4), chat.Timestamp);
+		result[8] = (byte)text.Length;
+		text.CopyTo(result, 9);
+
+		return result;
+	}
+
+	public static ChatMessage DeserializeChatMessage(in byte[] byteArray)
+	{
+		if (byteArray.Length < 9) // (1 + 1 + 1 + 1 + 4 + 1) bytes
+		{
+			throw new ArgumentException("Byte array is too short to deserialize ChatMessage.");
+		}
+
+		ReadOnlySpan<byte> dataSpan = byteArray;
+		int textLength = dataSpan[8];
+		if (9 + textLength > dataSpan.Length)
+		{
+			throw new ArgumentException("Byte array is too short to deserialize chat text.");
+		}
+
+		return new ChatMessage()
+		{
+			CommandID = (C.Command)dataSpan[0],
+			UserID = dataSpan[1],
+			Channel = (ChatChannel)dataSpan[2],
+			Target = dataSpan[3],
+			Timestamp = BitConverter.ToUInt32(dataSpan.Slice(4, 4)),
+			Text = Encoding.UTF8.GetString(dataSpan.Slice(9, textLength))
+		};
+	}
+
+	#endregion
+
+	#region Profile
+
+	public static PlayerInfo DeserializePlayerInfo(in byte[] byteArray)
+	{
+		if (byteArray.Length < 8) // (1 + 1 + 4 + 1 + 1) bytes
+		{
+			throw new ArgumentException("Byte array is too short to deserialize PlayerInfo.");
+		}
+
+		ReadOnlySpan<byte> dataSpan = byteArray;
+		int nameLength = dataSpan[7];
+		if (8 + nameLength > dataSpan.Length)
+		{
+			throw new ArgumentException("Byte array is too short to deserialize player name.");
+		}
+
+		return new PlayerInfo()
+		{
+			CommandID = (C.Command)dataSpan[0],
+			UserID = dataSpan[1],
+			Color = BitConverter.ToUInt32(dataSpan.Slice(2, 4)),
+			Skin = dataSpan[6],
+			Name = Encoding.UTF8.GetString(dataSpan.Slice(8, nameLength))
+		};
+	}
+
+	[MethodImpl(MethodImplOptions.AggressiveInlining)]
+	public static ProfileRejected DeserializeProfileRejected(in byte[] byteArray)
+	{
+		if (byteArray.Length < 2) // (1 + 1) bytes
+		{
+			throw new ArgumentException("Byte array is too short to deserialize ProfileRejected.");
+		}
+
+		return new ProfileRejected()
+		{
+			CommandID = (C.Command)byteArray[0],
+			Status = (ProfileStatus)byteArray[1]
+		};
+	}
+
+	#endregion
+
+	#region Join
+
+	// UserID, X, Y, Z and RotY
+	private const int _playerStateSize = 17;
+
+	// EntitySpawn without its command byte
+	private const int _joinEntitySize = 32;
+
+	public static JoinSnapshot DeserializeJoinSnapshot(in byte[] byteArray)
+	{
+		if (byteArray.Length < 3) // (1 + 1 + 1) bytes before the players
+		{
+			throw new ArgumentException("Byte array is too short to deserialize JoinSnapshot.");
+		}
+
+		ReadOnlySpan<byte> dataSpan = byteArray;
+		var players = new PlayerState[dataSpan[2]];
+		int offset = 3;
+
+		if (offset + players.Length * _playerStateSize + 2 > dataSpan.Length)
+		{
+			throw new ArgumentException("Byte array is too short to deserialize JoinSnapshot players.");
+		}
+
+		for (int i = 0; i < players.Length; i++)
+		{
+			players[i] = new PlayerState()
+			{
+				UserID = dataSpan[offset],
+				X = BitConverter.ToSingle(dataSpan.Slice(offset + 1, 4)),
+				Y = BitConverter.ToSingle(dataSpan.Slice(offset + 5, 4)),
+				Z = BitConverter.ToSingle(dataSpan.Slice(offset + 9, 4)),
+				RotY = BitConverter.ToSingle(dataSpan.Slice(offset + 13, 4))
+			};
+			offset += _playerStateSize;
+		}
+
+		var entities = new EntitySpawn[BitConverter.ToUInt16(dataSpan.Slice(offset, 2))];
+		offset += 2;
+
+		if (offset + entities.Length * _joinEntitySize > dataSpan.Length)
+		{
+			throw new ArgumentException("Byte array is too short to deserialize JoinSnapshot entities.");
+		}
+
+		for (int i = 0; i < entities.Length; i++)
+		{
+			entities[i] = new EntitySpawn()
+			{
+				CommandID = C.Command.ENTITY_SPAWN,
+				EntityID = BitConverter.ToUInt16(dataSpan.Slice(offset, 2)),
+				Kind = (EntityKind)dataSpan[offset + 2],
+				OwnerID = dataSpan[offset + 3],
+				X = BitConverter.ToSingle(dataSpan.Slice(offset + 4, 4)),
+				Y = BitConverter.ToSingle(dataSpan.Slice(offset + 8, 4)),
+				Z = BitConverter.ToSingle(dataSpan.Slice(offset + 12, 4)),
+				RotY = BitConverter.ToSingle(dataSpan.Slice(offset + 16, 4)),
+				VX = BitConverter.ToSingle(dataSpan.Slice(offset + 20, 4)),
+				VY = BitConverter.ToSingle(dataSpan.Slice(offset + 24, 4)),
+				VZ = BitConverter.ToSingle(dataSpan.Slice(offset + 28, 4))
+			};
+			offset += _joinEntitySize;
+		}
+
+		return new JoinSnapshot()
+		{
+			CommandID = (C.Command)dataSpan[0],
+			RoomID = dataSpan[1],
+			Players = players,
+			Entities = entities
+		};
+	}
+
+	[MethodImpl(MethodImplOptions.AggressiveInlining)]
+	public static PlayerEvent DeserializePlayerEvent(in byte[] byteArray)
+	{
+		if (byteArray.Length < 2) // (1 + 1) bytes
+		{
+			throw new ArgumentException("Byte array is too short to deserialize PlayerEvent.");
+		}
+
+		return new PlayerEvent()
+		{
+			CommandID = (C.Command)byteArray[0],
+			UserID = byteArray[1]
+		};
+	}
+
+	#endregion
+
+}
+
+/// <summary>
+/// Rebuilds messages the server split into FRAGMENT datagrams
+/// </summary>
+public class FragmentReassembler
+{
+	private class PartialMessage
+	{
+		public byte[][] Parts;
+		public int Received;
+		public DateTime Started;
+	}
+
+	private readonly TimeSpan _timeout;
+	private readonly Dictionary<ushort, PartialMessage> _messages = new Dictionary<ushort, PartialMessage>();
+	private readonly object _lock = new object();
+
+	public FragmentReassembler(TimeSpan timeout)
+	{
+		_timeout = timeout;
+	}
+
+	/// <summary>
+	/// Stores a fragment and returns true with the full message once every fragment has arrived
+	/// </summary>
+	public bool Add(in byte[] byteArray, out byte[] message)
+	{
+		message = null;
+		var fragment = BinaryUtils.DeserializeFragment(byteArray);
+		var now = DateTime.UtcNow;
+		PartialMessage current;
+
+		lock (_lock)
+		{
+			// Drop messages that never completed
+			var expired = new List<ushort>();
+			foreach (var (id, partial) in _messages)
+			{
+				if (now - partial.Started > _timeout) expired.Add(id);
+			}
+			foreach (var id in expired) _messages.Remove(id);
+
+			if (!_messages.TryGetValue(fragment.MessageID, out current) || current.Parts.Length != fragment.Count)
+			{
+				current = new PartialMessage { Parts = new byte[fragment.Count][], Started = now };
+				_messages[fragment.MessageID] = current;
+			}
+
+			if (current.Parts[fragment.Index] != null) return false; // Duplicate
+
+			current.Parts[fragment.Index] = fragment.Payload;
+			current.Received++;
+			if (current.Received < current.Parts.Length) return false;
+
+			_messages.Remove(fragment.MessageID);
+		}
+
+		int size = 0;
+		foreach (var part in current.Parts) size += part.Length;
+
+		message = new byte[size];
+		int offset = 0;
+		foreach (var part in current.Parts)
+		{
+			part.CopyTo(message, offset);
+			offset += part.Length;
+		}
+		return true;
+	}
+}
+
+/// <summary>
+/// Reads the fixed-point, bit-packed positions of POSITION_COMPACT messages
+/// </summary>
+public class Quantizer
+{
+	private readonly float[] _min;
+	private readonly float _precision;
+	private readonly int[] _axisBits = new int[3];
+	private readonly int _rotBits;
+
+	/// <summary>
+	/// Same bounds and precision as the server's DefaultCompactPositions
+	/// </summary>
+	public static Quantizer Default => new Quantizer(new float[] { -500, -50, -500 }, new float[] { 500, 200, 500 }, 0.01f, 10);
+
+	public Quantizer(float[] min, float[] max, float precision, int rotBits)
+	{
+		if (precision <= 0) throw new ArgumentException("Precision must be positive.");
+		if (rotBits <= 0 || rotBits > 32) throw new ArgumentException($"Invalid rotation bits: {rotBits}");
+
+		_min = min;
+		_precision = precision;
+		_rotBits = rotBits;
+
+		for (int axis = 0; axis < 3; axis++)
+		{
+			float span = max[axis] - min[axis];
+			if (span <= 0) throw new ArgumentException($"Invalid bounds on axis {axis}.");
+
+			// Bits needed for the number of steps, as bits.Len64 on the server
+			ulong steps = (ulong)Math.Ceiling(span / precision);
+			_axisBits[axis] = 64 - System.Numerics.BitOperations.LeadingZeroCount(steps);
+		}
+	}
+
+	/// <summary>
+	/// Size in bytes of a POSITION_COMPACT message
+	/// </summary>
+	public int MessageSize => 2 + (_axisBits[0] + _axisBits[1] + _axisBits[2] + _rotBits + 7) / 8;
+
+	/// <summary>
+	/// Reads X, Y, Z and RotY packed least significant bit first
+	/// </summary>
+	public void Decode(ReadOnlySpan<byte> data, out float x, out float y, out float z, out float rotY)
+	{
+		int bitPos = 0;
+		var coords = new float[3];
+		for (int axis = 0; axis < 3; axis++)
+		{
+			ulong raw = ReadBits(data, ref bitPos, _axisBits[axis]);
+			coords[axis] = _min[axis] + raw * _precision;
+		}
+
+		ulong rot = ReadBits(data, ref bitPos, _rotBits);
+		double turns = (double)rot / (1UL << _rotBits);
+
+		x = coords[0];
+		y = coords[1];
+		z = coords[2];
+		rotY = (float)(turns * 2 * Math.PI - Math.PI);
+	}
+
+	private static ulong ReadBits(ReadOnlySpan<byte> data, ref int bitPos, int count)
+	{
+		if (bitPos + count > data.Length * 8)
+		{
+			throw new ArgumentException("Byte array is too short for the packed position.");
+		}
+
+		ulong value = 0;
+		for (int i = 0; i < count; i++, bitPos++)
+		{
+			if ((data[bitPos / 8] & (1 << (bitPos % 8))) != 0)
+			{
+				value |= 1UL << i;
+			}
+		}
+		return value;
+	}
+}
+
+/// <summary>
+/// Ordered, exactly-once delivery of RELIABLE messages between the client and the server.
+/// Outgoing messages are resent until acknowledged; incoming ones are de-duplicated and released in order.
+/// </summary>
+public class ReliableChannel
+{
+	private class PendingMessage
+	{
+		public byte[] Payload;
+		public DateTime SentAt;
+	}
+
+	// How far ahead of the next expected sequence a message may arrive and still be buffered
+	private const int _window = 256;
+
+	private readonly int _maxPending;
+	private readonly Dictionary<ushort, PendingMessage> _pending = new Dictionary<ushort, PendingMessage>();
+	private readonly Dictionary<ushort, byte[]> _received = new Dictionary<ushort, byte[]>();
+	private ushort _nextSend;
+	private ushort _nextReceive;
+	private readonly object _lock = new object();
+
+	public ReliableChannel(int maxPending)
+	{
+		_maxPending = maxPending;
+	}
+
+	/// <summary>
+	/// Numbers a payload and keeps it until acknowledged. Returns false when too many messages are unacknowledged.
+	/// </summary>
+	public bool TrySend(byte[] payload, out Reliable reliable)
+	{
+		lock (_lock)
+		{
+			reliable = default;
+			if (_pending.Count >= _maxPending) return false;
+
+			ushort sequence = _nextSend++;
+			_pending[sequence] = new PendingMessage { Payload = payload, SentAt = DateTime.UtcNow };
+			reliable = new Reliable { CommandID = C.Command.RELIABLE, Sequence = sequence, Payload = payload };
+			return true;
+		}
+	}
+
+	public void Ack(ushort sequence)
+	{
+		lock (_lock)
+		{
+			_pending.Remove(sequence);
+		}
+	}
+
+	/// <summary>
+	/// Returns the unacknowledged messages last sent at least interval ago, oldest first, and marks them as sent again
+	/// </summary>
+	public List<Reliable> Due(TimeSpan interval)
+	{
+		var now = DateTime.UtcNow;
+		var due = new List<Reliable>();
+
+		lock (_lock)
+		{
+			ushort oldest = _nextSend;
+			foreach (var sequence in _pending.Keys)
+			{
+				if ((short)(sequence - oldest) < 0) oldest = sequence;
+			}
+
+			for (ushort sequence = oldest; sequence != _nextSend; sequence++)
+			{
+				if (!_pending.TryGetValue(sequence, out var pending) || now - pending.SentAt < interval) continue;
+
+				pending.SentAt = now;
+				due.Add(new Reliable { CommandID = C.Command.RELIABLE, Sequence = sequence, Payload = pending.Payload });
+			}
+		}
+		return due;
+	}
+
+	/// <summary>
+	/// Accepts an incoming message and returns the payloads now deliverable in order. Duplicates deliver
+	/// nothing but are acknowledged again; messages too far ahead are not acknowledged so they are resent.
+	/// </summary>
+	public List<byte[]> Receive(in Reliable reliable, out bool ack)
+	{
+		var deliver = new List<byte[]>();
+
+		lock (_lock)
+		{
+			int ahead = (short)(reliable.Sequence - _nextReceive);
+			ack = ahead < _window;
+			if (ahead < 0 || ahead >= _window) return deliver;
+
+			_received.TryAdd(reliable.Sequence, reliable.Payload);
+			while (_received.Remove(_nextReceive, out var payload))
+			{
+				deliver.Add(payload);
+				_nextReceive++;
+			}
+		}
+		return deliver;
+	}
 }
@@ -0,0 +1,375 @@
+namespace BinaryUtils;
+
+using System;
+using System.Numerics;
+using System.Security.Cryptography;
+using System.Text;
+using C = Command;
+
+/// <summary>
+/// X25519 key agreement (RFC 7748). .NET has no Curve25519, so the Montgomery ladder is done with BigInteger;
+/// it runs once per connection.
+/// </summary>
+public static class X25519
+{
+	public const int KeySize = 32;
+
+	private static readonly BigInteger _p = BigInteger.Pow(2, 255) - 19;
+	private static readonly BigInteger _a24 = 121665;
+	private static readonly byte[] _basePoint = BasePoint();
+
+	/// <summary>
+	/// Creates a random private key and its public key
+	/// </summary>
+	public static (byte[] PrivateKey, byte[] PublicKey) GenerateKeyPair()
+	{
+		byte[] privateKey = RandomNumberGenerator.GetBytes(KeySize);
+		return (privateKey, ScalarMult(privateKey, _basePoint));
+	}
+
+	/// <summary>
+	/// Computes the shared secret, rejecting peer keys that force an all-zero result
+	/// </summary>
+	public static byte[] SharedSecret(byte[] privateKey, byte[] peerPublicKey)
+	{
+		byte[] shared = ScalarMult(privateKey, peerPublicKey);
+
+		int bits = 0;
+		foreach (byte b in shared) bits |= b;
+		if (bits == 0)
+		{
+			throw new CryptographicException("Peer public key is a low-order point.");
+		}
+		return shared;
+	}
+
+	private static byte[] ScalarMult(byte[] scalar, byte[] point)
+	{
+		byte[] k = (byte[])scalar.Clone();
+		k[0] &= 248;
+		k[31] &= 127;
+		k[31] |= 64;
+
+		byte[] u = (byte[])point.Clone();
+		u[31] &= 127;
+
+		BigInteger kn = new BigInteger(k, isUnsigned: true);
+		BigInteger x1 = new BigInteger(u, isUnsigned: true) % _p;
+		BigInteger x2 = 1, z2 = 0, x3 = x1, z3 = 1;
+		int swap = 0;
+
+		for (int t = 254; t >= 0; t--)
+		{
+			int bit = (int)((kn >> t) & 1);
+			swap ^= bit;
+			if (swap == 1)
+			{
+				(x2, x3) = (x3, x2);
+				(z2, z3) = (z3, z2);
+			}
+			swap = bit;
+
+			BigInteger a = Mod(x2 + z2), aa = Mod(a * a);
+			BigInteger b = Mod(x2 - z2), bb = Mod(b * b);
+			BigInteger e = Mod(aa - bb);
+			BigInteger c = Mod(x3 + z3), d = Mod(x3 - z3);
+			BigInteger da = Mod(d * a), cb = Mod(c * b);
+
+			x3 = Mod((da + cb) * (da + cb));
+			z3 = Mod(x1 * Mod((da - cb) * (da - cb)));
+			x2 = Mod(aa * bb);
+			z2 = Mod(e * (aa + _a24 * e));
+		}
+		if (swap == 1)
+		{
+			(x2, x3) = (x3, x2);
+			(z2, z3) = (z3, z2);
+		}
+
+		return Encode(Mod(x2 * BigInteger.ModPow(z2, _p - 2, _p)));
+	}
+
+	private static BigInteger Mod(BigInteger value)
+	{
+		BigInteger result = value % _p;
+		return result.Sign < 0 ? result + _p : result;
+	}
+
+	private static byte[] Encode(BigInteger value)
+	{
+		byte[] result = new byte[KeySize];
+		value.TryWriteBytes(result, out _, isUnsigned: true);
+		return result;
+	}
+
+	private static byte[] BasePoint()
+	{
+		byte[] point = new byte[KeySize];
+		point[0] = 9;
+		return point;
+	}
+}
+
+/// <summary>
+/// Ed25519 signature verification (RFC 8032), used to check the server signed its key share
+/// </summary>
+public static class Ed25519
+{
+	public const int PublicKeySize = 32;
+	public const int SignatureSize = 64;
+
+	private static readonly BigInteger _p = BigInteger.Pow(2, 255) - 19;
+	private static readonly BigInteger _q = BigInteger.Pow(2, 252) + BigInteger.Parse("27742317777372353535851937790883648493");
+	private static readonly BigInteger _d = Mod(-121665 * Inverse(121666));
+	private static readonly BigInteger _sqrtM1 = BigInteger.ModPow(2, (_p - 1) / 4, _p);
+	private static readonly BigInteger[] _g = BasePoint();
+
+	/// <summary>
+	/// Checks a signature of message by publicKey
+	/// </summary>
+	public static bool Verify(byte[] publicKey, byte[] message, byte[] signature)
+	{
+		if (publicKey.Length != PublicKeySize || signature.Length != SignatureSize) return false;
+
+		var a = Decompress(publicKey);
+		if (a == null) return false;
+		var r = Decompress(signature.AsSpan(0, 32).ToArray());
+		if (r == null) return false;
+
+		BigInteger s = new BigInteger(signature.AsSpan(32, 32), isUnsigned: true);
+		if (s >= _q) return false;
+
+		byte[] hash;
+		using (var sha = SHA512.Create())
+		{
+			byte[] input = new byte[64 + message.Length];
+			signature.AsSpan(0, 32).CopyTo(input);
+			publicKey.CopyTo(input, 32);
+			message.CopyTo(input, 64);
+			hash = sha.ComputeHash(input);
+		}
+		BigInteger h = new BigInteger(hash, isUnsigned: true) % _q;
+
+		return PointEqual(PointMul(s, _g), PointAdd(r, PointMul(h, a)));
+	}
+
+	// Points are in extended coordinates (X, Y, Z, T) with x = X/Z, y = Y/Z, x*y = T/Z
+	private static BigInteger[] PointAdd(BigInteger[] p, BigInteger[] q)
+	{
+		BigInteger a = Mod((p[1] - p[0]) * (q[1] - q[0]));
+		BigInteger b = Mod((p[1] + p[0]) * (q[1] + q[0]));
+		BigInteger c = Mod(2 * p[3] * q[3] * _d);
+		BigInteger d = Mod(2 * p[2] * q[2]);
+		BigInteger e = b - a, f = d - c, g = d + c, h = b + a;
+		return new[] { Mod(e * f), Mod(g * h), Mod(f * g), Mod(e * h) };
+	}
+
+	private static BigInteger[] PointMul(BigInteger scalar, BigInteger[] point)
+	{
+		var result = new BigInteger[] { 0, 1, 1, 0 }; // Neutral element
+		while (scalar > 0)
+		{
+			if (!scalar.IsEven) result = PointAdd(result, point);
+			point = PointAdd(point, point);
+			scalar >>= 1;
+		}
+		return result;
+	}
+
+	private static bool PointEqual(BigInteger[] p, BigInteger[] q)
+	{
+		return Mod(p[0] * q[2] - q[0] * p[2]) == 0 && Mod(p[1] * q[2] - q[1] * p[2]) == 0;
+	}
+
+	private static BigInteger[] Decompress(byte[] encoded)
+	{
+		byte[] bytes = (byte[])encoded.Clone();
+		int sign = bytes[31] >> 7;
+		bytes[31] &= 127;
+
+		BigInteger y = new BigInteger(bytes, isUnsigned: true);
+		BigInteger? x = RecoverX(y, sign);
+		if (x == null) return null;
+		return new[] { x.Value, y, 1, Mod(x.Value * y) };
+	}
+
+	private static BigInteger? RecoverX(BigInteger y, int sign)
+	{
+		if (y >= _p) return null;
+
+		BigInteger x2 = Mod((y * y - 1) * Inverse(_d * y * y + 1));
+		if (x2.IsZero)
+		{
+			return sign == 0 ? BigInteger.Zero : null;
+		}
+
+		BigInteger x = BigInteger.ModPow(x2, (_p + 3) / 8, _p);
+		if (!Mod(x * x - x2).IsZero) x = Mod(x * _sqrtM1);
+		if (!Mod(x * x - x2).IsZero) return null;
+
+		if ((int)(x & 1) != sign) x = _p - x;
+		return x;
+	}
+
+	private static BigInteger[] BasePoint()
+	{
+		BigInteger y = Mod(4 * Inverse(5));
+		BigInteger x = RecoverX(y, 0).Value;
+		return new[] { x, y, 1, Mod(x * y) };
+	}
+
+	private static BigInteger Inverse(BigInteger value)
+	{
+		return BigInteger.ModPow(Mod(value), _p - 2, _p);
+	}
+
+	private static BigInteger Mod(BigInteger value)
+	{
+		BigInteger result = value % _p;
+		return result.Sign < 0 ? result + _p : result;
+	}
+}
+
+/// <summary>
+/// The client end of the server's secure transport: keys derived from the key exchange, and SECURE
+/// envelopes sealed with ChaCha20-Poly1305 under a counter nonce, with the same replay window as the server
+/// </summary>
+public class SecureSession
+{
+	// SECURE command byte plus the 8-byte nonce counter
+	public const int HeaderSize = 9;
+	private const int _tagSize = 16;
+	private const int _replayWindowSize = 64;
+	private const string _keyExchangeContext = "masters-thesis key exchange";
+
+	private readonly ChaCha20Poly1305 _send; // client -> server
+	private readonly ChaCha20Poly1305 _recv; // server -> client
+	private readonly byte[] _rekey;
+	private ulong _sendCounter;
+	private ulong _highest;
+	private ulong _bitmap; // bit i set means _highest - i was received
+	private readonly object _lock = new object();
+
+	private SecureSession(byte[] shared, byte[] clientPublicKey, byte[] serverPublicKey)
+	{
+		byte[] prk = HMACSHA256.HashData(Encoding.ASCII.GetBytes("masters-thesis secure transport"), shared);
+		byte[] transcript = Concat(clientPublicKey, serverPublicKey);
+
+		_send = new ChaCha20Poly1305(HMACSHA256.HashData(prk, Concat(Encoding.ASCII.GetBytes("client->server"), transcript)));
+		_recv = new ChaCha20Poly1305(HMACSHA256.HashData(prk, Concat(Encoding.ASCII.GetBytes("server->client"), transcript)));
+		_rekey = HMACSHA256.HashData(prk, Concat(Encoding.ASCII.GetBytes("rekey"), transcript));
+	}
+
+	/// <summary>
+	/// Completes the key exchange once the server replied. Throws if the reply is not signed by the pinned identity key.
+	/// </summary>
+	public static SecureSession Establish(byte[] privateKey, byte[] clientPublicKey, byte[] serverPublicKey, byte[] signature, byte[] serverIdentity)
+	{
+		byte[] transcript = Concat(Encoding.ASCII.GetBytes(_keyExchangeContext), Concat(clientPublicKey, serverPublicKey));
+		if (!Ed25519.Verify(serverIdentity, transcript, signature))
+		{
+			throw new CryptographicException("Key exchange is not signed by the server's identity key.");
+		}
+
+		return new SecureSession(X25519.SharedSecret(privateKey, serverPublicKey), clientPublicKey, serverPublicKey);
+	}
+
+	/// <summary>
+	/// Proof sent with a new public key to replace this session on the server
+	/// </summary>
+	public byte[] RekeyProof(byte[] clientPublicKey)
+	{
+		return HMACSHA256.HashData(_rekey, clientPublicKey);
+	}
+
+	/// <summary>
+	/// Wraps a datagram into a SECURE envelope
+	/// </summary>
+	public byte[] Seal(byte[] plaintext)
+	{
+		ulong counter;
+		lock (_lock)
+		{
+			counter = ++_sendCounter;
+		}
+
+		byte[] envelope = new byte[HeaderSize + plaintext.Length + _tagSize];
+		envelope[0] = (byte)C.Command.SECURE;
+		BitConverter.TryWriteBytes(new Span<byte>(envelope, 1, 8), counter);
+
+		var span = envelope.AsSpan();
+		_send.Encrypt(Nonce(counter), plaintext, span.Slice(HeaderSize, plaintext.Length),
+			span.Slice(HeaderSize + plaintext.Length, _tagSize), span.Slice(0, HeaderSize));
+		return envelope;
+	}
+
+	/// <summary>
+	/// Authenticates and decrypts a SECURE envelope; returns null for forged, corrupted or replayed ones
+	/// </summary>
+	public byte[] Open(byte[] envelope)
+	{
+		if (envelope.Length < HeaderSize + _tagSize) return null;
+
+		var span = envelope.AsSpan();
+		ulong counter = BitConverter.ToUInt64(span.Slice(1, 8));
+		int length = envelope.Length - HeaderSize - _tagSize;
+		byte[] plaintext = new byte[length];
+
+		lock (_lock)
+		{
+			if (Seen(counter)) return null;
+
+			try
+			{
+				_recv.Decrypt(Nonce(counter), span.Slice(HeaderSize, length), span.Slice(HeaderSize + length, _tagSize),
+					plaintext, span.Slice(0, HeaderSize));
+			}
+			catch (CryptographicException)
+			{
+				return null;
+			}
+
+			Accept(counter);
+		}
+		return plaintext;
+	}
+
+	private bool Seen(ulong counter)
+	{
+		if (counter == 0) return true;
+		if (counter > _highest) return false;
+
+		ulong diff = _highest - counter;
+		if (diff >= _replayWindowSize) return true;
+		return (_bitmap & (1UL << (int)diff)) != 0;
+	}
+
+	private void Accept(ulong counter)
+	{
+		if (counter > _highest)
+		{
+			ulong shift = counter - _highest;
+			_bitmap = shift >= _replayWindowSize ? 0 : _bitmap << (int)shift;
+			_bitmap |= 1;
+			_highest = counter;
+			return;
+		}
+		_bitmap |= 1UL << (int)(_highest - counter);
+	}
+
+	// 12-byte nonce with the counter in the last 8 bytes, as on the server
+	private static byte[] Nonce(ulong counter)
+	{
+		byte[] nonce = new byte[12];
+		BitConverter.TryWriteBytes(new Span<byte>(nonce, 4, 8), counter);
+		return nonce;
+	}
+
+	private static byte[] Concat(byte[] a, byte[] b)
+	{
+		byte[] result = new byte[a.Length + b.Length];
+		a.CopyTo(result, 0);
+		b.CopyTo(result, a.Length);
+		return result;
+	}
+}
@@ -10,4 +10,39 @@ public enum Command : byte
 	USER_ASSIGNMENT = 5,
 	PORT_REQUEST = 6,     // Changed to match server (was 7)
 	PORT_ASSIGNMENT = 7,  // Changed to match server (was 6)
+	INTEREST_ENTER = 8,
+	INTEREST_LEAVE = 9,
+	ROOM_CREATE = 10,
+	ROOM_JOIN = 11,
+	ROOM_LEAVE = 12,
+	ROOM_LIST_REQUEST = 13,
+	ROOM_LIST = 14,
+	ROOM_ASSIGNMENT = 15,
+	MATCH_REQUEST = 16,
+	MATCH_CANCEL = 17,
+	MATCH_ASSIGNMENT = 18,
+	SNAPSHOT = 19,
+	SNAPSHOT_ACK = 20,
+	POSITION_COMPACT = 21,
+	BATCH = 22,
+	FRAGMENT = 23,
+	KEY_EXCHANGE = 24,
+	SECURE = 25,
+	CONNECT_CHALLENGE = 26,
+	INPUT = 27,
+	AUTH_STATE = 28,
+	ENTITY_SPAWN = 29,
+	ENTITY_DESPAWN = 30,
+	ENTITY_PROPERTIES = 31,
+	RELIABLE = 32,
+	RELIABLE_ACK = 33,
+	CHAT = 34,
+	PLAYER_INFO = 35,
+	PROFILE_REJECTED = 36,
+	JOIN_SNAPSHOT = 37,
+	PLAYER_JOINED = 38,
+	PLAYER_LEFT = 39,
+	PING = 40,
+	PONG = 41,
+	ENTITY_TRANSFORM = 42,
 }
@@ -1,5 +1,6 @@
 namespace Data;
 
+using System;
 using System.Runtime.InteropServices;
 using C = Command;
 using D = Direction;
@@ -102,3 +103,498 @@ public struct PortAssignment
 		return $"CommandID: {CommandID}, UserID: {UserID}, Port: {Port}";
 	}
 }
+
+[StructLayout(LayoutKind.Sequential, Pack = 1)]
+public struct InterestEvent
+{
+	public C.Command CommandID;
+	public byte UserID;
+
+	public override string ToString()
+	{
+		return $"CommandID: {CommandID}, UserID: {UserID}";
+	}
+}
+
+public enum RoomStatus : byte
+{
+	OK = 0,
+	NOT_FOUND = 1,
+	FULL = 2,
+	ERROR = 3,
+}
+
+public struct RoomCreate
+{
+	public C.Command CommandID;
+	public byte UserID;
+	public byte Capacity; // 0 means unlimited
+	public string Name;
+
+	public override string ToString()
+	{
+		return $"CommandID: {CommandID}, UserID: {UserID}, Capacity: {Capacity}, Name: {Name}";
+	}
+}
+
+[StructLayout(LayoutKind.Sequential, Pack = 1)]
+public struct RoomJoin
+{
+	public C.Command CommandID;
+	public byte UserID;
+	public byte RoomID;
+
+	public override string ToString()
+	{
+		return $"CommandID: {CommandID}, UserID: {UserID}, RoomID: {RoomID}";
+	}
+}
+
+[StructLayout(LayoutKind.Sequential, Pack = 1)]
+public struct RoomRequest
+{
+	public C.Command CommandID;
+	public byte UserID;
+
+	public override string ToString()
+	{
+		return $"CommandID: {CommandID}, UserID: {UserID}";
+	}
+}
+
+public struct RoomEntry
+{
+	public byte RoomID;
+	public byte PlayerCount;
+	public byte Capacity;
+	public string Name;
+
+	public override string ToString()
+	{
+		return $"RoomID: {RoomID}, Name: {Name}, Players: {PlayerCount}/{Capacity}";
+	}
+}
+
+public struct RoomList
+{
+	public C.Command CommandID;
+	public RoomEntry[] Rooms;
+
+	public override string ToString()
+	{
+		return $"CommandID: {CommandID}, Rooms: {string.Join("; ", Rooms)}";
+	}
+}
+
+[StructLayout(LayoutKind.Sequential, Pack = 1)]
+public struct RoomAssignment
+{
+	public C.Command CommandID;
+	public byte UserID;
+	public byte RoomID;
+	public RoomStatus Status;
+
+	public override string ToString()
+	{
+		return $"CommandID: {CommandID}, UserID: {UserID}, RoomID: {RoomID}, Status: {Status}";
+	}
+}
+
+[StructLayout(LayoutKind.Sequential, Pack = 1)]
+public struct MatchRequest
+{
+	public C.Command CommandID;
+	public byte UserID;
+	public ushort RTTMillis; // ignored by the server, which measures RTT itself
+	public ushort Skill;
+	public bool HasSkill;
+
+	public override string ToString()
+	{
+		return $"CommandID: {CommandID}, UserID: {UserID}, RTTMillis: {RTTMillis}, Skill: {Skill}, HasSkill: {HasSkill}";
+	}
+}
+
+[StructLayout(LayoutKind.Sequential, Pack = 1)]
+public struct MatchAssignment
+{
+	public C.Command CommandID;
+	public byte UserID;
+	public byte RoomID;
+	public byte PlayerCount;
+
+	public override string ToString()
+	{
+		return $"CommandID: {CommandID}, UserID: {UserID}, RoomID: {RoomID}, PlayerCount: {PlayerCount}";
+	}
+}
+
+[System.Flags]
+public enum SnapshotMask : byte
+{
+	X = 0x01,
+	Y = 0x02,
+	Z = 0x04,
+	RotY = 0x08,
+	Kind = 0x10, // The entity kind precedes the fields
+	All = X | Y | Z | RotY,
+	Removed = 0x80,
+}
+
+public enum EntityKind : byte
+{
+	Player = 0,
+	NPC = 1,
+	Projectile = 2,
+	Pickup = 3,
+}
+
+public struct SnapshotEntry
+{
+	public ushort EntityID; // 1-255 are players keyed by user ID
+	public SnapshotMask Mask;
+	public EntityKind Kind;
+	public float X;
+	public float Y;
+	public float Z;
+	public float RotY;
+
+	public override string ToString()
+	{
+		return $"EntityID: {EntityID}, Mask: {Mask}, Kind: {Kind}, X: {X}, Y: {Y}, Z: {Z}, RotY: {RotY}";
+	}
+}
+
+public struct SnapshotData
+{
+	public C.Command CommandID;
+	public uint Sequence;
+	public uint Baseline; // 0 for a full snapshot
+	public SnapshotEntry[] Entries;
+
+	public override string ToString()
+	{
+		return $"CommandID: {CommandID}, Sequence: {Sequence}, Baseline: {Baseline}, Entries: {Entries.Length}";
+	}
+}
+
+[StructLayout(LayoutKind.Sequential, Pack = 1)]
+public struct SnapshotAck
+{
+	public C.Command CommandID;
+	public byte UserID;
+	public uint Sequence;
+
+	public override string ToString()
+	{
+		return $"CommandID: {CommandID}, UserID: {UserID}, Sequence: {Sequence}";
+	}
+}
+
+public struct Fragment
+{
+	public C.Command CommandID;
+	public ushort MessageID;
+	public byte Index;
+	public byte Count;
+	public byte[] Payload;
+
+	public override string ToString()
+	{
+		return $"CommandID: {CommandID}, MessageID: {MessageID}, Fragment: {Index + 1}/{Count}, Payload: {Payload.Length} bytes";
+	}
+}
+
+public struct ConnectChallenge
+{
+	public C.Command CommandID;
+	public byte[] Cookie; // 16 bytes, echoed in the next PORT_REQUEST
+
+	public override string ToString()
+	{
+		return $"CommandID: {CommandID}, Cookie: {Convert.ToHexString(Cookie)}";
+	}
+}
+
+public struct KeyExchange
+{
+	public C.Command CommandID;
+	public byte[] PublicKey; // 32-byte X25519 share
+	public byte[] Signature; // 64-byte Ed25519 signature of both shares, in the server's reply
+
+	public override string ToString()
+	{
+		return $"CommandID: {CommandID}, PublicKey: {Convert.ToHexString(PublicKey)}";
+	}
+}
+
+[StructLayout(LayoutKind.Sequential, Pack = 1)]
+public struct InputFrame
+{
+	public uint Sequence;
+	public float MoveX; // World-space movement direction, length at most 1
+	public float MoveZ;
+	public float RotY;
+	public float Delta; // Seconds the input was held
+
+	public override string ToString()
+	{
+		return $"Sequence: {Sequence}, MoveX: {MoveX}, MoveZ: {MoveZ}, RotY: {RotY}, Delta: {Delta}";
+	}
+}
+
+public struct InputData
+{
+	public C.Command CommandID;
+	public byte UserID;
+	public InputFrame[] Frames; // Oldest first, resent until acknowledged
+
+	public override string ToString()
+	{
+		return $"CommandID: {CommandID}, UserID: {UserID}, Frames: {Frames.Length}";
+	}
+}
+
+[StructLayout(LayoutKind.Sequential, Pack = 1)]
+public struct AuthState
+{
+	public C.Command CommandID;
+	public byte UserID;
+	public uint LastSequence;
+	public float X;
+	public float Y;
+	public float Z;
+	public float RotY;
+
+	public override string ToString()
+	{
+		return $"CommandID: {CommandID}, UserID: {UserID}, LastSequence: {LastSequence}, X: {X}, Y: {Y}, Z: {Z}, RotY: {RotY}";
+	}
+}
+
+[StructLayout(LayoutKind.Sequential, Pack = 1)]
+public struct EntitySpawn
+{
+	public C.Command CommandID;
+	public ushort EntityID;
+	public EntityKind Kind;
+	public byte OwnerID; // 0 for entities spawned by the server
+	public float X;
+	public float Y;
+	public float Z;
+	public float RotY;
+	public float VX; // Velocity the entity moves by until a snapshot or transform says otherwise
+	public float VY;
+	public float VZ;
+
+	public override string ToString()
+	{
+		return $"CommandID: {CommandID}, EntityID: {EntityID}, Kind: {Kind}, OwnerID: {OwnerID}, X: {X}, Y: {Y}, Z: {Z}, RotY: {RotY}, Velocity: ({VX}, {VY}, {VZ})";
+	}
+}
+
+[StructLayout(LayoutKind.Sequential, Pack = 1)]
+public struct EntityDespawn
+{
+	public C.Command CommandID;
+	public ushort EntityID;
+
+	public override string ToString()
+	{
+		return $"CommandID: {CommandID}, EntityID: {EntityID}";
+	}
+}
+
+[StructLayout(LayoutKind.Sequential, Pack = 1)]
+public struct EntityTransform
+{
+	public ushort EntityID;
+	public float X;
+	public float Y;
+	public float Z;
+	public float RotY;
+	public float VX;
+	public float VY;
+	public float VZ;
+
+	public override string ToString()
+	{
+		return $"EntityID: {EntityID}, X: {X}, Y: {Y}, Z: {Z}, RotY: {RotY}, Velocity: ({VX}, {VY}, {VZ})";
+	}
+}
+
+public struct EntityTransforms
+{
+	public C.Command CommandID;
+	public EntityTransform[] Entities; // only entities the client was spawned are moved
+
+	public override string ToString()
+	{
+		return $"CommandID: {CommandID}, Entities: {Entities.Length}";
+	}
+}
+
+public enum PropertyType : byte
+{
+	Bool = 0,
+	UInt8 = 1,
+	Int32 = 2,
+	Float32 = 3,
+	Vec3 = 4,
+	String = 5,
+}
+
+public struct PropertyValue
+{
+	public byte ID;
+	public PropertyType Type;
+	public object Value; // bool, byte, int, float, System.Numerics.Vector3 or string
+
+	public override string ToString()
+	{
+		return $"ID: {ID}, Type: {Type}, Value: {Value}";
+	}
+}
+
+public struct EntityProperties
+{
+	public ushort EntityID;
+	public PropertyValue[] Values;
+
+	public override string ToString()
+	{
+		return $"EntityID: {EntityID}, Values: {Values.Length}";
+	}
+}
+
+public struct PropertyUpdate
+{
+	public C.Command CommandID;
+	public EntityProperties[] Entities;
+
+	public override string ToString()
+	{
+		return $"CommandID: {CommandID}, Entities: {Entities.Length}";
+	}
+}
+
+public struct Reliable
+{
+	public C.Command CommandID;
+	public ushort Sequence;
+	public byte[] Payload; // A complete message, delivered in order and exactly once
+
+	public override string ToString()
+	{
+		return $"CommandID: {CommandID}, Sequence: {Sequence}, Payload: {Payload.Length} bytes";
+	}
+}
+
+[StructLayout(LayoutKind.Sequential, Pack = 1)]
+public struct ReliableAck
+{
+	public C.Command CommandID;
+	public ushort Sequence;
+
+	public override string ToString()
+	{
+		return $"CommandID: {CommandID}, Sequence: {Sequence}";
+	}
+}
+
+public enum ChatChannel : byte
+{
+	Global = 0,
+	Room = 1,
+	Whisper = 2,
+	System = 3, // Server notices, never sent by clients
+}
+
+public struct ChatMessage
+{
+	public C.Command CommandID;
+	public byte UserID; // Sender, 0 for system notices
+	public ChatChannel Channel;
+	public byte Target; // Recipient of a whisper, otherwise 0
+	public uint Timestamp; // Unix seconds, set by the server
+	public string Text;
+
+	public override string ToString()
+	{
+		return $"CommandID: {CommandID}, UserID: {UserID}, Channel: {Channel}, Target: {Target}, Timestamp: {Timestamp}, Text: {Text}";
+	}
+}
+
+public struct PlayerInfo
+{
+	public C.Command CommandID;
+	public byte UserID;
+	public uint Color; // RGBA8, 0 for the default color
+	public byte Skin;
+	public string Name;
+
+	public override string ToString()
+	{
+		return $"CommandID: {CommandID}, UserID: {UserID}, Color: {Color:X8}, Skin: {Skin}, Name: {Name}";
+	}
+}
+
+public enum ProfileStatus : byte
+{
+	OK = 0,
+	NameInvalid = 1,
+	NameTaken = 2,
+	Banned = 3,
+}
+
+[StructLayout(LayoutKind.Sequential, Pack = 1)]
+public struct ProfileRejected
+{
+	public C.Command CommandID;
+	public ProfileStatus Status;
+
+	public override string ToString()
+	{
+		return $"CommandID: {CommandID}, Status: {Status}";
+	}
+}
+
+[StructLayout(LayoutKind.Sequential, Pack = 1)]
+public struct PlayerState
+{
+	public byte UserID;
+	public float X;
+	public float Y;
+	public float Z;
+	public float RotY;
+
+	public override string ToString()
+	{
+		return $"UserID: {UserID}, X: {X}, Y: {Y}, Z: {Z}, RotY: {RotY}";
+	}
+}
+
+public struct JoinSnapshot
+{
+	public C.Command CommandID;
+	public byte RoomID;
+	public PlayerState[] Players; // Everyone in the area of interest when we registered
+	public EntitySpawn[] Entities;
+
+	public override string ToString()
+	{
+		return $"CommandID: {CommandID}, RoomID: {RoomID}, Players: {Players.Length}, Entities: {Entities.Length}";
+	}
+}
+
+[StructLayout(LayoutKind.Sequential, Pack = 1)]
+public struct PlayerEvent
+{
+	public C.Command CommandID;
+	public byte UserID;
+
+	public override string ToString()
+	{
+		return $"CommandID: {CommandID}, UserID: {UserID}";
+	}
+}
@@ -5,6 +5,9 @@ public partial class PlayerController : Node
 	[Signal]
 	public delegate void PlayerPositionUpdatedEventHandler(Vector3 position, float rotationY);
 
+	[Signal]
+	public delegate void PlayerInputSampledEventHandler(Vector2 movement, float rotationY, float delta);
+
 	[Export]
 	public float MovementSpeed { get; set; } = 10.0f;
 
@@ -35,6 +38,10 @@ public partial class PlayerController : Node
 		// Rotate direction to match player orientation
 		movementDirection = movementDirection.Rotated(Vector3.Up, _parent.Rotation.Y);
 
+		// Emit the world-space input for server-authoritative movement
+		EmitSignal(SignalName.PlayerInputSampled,
+			new Vector2(movementDirection.X, movementDirection.Z), _parent.Rotation.Y, (float)delta);
+
 		// Apply velocity
 		_parent.Velocity = movementDirection * MovementSpeed;
 		_parent.MoveAndSlide();
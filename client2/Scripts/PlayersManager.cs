@@ -13,6 +13,15 @@ using System.Collections.Generic;
 
 public partial class PlayersManager : Node3D
 {
+	[Signal]
+	public delegate void ChatMessageReceivedEventHandler(byte userId, byte channel, byte target, string text);
+
+	[Signal]
+	public delegate void PlayerJoinedEventHandler(byte userId);
+
+	[Signal]
+	public delegate void PlayerLeftEventHandler(byte userId);
+
 	// Networking constants
 	private const int _serverPort = 8080;
 	private const string _serverAddress = "127.0.0.1";
@@ -27,6 +36,41 @@ public partial class PlayersManager : Node3D
 	private Dictionary<byte, CharacterBody3D> _otherPlayers = new Dictionary<byte, CharacterBody3D>();
 	private CharacterBody3D _localPlayer;
 
+	// Profile sent in the PORT_REQUEST; an empty name lets the server pick a default one
+	[Export]
+	private string _playerName = "";
+	[Export]
+	private Color _playerColor = new Color(0, 0, 0, 0);
+	[Export]
+	private byte _playerSkin = 0;
+
+	// Secure transport: everything after the KEY_EXCHANGE is sealed with ChaCha20-Poly1305. The server's key
+	// share must be signed by the identity key it logs at startup, given here in hex.
+	[Export]
+	private bool _secureTransport = false;
+	[Export]
+	private string _serverIdentityKey = "";
+	private BU.SecureSession _secure;
+	private byte[] _keyExchangePrivate;
+	private byte[] _keyExchangePublic;
+
+	// Random secret kept between sessions so the server can restore our name and position
+	private const string _secretPath = "user://player_secret.bin";
+	private byte[] _playerSecret;
+	private bool _banned = false; // set when the server refuses our identity, stops port request retries
+
+	// Names and cosmetics of every player, written from the network thread
+	private ConcurrentDictionary<byte, PlayerInfo> _playerInfos = new ConcurrentDictionary<byte, PlayerInfo>();
+
+	// Non-player entities, moved by their last known velocity between server updates
+	[Export]
+	private PackedScene _entityScene;
+	private Dictionary<ushort, Node3D> _entities = new Dictionary<ushort, Node3D>();
+	private Dictionary<ushort, Vector3> _entityVelocities = new Dictionary<ushort, Vector3>();
+
+	// Replicated entity properties by entity and property ID, written from the network thread
+	private ConcurrentDictionary<ushort, ConcurrentDictionary<byte, object>> _entityProperties = new ConcurrentDictionary<ushort, ConcurrentDictionary<byte, object>>();
+
 	// Network components
 	private IPEndPoint _serverIP;
 	private UdpClient _udpClient;
@@ -34,6 +78,8 @@ public partial class PlayersManager : Node3D
 	private bool _isRunning = false;
 	private int _assignedPort = -1; // Dynamically assigned by server
 	private byte _userID = 0; // Will be assigned by server
+	private byte _roomID = 0; // Lobby until a room is joined
+	private byte[] _connectCookie = new byte[BU.BinaryUtils.CookieSize]; // Echoed in PORT_REQUEST
 	private double _targetFrameTime = 1.0 / _targetFPS;
 
 	// RTT tracking
@@ -42,6 +88,32 @@ public partial class PlayersManager : Node3D
 	private double _averageRtt = 0;
 	private int _rttSamples = 0;
 
+	// Server-authoritative movement: inputs are predicted locally and kept until the
+	// server acknowledges them, then replayed on top of each AUTH_STATE
+	[Export]
+	private bool _serverAuthoritative = false;
+	private const int _maxPendingInputs = 64;
+	private const int _maxInputsPerPacket = 8;
+	private const float _reconcileThreshold = 0.01f;
+	private uint _inputSequence = 0;
+	private List<InputFrame> _pendingInputs = new List<InputFrame>();
+	private PlayerController _localController;
+
+	// Delta snapshots received from the server, keyed by sequence number
+	private const int _snapshotHistorySize = 32;
+	private Dictionary<uint, Dictionary<ushort, SnapshotEntry>> _snapshots = new Dictionary<uint, Dictionary<ushort, SnapshotEntry>>();
+
+	// Reassembly of messages larger than one datagram
+	private BU.FragmentReassembler _fragments = new BU.FragmentReassembler(TimeSpan.FromSeconds(2));
+
+	// Decodes POSITION_COMPACT broadcasts, must match the server's CompactPositions
+	private BU.Quantizer _quantizer = BU.Quantizer.Default;
+
+	// Reliable delivery of chat, resent until the server acknowledges it
+	private const int _maxPendingReliable = 256;
+	private static readonly TimeSpan _reliableResendInterval = TimeSpan.FromMilliseconds(200);
+	private BU.ReliableChannel _reliable = new BU.ReliableChannel(_maxPendingReliable);
+
 	// Log level for debugging
 	private enum LogLevel { Debug, Info, Warning, Error }
 	private LogLevel _logLevel = LogLevel.Info;
@@ -77,6 +149,8 @@ public partial class PlayersManager : Node3D
 		// Create a unique client instance ID (helpful with multiple clients)
 		_clientInstanceId = $"Client-{Guid.NewGuid().ToString().Substring(0, 6)}";
 
+		LoadPlayerSecret();
+
 		// Initialize network
 		InitializeNetwork();
 		_isRunning = true;
@@ -88,6 +162,17 @@ public partial class PlayersManager : Node3D
 		SetupRttDisplay();
 	}
 
+	public override void _Process(double delta)
+	{
+		foreach (var (entityId, velocity) in _entityVelocities)
+		{
+			if (_entities.TryGetValue(entityId, out var entity))
+			{
+				entity.Position += velocity * (float)delta;
+			}
+		}
+	}
+
 	private void SetupRttDisplay()
 	{
 		_rttLabel = new Label();
@@ -126,12 +211,46 @@ public partial class PlayersManager : Node3D
 		}
 	}
 
+	private void LoadPlayerSecret()
+	{
+		try
+		{
+			if (FileAccess.FileExists(_secretPath))
+			{
+				using var file = FileAccess.Open(_secretPath, FileAccess.ModeFlags.Read);
+				byte[] secret = file?.GetBuffer(BU.BinaryUtils.SecretSize);
+				if (secret != null && secret.Length == BU.BinaryUtils.SecretSize)
+				{
+					_playerSecret = secret;
+					return;
+				}
+			}
+
+			_playerSecret = System.Security.Cryptography.RandomNumberGenerator.GetBytes(BU.BinaryUtils.SecretSize);
+			using var created = FileAccess.Open(_secretPath, FileAccess.ModeFlags.Write);
+			created?.StoreBuffer(_playerSecret);
+		}
+		catch (Exception e)
+		{
+			// Without a secret we play anonymously and start fresh every session
+			Log($"Failed to load player secret: {e}", LogLevel.Warning);
+			_playerSecret = null;
+		}
+	}
+
 	private void SendPortRequest()
 	{
+		// In secure mode the port request is only sent inside a session
+		if (_secureTransport && _secure == null)
+		{
+			SendKeyExchange();
+			return;
+		}
+
 		try
 		{
-			byte[] requestData = [(byte)C.Command.PORT_REQUEST];
-			_udpClient.Send(requestData, requestData.Length, _serverIP);
+			byte[] requestData = BU.BinaryUtils.SerializePortRequest(_connectCookie, _playerName, _playerColor.ToRgba32(), _playerSkin, _playerSecret);
+			SendDatagram(requestData);
 			Log("Port request sent to server", LogLevel.Debug);
 		}
 		catch (Exception e)
@@ -140,6 +259,111 @@ public partial class PlayersManager : Node3D
 		}
 	}
 
+	/// Starts a key exchange, proving we own the current session if we have one
+	private void SendKeyExchange()
+	{
+		try
+		{
+			(_keyExchangePrivate, _keyExchangePublic) = BU.X25519.GenerateKeyPair();
+			byte[] proof = _secure?.RekeyProof(_keyExchangePublic);
+
+			byte[] requestData = BU.BinaryUtils.SerializeKeyExchange(_keyExchangePublic, _connectCookie, proof);
+			_udpClient.Send(requestData, requestData.Length, _serverIP);
+			Log("Key exchange sent to server", LogLevel.Debug);
+		}
+		catch (Exception e)
+		{
+			Log($"Failed to send key exchange: {e}", LogLevel.Error);
+		}
+	}
+
+	private void HandleKeyExchange(byte[] data)
+	{
+		try
+		{
+			if (_keyExchangePrivate == null) return; // Not waiting for one
+
+			var reply = BU.BinaryUtils.DeserializeKeyExchange(data);
+			_secure = BU.SecureSession.Establish(_keyExchangePrivate, _keyExchangePublic, reply.PublicKey, reply.Signature,
+				Convert.FromHexString(_serverIdentityKey));
+			_keyExchangePrivate = null;
+			Log("Secure session established", LogLevel.Info);
+
+			SendPortRequest();
+		}
+		catch (Exception e)
+		{
+			Log($"Failed to process key exchange: {e}", LogLevel.Error);
+		}
+	}
+
+	private void HandleKeyExchangeChallenge(byte[] data)
+	{
+		try
+		{
+			if (_keyExchangePrivate == null) return; // Not waiting for one
+
+			var challenge = BU.BinaryUtils.DeserializeConnectChallenge(data);
+			_connectCookie = challenge.Cookie;
+
+			// Answer right away so the cookie is still valid
+			SendKeyExchange();
+		}
+		catch (Exception e)
+		{
+			Log($"Failed to process key exchange challenge: {e}", LogLevel.Error);
+		}
+	}
+
+	/// Writes a datagram to the server, sealed in secure mode
+	private void SendDatagram(byte[] byteArray)
+	{
+		if (_secureTransport)
+		{
+			if (_secure == null) return;
+			byteArray = _secure.Seal(byteArray);
+		}
+		_udpClient.Send(byteArray, byteArray.Length, _serverIP);
+	}
+
+	/// Handles a received datagram, opening it first in secure mode
+	private void ProcessDatagram(byte[] data)
+	{
+		if (data == null || data.Length == 0) return;
+
+		if (!_secureTransport)
+		{
+			ProcessPacket(data);
+			return;
+		}
+
+		switch (BU.BinaryUtils.GetCommand(data))
+		{
+			case C.Command.KEY_EXCHANGE:
+				HandleKeyExchange(data);
+				break;
+
+			// A key exchange without a valid cookie is answered with a plaintext challenge
+			case C.Command.CONNECT_CHALLENGE:
+				HandleKeyExchangeChallenge(data);
+				break;
+
+			case C.Command.SECURE:
+				byte[] plaintext = _secure?.Open(data);
+				if (plaintext == null)
+				{
+					Log("Rejected secure packet from server", LogLevel.Debug);
+					return;
+				}
+				ProcessPacket(plaintext);
+				break;
+
+			default:
+				Log("Dropping unsealed packet in secure mode", LogLevel.Debug);
+				break;
+		}
+	}
+
 	private async Task NetworkLoop()
 	{
 		var timer = new PeriodicTimer(TimeSpan.FromSeconds(_targetFrameTime));
@@ -153,11 +377,17 @@ public partial class PlayersManager : Node3D
 				{
 					IPEndPoint remoteEP = new IPEndPoint(IPAddress.Any, 0);
 					byte[] data = _udpClient.Receive(ref remoteEP);
-					ProcessPacket(data);
+					ProcessDatagram(data);
+				}
+
+				// Resend reliable messages the server has not acknowledged
+				foreach (var reliable in _reliable.Due(_reliableResendInterval))
+				{
+					SendToServer(BU.BinaryUtils.SerializeReliable(reliable));
 				}
 
 				// Retry port request if we don't have one yet
-				if (_assignedPort <= 0 && DateTime.Now.Second % 5 == 0)
+				if (_assignedPort <= 0 && !_banned && DateTime.Now.Second % 5 == 0)
 				{
 					SendPortRequest();
 				}
@@ -185,6 +415,7 @@ public partial class PlayersManager : Node3D
 		// Explicitly set the parent of the controller to be the local player
 		var controller = new PlayerController();
 		_localPlayer.AddChild(controller);
+		_localController = controller;
 
 		// Print debug information
 		GD.Print($"Local player spawned with ID: {_userID}, adding controller");
@@ -194,8 +425,41 @@ public partial class PlayersManager : Node3D
 		controller.Connect(PlayerController.SignalName.PlayerPositionUpdated,
 			new Callable(this, MethodName.OnLocalPlayerPositionUpdated));
 
+		controller.Connect(PlayerController.SignalName.PlayerInputSampled,
+			new Callable(this, MethodName.OnLocalPlayerInputSampled));
+
 		// Print debug information about signals
 		GD.Print($"Connected PlayerPositionUpdated signal");
+
+		ApplyPlayerInfo(_userID);
+	}
+
+	/// Called every physics frame with the local player's input
+	private void OnLocalPlayerInputSampled(Vector2 movement, float rotationY, float delta)
+	{
+		if (!_serverAuthoritative || _userID == 0 || _assignedPort <= 0) return;
+
+		_pendingInputs.Add(new InputFrame
+		{
+			Sequence = ++_inputSequence,
+			MoveX = movement.X,
+			MoveZ = movement.Y,
+			RotY = rotationY,
+			Delta = delta
+		});
+		if (_pendingInputs.Count > _maxPendingInputs)
+		{
+			_pendingInputs.RemoveAt(0);
+		}
+
+		// Resend the newest unacknowledged inputs so a lost packet loses no input
+		int count = Math.Min(_pendingInputs.Count, _maxInputsPerPacket);
+		SendToServer(BU.BinaryUtils.SerializeInputData(new InputData
+		{
+			CommandID = C.Command.INPUT,
+			UserID = _userID,
+			Frames = _pendingInputs.GetRange(_pendingInputs.Count - count, count).ToArray()
+		}));
 	}
 
 	/// Called when the local player's position is updated
@@ -226,13 +490,119 @@ public partial class PlayersManager : Node3D
 		try
 		{
 			var byteArray = BU.BinaryUtils.SerializePositionDataRTT(positionData);
-			_udpClient.Send(byteArray, byteArray.Length, _serverIP);
+			SendDatagram(byteArray);
 		}
 		catch (Exception e)
 		{
 			Log($"Failed to send position update: {e}", LogLevel.Error);
 		}
 	}
+	/// Asks the server for the list of open rooms
+	public void RequestRoomList()
+	{
+		SendToServer(BU.BinaryUtils.SerializeRoomRequest(new RoomRequest
+		{
+			CommandID = C.Command.ROOM_LIST_REQUEST,
+			UserID = _userID
+		}));
+	}
+
+	/// Opens a new room on the server and moves the local player into it
+	public void CreateRoom(string name, byte capacity)
+	{
+		SendToServer(BU.BinaryUtils.SerializeRoomCreate(new RoomCreate
+		{
+			CommandID = C.Command.ROOM_CREATE,
+			UserID = _userID,
+			Capacity = capacity,
+			Name = name
+		}));
+	}
+
+	/// Moves the local player into an existing room
+	public void JoinRoom(byte roomId)
+	{
+		SendToServer(BU.BinaryUtils.SerializeRoomJoin(new RoomJoin
+		{
+			CommandID = C.Command.ROOM_JOIN,
+			UserID = _userID,
+			RoomID = roomId
+		}));
+	}
+
+	/// Returns the local player to the lobby
+	public void LeaveRoom()
+	{
+		SendToServer(BU.BinaryUtils.SerializeRoomRequest(new RoomRequest
+		{
+			CommandID = C.Command.ROOM_LEAVE,
+			UserID = _userID
+		}));
+	}
+
+	/// Enters the matchmaking queue, matched by skill when one is given.
+	/// The server matches by the RTT it measures with its pings.
+	public void RequestMatch(ushort? skill = null)
+	{
+		SendToServer(BU.BinaryUtils.SerializeMatchRequest(new MatchRequest
+		{
+			CommandID = C.Command.MATCH_REQUEST,
+			UserID = _userID,
+			RTTMillis = (ushort)Math.Min(_averageRtt, ushort.MaxValue),
+			Skill = skill ?? 0,
+			HasSkill = skill.HasValue
+		}));
+	}
+
+	/// Leaves the matchmaking queue
+	public void CancelMatch()
+	{
+		SendToServer(BU.BinaryUtils.SerializeRoomRequest(new RoomRequest
+		{
+			CommandID = C.Command.MATCH_CANCEL,
+			UserID = _userID
+		}));
+	}
+
+	/// Sends a chat message; whispers go to the player given by target
+	public void SendChat(ChatChannel channel, string text, byte target = 0)
+	{
+		SendReliable(BU.BinaryUtils.SerializeChatMessage(new ChatMessage
+		{
+			CommandID = C.Command.CHAT,
+			UserID = _userID,
+			Channel = channel,
+			Target = target,
+			Text = text
+		}));
+	}
+
+	private void SendReliable(byte[] byteArray)
+	{
+		if (_userID == 0 || _assignedPort <= 0) return; // Don't send until we have an ID and port
+
+		if (!_reliable.TrySend(byteArray, out var reliable))
+		{
+			Log("Too many unacknowledged reliable messages, dropping one", LogLevel.Warning);
+			return;
+		}
+		SendToServer(BU.BinaryUtils.SerializeReliable(reliable));
+	}
+
+	private void SendToServer(byte[] byteArray)
+	{
+		if (_userID == 0 || _assignedPort <= 0) return; // Don't send until we have an ID and port
+
+		try
+		{
+			SendDatagram(byteArray);
+		}
+		catch (Exception e)
+		{
+			Log($"Failed to send to server: {e}", LogLevel.Error);
+		}
+	}
+
 	private void ProcessPacket(byte[] data)
 	{
 		if (data == null || data.Length == 0) return;
@@ -243,6 +613,24 @@ public partial class PlayersManager : Node3D
 
 			switch (command)
 			{
+				case C.Command.BATCH:
+					foreach (var batched in BU.BinaryUtils.UnpackBatch(data))
+					{
+						ProcessPacket(batched);
+					}
+					break;
+
+				case C.Command.FRAGMENT:
+					if (_fragments.Add(data, out var reassembled))
+					{
+						ProcessPacket(reassembled);
+					}
+					break;
+
+				case C.Command.CONNECT_CHALLENGE:
+					HandleConnectChallenge(data);
+					break;
+
 				case C.Command.PORT_ASSIGNMENT:
 					HandlePortAssignment(data);
 					break;
@@ -257,9 +645,83 @@ public partial class PlayersManager : Node3D
 
 				case C.Command.POSITION_RTT:
 				case C.Command.POSITION:
+				case C.Command.POSITION_COMPACT:
 					HandlePositionUpdate(data);
 					break;
 
+				case C.Command.INTEREST_LEAVE:
+					HandleInterestLeave(data);
+					break;
+
+				case C.Command.ROOM_LIST:
+					HandleRoomList(data);
+					break;
+
+				case C.Command.ROOM_ASSIGNMENT:
+					HandleRoomAssignment(data);
+					break;
+
+				case C.Command.MATCH_ASSIGNMENT:
+					HandleMatchAssignment(data);
+					break;
+
+				case C.Command.SNAPSHOT:
+					HandleSnapshot(data);
+					break;
+
+				case C.Command.AUTH_STATE:
+					HandleAuthState(data);
+					break;
+
+				case C.Command.ENTITY_SPAWN:
+					HandleEntitySpawn(data);
+					break;
+
+				case C.Command.ENTITY_DESPAWN:
+					HandleEntityDespawn(data);
+					break;
+
+				case C.Command.ENTITY_TRANSFORM:
+					HandleEntityTransforms(data);
+					break;
+
+				case C.Command.ENTITY_PROPERTIES:
+					HandlePropertyUpdate(data);
+					break;
+
+				case C.Command.RELIABLE:
+					HandleReliable(data);
+					break;
+
+				case C.Command.PING:
+					HandlePing(data);
+					break;
+
+				case C.Command.RELIABLE_ACK:
+					_reliable.Ack(BU.BinaryUtils.DeserializeReliableAck(data).Sequence);
+					break;
+
+				case C.Command.CHAT:
+					HandleChatMessage(data);
+					break;
+
+				case C.Command.PLAYER_INFO:
+					HandlePlayerInfo(data);
+					break;
+
+				case C.Command.PROFILE_REJECTED:
+					HandleProfileRejected(data);
+					break;
+
+				case C.Command.JOIN_SNAPSHOT:
+					HandleJoinSnapshot(data);
+					break;
+
+				case C.Command.PLAYER_JOINED:
+				case C.Command.PLAYER_LEFT:
+					HandlePlayerEvent(data);
+					break;
+
 				default:
 					Log($"Unknown command: {command}", LogLevel.Debug);
 					break;
@@ -271,6 +733,51 @@ public partial class PlayersManager : Node3D
 		}
 	}
 
+	/// Echoes a server ping so the server can measure our RTT
+	private void HandlePing(byte[] data)
+	{
+		var pong = (byte[])data.Clone();
+		pong[0] = (byte)C.Command.PONG;
+		SendToServer(pong);
+	}
+
+	private void HandleAuthState(byte[] data)
+	{
+		try
+		{
+			var state = BU.BinaryUtils.DeserializeAuthState(data);
+			if (state.UserID != _userID) return;
+
+			CallDeferred(nameof(ReconcileLocalPlayer), state.LastSequence, state.X, state.Y, state.Z, state.RotY);
+		}
+		catch (Exception e)
+		{
+			Log($"Failed to process auth state: {e}", LogLevel.Error);
+		}
+	}
+
+	/// <summary>
+	/// Drops inputs the server has processed and replays the rest from its authoritative position
+	/// </summary>
+	private void ReconcileLocalPlayer(uint lastSequence, float x, float y, float z, float rotY)
+	{
+		if (_localPlayer == null || _localController == null) return;
+
+		_pendingInputs.RemoveAll(input => (int)(input.Sequence - lastSequence) <= 0);
+
+		var predicted = new Vector3(x, y, z);
+		foreach (var input in _pendingInputs)
+		{
+			predicted += new Vector3(input.MoveX, 0, input.MoveZ) * _localController.MovementSpeed * input.Delta;
+		}
+
+		if (predicted.DistanceTo(_localPlayer.Position) > _reconcileThreshold)
+		{
+			Log($"Reconciled local player by {predicted.DistanceTo(_localPlayer.Position):F3} units", LogLevel.Debug);
+			_localPlayer.Position = predicted;
+		}
+	}
+
 	private void HandleRttResponse(byte[] data)
 	{
 		try
@@ -292,6 +799,22 @@ public partial class PlayersManager : Node3D
 		}
 	}
 
+	private void HandleConnectChallenge(byte[] data)
+	{
+		try
+		{
+			var challenge = BU.BinaryUtils.DeserializeConnectChallenge(data);
+			_connectCookie = challenge.Cookie;
+
+			// Answer right away so the cookie is still valid
+			SendPortRequest();
+		}
+		catch (Exception e)
+		{
+			Log($"Failed to process connect challenge: {e}", LogLevel.Error);
+		}
+	}
+
 	private void HandlePortAssignment(byte[] data)
 	{
 		try
@@ -325,6 +848,12 @@ public partial class PlayersManager : Node3D
 		try
 		{
 			var assignment = BU.BinaryUtils.DeserializeUserAssignment(data);
+
+			// A new registration starts a new reliable channel on the server
+			if (assignment.UserID != _userID)
+			{
+				_reliable = new BU.ReliableChannel(_maxPendingReliable);
+			}
 			_userID = assignment.UserID;
 			Log($"Assigned UserID: {_userID}", LogLevel.Info);
 
@@ -344,10 +873,20 @@ public partial class PlayersManager : Node3D
 	{
 		try
 		{
-			var positionData = BU.BinaryUtils.DeserializePositionData(data);
+			var positionData = BU.BinaryUtils.GetCommand(data) == C.Command.POSITION_COMPACT
+				? BU.BinaryUtils.DeserializeCompactPosition(data, _quantizer)
+				: BU.BinaryUtils.DeserializePositionData(data);
 
-			// Skip our own position updates
-			if (positionData.UserID == _userID) return;
+			// Our own position only comes back when the server corrected it
+			if (positionData.UserID == _userID)
+			{
+				CallDeferred(nameof(ApplyServerCorrection),
+					positionData.X,
+					positionData.Y,
+					positionData.Z,
+					positionData.RotY);
+				return;
+			}
 
 			// Queue the update to happen on the main thread
 			CallDeferred(nameof(UpdateRemotePlayerPosition),
@@ -363,35 +902,518 @@ public partial class PlayersManager : Node3D
 		}
 	}
 
-	/// <summary>
-	/// Updates or spawns a remote player at the given position
-	/// </summary>
-	private void UpdateRemotePlayerPosition(byte userId, float x, float y, float z, float rotY)
+	private void HandleInterestLeave(byte[] data)
 	{
-		// Don't update the local player through this method
-		if (userId == _userID) return;
-
-		if (!_otherPlayers.TryGetValue(userId, out var playerNode))
+		try
 		{
-			// Create new player instance
-			playerNode = _playerScene.Instantiate<CharacterBody3D>();
-			// Set initial position with y = 1 only for newly created players
-			playerNode.Position = new Vector3(x, 1, z);
-			_playerContainer.AddChild(playerNode);
-			_otherPlayers[userId] = playerNode;
+			var interestEvent = BU.BinaryUtils.DeserializeInterestEvent(data);
+			CallDeferred(nameof(RemoveRemotePlayer), interestEvent.UserID);
+		}
+		catch (Exception e)
+		{
+			Log($"Failed to process interest event: {e}", LogLevel.Error);
+		}
+	}
+
+	private void HandleRoomList(byte[] data)
+	{
+		try
+		{
+			var roomList = BU.BinaryUtils.DeserializeRoomList(data);
+			foreach (var room in roomList.Rooms)
+			{
+				Log($"Room {room}", LogLevel.Info);
+			}
+		}
+		catch (Exception e)
+		{
+			Log($"Failed to process room list: {e}", LogLevel.Error);
+		}
+	}
+
+	private void HandleRoomAssignment(byte[] data)
+	{
+		try
+		{
+			var assignment = BU.BinaryUtils.DeserializeRoomAssignment(data);
+			if (assignment.Status != RoomStatus.OK)
+			{
+				Log($"Room request for room {assignment.RoomID} failed: {assignment.Status}", LogLevel.Warning);
+				return;
+			}
+
+			_roomID = assignment.RoomID;
+			Log($"Joined room {_roomID}", LogLevel.Info);
+
+			// Players and entities from the previous room are no longer relevant
+			CallDeferred(nameof(ClearRemotePlayers));
+			CallDeferred(nameof(ClearEntities));
+		}
+		catch (Exception e)
+		{
+			Log($"Failed to process room assignment: {e}", LogLevel.Error);
+		}
+	}
+
+	private void HandleMatchAssignment(byte[] data)
+	{
+		try
+		{
+			var assignment = BU.BinaryUtils.DeserializeMatchAssignment(data);
+			_roomID = assignment.RoomID;
+			Log($"Match found: room {_roomID} with {assignment.PlayerCount} players", LogLevel.Info);
+
+			CallDeferred(nameof(ClearRemotePlayers));
+			CallDeferred(nameof(ClearEntities));
+		}
+		catch (Exception e)
+		{
+			Log($"Failed to process match assignment: {e}", LogLevel.Error);
+		}
+	}
+
+	private void HandleSnapshot(byte[] data)
+	{
+		try
+		{
+			var snapshot = BU.BinaryUtils.DeserializeSnapshotData(data);
+
+			// Rebuild the full state from the baseline the server encoded against
+			var states = new Dictionary<ushort, SnapshotEntry>();
+			if (snapshot.Baseline != 0)
+			{
+				if (!_snapshots.TryGetValue(snapshot.Baseline, out var baseline))
+				{
+					Log($"Dropping snapshot {snapshot.Sequence}: unknown baseline {snapshot.Baseline}", LogLevel.Debug);
+					return;
+				}
+				states = new Dictionary<ushort, SnapshotEntry>(baseline);
+			}
+
+			foreach (var entry in snapshot.Entries)
+			{
+				if ((entry.Mask & SnapshotMask.Removed) != 0)
+				{
+					states.Remove(entry.EntityID);
+					if (!IsPlayerEntity(entry.EntityID))
+					{
+						CallDeferred(nameof(RemoveEntity), entry.EntityID);
+					}
+					continue;
+				}
+
+				states.TryGetValue(entry.EntityID, out var state);
+				state.EntityID = entry.EntityID;
+				if ((entry.Mask & SnapshotMask.Kind) != 0) state.Kind = entry.Kind;
+				if ((entry.Mask & SnapshotMask.X) != 0) state.X = entry.X;
+				if ((entry.Mask & SnapshotMask.Y) != 0) state.Y = entry.Y;
+				if ((entry.Mask & SnapshotMask.Z) != 0) state.Z = entry.Z;
+				if ((entry.Mask & SnapshotMask.RotY) != 0) state.RotY = entry.RotY;
+				states[entry.EntityID] = state;
+
+				if (IsPlayerEntity(state.EntityID))
+				{
+					CallDeferred(nameof(UpdateRemotePlayerPosition), (byte)state.EntityID, state.X, state.Y, state.Z, state.RotY);
+				}
+				else
+				{
+					CallDeferred(nameof(UpdateEntity), state.EntityID, (byte)state.Kind, state.X, state.Y, state.Z, state.RotY);
+				}
+			}
+
+			_snapshots[snapshot.Sequence] = states;
+			_snapshots.Remove(snapshot.Sequence - _snapshotHistorySize);
+
+			SendToServer(BU.BinaryUtils.SerializeSnapshotAck(new SnapshotAck
+			{
+				CommandID = C.Command.SNAPSHOT_ACK,
+				UserID = _userID,
+				Sequence = snapshot.Sequence
+			}));
+		}
+		catch (Exception e)
+		{
+			Log($"Failed to process snapshot: {e}", LogLevel.Error);
+		}
+	}
+
+	private void HandleReliable(byte[] data)
+	{
+		try
+		{
+			var reliable = BU.BinaryUtils.DeserializeReliable(data);
+			var payloads = _reliable.Receive(reliable, out bool ack);
+			if (ack)
+			{
+				SendToServer(BU.BinaryUtils.SerializeReliableAck(new ReliableAck
+				{
+					CommandID = C.Command.RELIABLE_ACK,
+					Sequence = reliable.Sequence
+				}));
+			}
+
+			foreach (var payload in payloads)
+			{
+				ProcessPacket(payload);
+			}
+		}
+		catch (Exception e)
+		{
+			Log($"Failed to process reliable message: {e}", LogLevel.Error);
+		}
+	}
+
+	private void HandleChatMessage(byte[] data)
+	{
+		try
+		{
+			var chat = BU.BinaryUtils.DeserializeChatMessage(data);
+			Log($"[{chat.Channel}] {chat.UserID}: {chat.Text}", LogLevel.Info);
+			CallDeferred(nameof(EmitChatMessage), chat.UserID, (byte)chat.Channel, chat.Target, chat.Text);
+		}
+		catch (Exception e)
+		{
+			Log($"Failed to process chat message: {e}", LogLevel.Error);
+		}
+	}
+
+	private void EmitChatMessage(byte userId, byte channel, byte target, string text)
+	{
+		EmitSignal(SignalName.ChatMessageReceived, userId, channel, target, text);
+	}
+
+	private void HandlePlayerInfo(byte[] data)
+	{
+		try
+		{
+			var info = BU.BinaryUtils.DeserializePlayerInfo(data);
+			_playerInfos[info.UserID] = info;
+			Log($"Player {info.UserID} is {info.Name}", LogLevel.Info);
+			CallDeferred(nameof(ApplyPlayerInfo), info.UserID);
+		}
+		catch (Exception e)
+		{
+			Log($"Failed to process player info: {e}", LogLevel.Error);
+		}
+	}
+
+	private void HandleJoinSnapshot(byte[] data)
+	{
+		try
+		{
+			var snapshot = BU.BinaryUtils.DeserializeJoinSnapshot(data);
+			Log($"Join snapshot: {snapshot}", LogLevel.Info);
+
+			foreach (var player in snapshot.Players)
+			{
+				CallDeferred(nameof(UpdateRemotePlayerPosition), player.UserID, player.X, player.Y, player.Z, player.RotY);
+			}
+			foreach (var spawn in snapshot.Entities)
+			{
+				CallDeferred(nameof(UpdateEntity), spawn.EntityID, (byte)spawn.Kind, spawn.X, spawn.Y, spawn.Z, spawn.RotY);
+				CallDeferred(nameof(SetEntityVelocity), spawn.EntityID, new Vector3(spawn.VX, spawn.VY, spawn.VZ));
+			}
+		}
+		catch (Exception e)
+		{
+			Log($"Failed to process join snapshot: {e}", LogLevel.Error);
+		}
+	}
+
+	private void HandlePlayerEvent(byte[] data)
+	{
+		try
+		{
+			var playerEvent = BU.BinaryUtils.DeserializePlayerEvent(data);
+			if (playerEvent.CommandID == C.Command.PLAYER_JOINED)
+			{
+				Log($"Player {playerEvent.UserID} joined", LogLevel.Info);
+				CallDeferred(nameof(EmitPlayerEvent), playerEvent.UserID, true);
+				return;
+			}
+
+			Log($"Player {playerEvent.UserID} left", LogLevel.Info);
+			_playerInfos.TryRemove(playerEvent.UserID, out _);
+			CallDeferred(nameof(RemoveRemotePlayer), playerEvent.UserID);
+			CallDeferred(nameof(EmitPlayerEvent), playerEvent.UserID, false);
+		}
+		catch (Exception e)
+		{
+			Log($"Failed to process player event: {e}", LogLevel.Error);
+		}
+	}
+
+	private void EmitPlayerEvent(byte userId, bool joined)
+	{
+		EmitSignal(joined ? SignalName.PlayerJoined : SignalName.PlayerLeft, userId);
+	}
+
+	private void HandleProfileRejected(byte[] data)
+	{
+		try
+		{
+			var rejected = BU.BinaryUtils.DeserializeProfileRejected(data);
+			if (rejected.Status == ProfileStatus.Banned)
+			{
+				Log("Server refused to register us: banned", LogLevel.Error);
+				_banned = true;
+				return;
+			}
+
+			Log($"Server rejected name \"{_playerName}\": {rejected.Status}, retrying with a default name", LogLevel.Warning);
+
+			// The next port request retry registers without a chosen name
+			_playerName = "";
+		}
+		catch (Exception e)
+		{
+			Log($"Failed to process profile rejection: {e}", LogLevel.Error);
+		}
+	}
 
-			// Set a different color to distinguish players
-			MeshInstance3D mesh = playerNode.GetNodeOrNull<MeshInstance3D>("Mesh");
-			if (mesh != null)
+	private void HandleEntitySpawn(byte[] data)
+	{
+		try
+		{
+			var spawn = BU.BinaryUtils.DeserializeEntitySpawn(data);
+			CallDeferred(nameof(UpdateEntity), spawn.EntityID, (byte)spawn.Kind, spawn.X, spawn.Y, spawn.Z, spawn.RotY);
+			CallDeferred(nameof(SetEntityVelocity), spawn.EntityID, new Vector3(spawn.VX, spawn.VY, spawn.VZ));
+		}
+		catch (Exception e)
+		{
+			Log($"Failed to process entity spawn: {e}", LogLevel.Error);
+		}
+	}
+
+	private void HandleEntityDespawn(byte[] data)
+	{
+		try
+		{
+			var despawn = BU.BinaryUtils.DeserializeEntityDespawn(data);
+			CallDeferred(nameof(RemoveEntity), despawn.EntityID);
+		}
+		catch (Exception e)
+		{
+			Log($"Failed to process entity despawn: {e}", LogLevel.Error);
+		}
+	}
+
+	private void HandleEntityTransforms(byte[] data)
+	{
+		try
+		{
+			var transforms = BU.BinaryUtils.DeserializeEntityTransforms(data);
+			foreach (var transform in transforms.Entities)
 			{
-				StandardMaterial3D material = new StandardMaterial3D();
-				// Generate a unique color based on user ID
-				material.AlbedoColor = new Color(
-					(userId * 50) % 255 / 255f,
-					(userId * 120) % 255 / 255f,
-					(userId * 200) % 255 / 255f);
-				mesh.MaterialOverride = material;
+				CallDeferred(nameof(CorrectEntity), transform.EntityID, transform.X, transform.Y, transform.Z, transform.RotY,
+					new Vector3(transform.VX, transform.VY, transform.VZ));
 			}
+		}
+		catch (Exception e)
+		{
+			Log($"Failed to process entity transforms: {e}", LogLevel.Error);
+		}
+	}
+
+	private void HandlePropertyUpdate(byte[] data)
+	{
+		try
+		{
+			var update = BU.BinaryUtils.DeserializePropertyUpdate(data);
+			foreach (var entity in update.Entities)
+			{
+				var properties = _entityProperties.GetOrAdd(entity.EntityID, _ => new ConcurrentDictionary<byte, object>());
+				foreach (var property in entity.Values)
+				{
+					properties[property.ID] = property.Value;
+				}
+			}
+		}
+		catch (Exception e)
+		{
+			Log($"Failed to process property update: {e}", LogLevel.Error);
+		}
+	}
+
+	/// <summary>
+	/// Returns the last value the server replicated for an entity property
+	/// </summary>
+	public bool TryGetEntityProperty(ushort entityId, byte propertyId, out object value)
+	{
+		value = null;
+		return _entityProperties.TryGetValue(entityId, out var properties) && properties.TryGetValue(propertyId, out value);
+	}
+
+	/// <summary>
+	/// Entity IDs 1-255 are players keyed by their user ID
+	/// </summary>
+	private static bool IsPlayerEntity(ushort entityId)
+	{
+		return entityId != 0 && entityId <= byte.MaxValue;
+	}
+
+	/// <summary>
+	/// Moves an entity, creating it first if this is the first we hear of it
+	/// </summary>
+	private void UpdateEntity(ushort entityId, byte kind, float x, float y, float z, float rotY)
+	{
+		if (!_entities.TryGetValue(entityId, out var entity))
+		{
+			entity = CreateEntityNode((EntityKind)kind);
+			_playerContainer.AddChild(entity);
+			_entities[entityId] = entity;
+			Log($"Entity {entityId} ({(EntityKind)kind}) spawned", LogLevel.Debug);
+		}
+
+		entity.Position = new Vector3(x, y, z);
+		entity.Rotation = new Vector3(0, rotY, 0);
+	}
+
+	/// <summary>
+	/// Moves an entity the server still replicates. Transforms are unreliable and may arrive after
+	/// the despawn, so unknown entities are ignored rather than created.
+	/// </summary>
+	private void CorrectEntity(ushort entityId, float x, float y, float z, float rotY, Vector3 velocity)
+	{
+		if (!_entities.TryGetValue(entityId, out var entity))
+		{
+			return;
+		}
+
+		entity.Position = new Vector3(x, y, z);
+		entity.Rotation = new Vector3(0, rotY, 0);
+		_entityVelocities[entityId] = velocity;
+	}
+
+	private void SetEntityVelocity(ushort entityId, Vector3 velocity)
+	{
+		if (_entities.ContainsKey(entityId))
+		{
+			_entityVelocities[entityId] = velocity;
+		}
+	}
+
+	/// <summary>
+	/// Instantiates the entity scene, or a sphere colored by kind when none is set
+	/// </summary>
+	private Node3D CreateEntityNode(EntityKind kind)
+	{
+		if (_entityScene != null)
+		{
+			return _entityScene.Instantiate<Node3D>();
+		}
+
+		var material = new StandardMaterial3D();
+		material.AlbedoColor = kind switch
+		{
+			EntityKind.NPC => Colors.Orange,
+			EntityKind.Projectile => Colors.Red,
+			EntityKind.Pickup => Colors.Gold,
+			_ => Colors.White
+		};
+
+		var radius = kind == EntityKind.Projectile ? 0.1f : 0.3f;
+		return new MeshInstance3D
+		{
+			Mesh = new SphereMesh { Radius = radius, Height = radius * 2 },
+			MaterialOverride = material
+		};
+	}
+
+	private void RemoveEntity(ushort entityId)
+	{
+		_entityVelocities.Remove(entityId);
+		_entityProperties.TryRemove(entityId, out _);
+		if (_entities.Remove(entityId, out var entity))
+		{
+			entity.QueueFree();
+			Log($"Entity {entityId} despawned", LogLevel.Debug);
+		}
+	}
+
+	private void ClearEntities()
+	{
+		foreach (var entity in _entities.Values)
+		{
+			entity.QueueFree();
+		}
+		_entities.Clear();
+		_entityVelocities.Clear();
+		_entityProperties.Clear();
+	}
+
+	/// <summary>
+	/// Labels a player's node with their name and tints it with their color, once both are known
+	/// </summary>
+	private void ApplyPlayerInfo(byte userId)
+	{
+		CharacterBody3D playerNode = userId == _userID ? _localPlayer : _otherPlayers.GetValueOrDefault(userId);
+		if (playerNode == null) return;
+
+		_playerInfos.TryGetValue(userId, out var info);
+
+		var label = playerNode.GetNodeOrNull<Label3D>("NameLabel");
+		if (label == null)
+		{
+			label = new Label3D();
+			label.Name = "NameLabel";
+			label.Billboard = BaseMaterial3D.BillboardModeEnum.Enabled;
+			label.Position = new Vector3(0, 1.5f, 0);
+			playerNode.AddChild(label);
+		}
+		label.Text = info.Name ?? $"Player {userId}";
+
+		// Players without a chosen color get a unique one based on their user ID
+		MeshInstance3D mesh = playerNode.GetNodeOrNull<MeshInstance3D>("Mesh");
+		if (mesh != null && (info.Color != 0 || userId != _userID))
+		{
+			StandardMaterial3D material = new StandardMaterial3D();
+			material.AlbedoColor = info.Color != 0 ? new Color(info.Color) : new Color(
+				(userId * 50) % 255 / 255f,
+				(userId * 120) % 255 / 255f,
+				(userId * 200) % 255 / 255f);
+			mesh.MaterialOverride = material;
+		}
+	}
+
+	private void ClearRemotePlayers()
+	{
+		foreach (var player in _otherPlayers.Values)
+		{
+			player.QueueFree();
+		}
+		_otherPlayers.Clear();
+	}
+
+	/// <summary>
+	/// Removes a remote player that left our area of interest
+	/// </summary>
+	private void RemoveRemotePlayer(byte userId)
+	{
+		if (_otherPlayers.Remove(userId, out var playerNode))
+		{
+			playerNode.QueueFree();
+			Log($"Remote player left area of interest: {userId}", LogLevel.Debug);
+		}
+	}
+
+	/// <summary>
+	/// Updates or spawns a remote player at the given position
+	/// </summary>
+	private void UpdateRemotePlayerPosition(byte userId, float x, float y, float z, float rotY)
+	{
+		// Don't update the local player through this method
+		if (userId == _userID) return;
+
+		if (!_otherPlayers.TryGetValue(userId, out var playerNode))
+		{
+			// Create new player instance
+			playerNode = _playerScene.Instantiate<CharacterBody3D>();
+			// Set initial position with y = 1 only for newly created players
+			playerNode.Position = new Vector3(x, 1, z);
+			_playerContainer.AddChild(playerNode);
+			_otherPlayers[userId] = playerNode;
+			ApplyPlayerInfo(userId);
 
 			Log($"Remote player joined with ID: {userId}", LogLevel.Info);
 		}
@@ -403,6 +1425,18 @@ public partial class PlayersManager : Node3D
 		}
 	}
 
+	/// <summary>
+	/// Moves the local player to where the server's movement validation put it
+	/// </summary>
+	private void ApplyServerCorrection(float x, float y, float z, float rotY)
+	{
+		if (_localPlayer == null) return;
+
+		_localPlayer.Position = new Vector3(x, y, z);
+		_localPlayer.Rotation = new Vector3(0, rotY, 0);
+		Log($"Position corrected by server to ({x:F2}, {y:F2}, {z:F2})", LogLevel.Debug);
+	}
+
 	private async Task ReceiveLoop()
 	{
 		Log("Starting receive loop...", LogLevel.Info);
@@ -416,7 +1450,7 @@ public partial class PlayersManager : Node3D
 					IPEndPoint remoteEP = new IPEndPoint(IPAddress.Any, 0);
 					byte[] data = _receiveClient.Receive(ref remoteEP);
 
-					ProcessPacket(data);
+					ProcessDatagram(data);
 				}
 				await Task.Delay(1); // Prevent CPU overuse
 			}
@@ -535,6 +1569,7 @@ public partial class PlayersManager : Node3D
 			player.QueueFree();
 		}
 		_otherPlayers.Clear();
+		ClearEntities();
 
 		Log("Network and player cleanup complete", LogLevel.Info);
 	}
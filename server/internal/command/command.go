@@ -3,18 +3,63 @@ package command
 type Command uint8
 
 const (
-	POSITION        Command = iota // 0
-	MOVE                           // 1
-	POSITION_RTT                   // 2
-	MOVE_RTT                       // 3
-	DEFAULT_RTT                    // 4
-	USER_ASSIGNMENT                // 5
-	PORT_REQUEST                   // 6
-	PORT_ASSIGNMENT                // 7
+	POSITION          Command = iota // 0
+	MOVE                             // 1
+	POSITION_RTT                     // 2
+	MOVE_RTT                         // 3
+	DEFAULT_RTT                      // 4
+	USER_ASSIGNMENT                  // 5
+	PORT_REQUEST                     // 6
+	PORT_ASSIGNMENT                  // 7
+	INTEREST_ENTER                   // 8
+	INTEREST_LEAVE                   // 9
+	ROOM_CREATE                      // 10
+	ROOM_JOIN                        // 11
+	ROOM_LEAVE                       // 12
+	ROOM_LIST_REQUEST                // 13
+	ROOM_LIST                        // 14
+	ROOM_ASSIGNMENT                  // 15
+	MATCH_REQUEST                    // 16
+	MATCH_CANCEL                     // 17
+	MATCH_ASSIGNMENT                 // 18
+	SNAPSHOT                         // 19
+	SNAPSHOT_ACK                     // 20
+	POSITION_COMPACT                 // 21
+	BATCH                            // 22
+	FRAGMENT                         // 23
+	KEY_EXCHANGE                     // 24
+	SECURE                           // 25
+	CONNECT_CHALLENGE                // 26
+	INPUT                            // 27
+	AUTH_STATE                       // 28
+	ENTITY_SPAWN                     // 29
+	ENTITY_DESPAWN                   // 30
+	ENTITY_PROPERTIES                // 31
+	RELIABLE                         // 32
+	RELIABLE_ACK                     // 33
+	CHAT                             // 34
+	PLAYER_INFO                      // 35
+	PROFILE_REJECTED                 // 36
+	JOIN_SNAPSHOT                    // 37
+	PLAYER_JOINED                    // 38
+	PLAYER_LEFT                      // 39
+	PING                             // 40
+	PONG                             // 41
+	ENTITY_TRANSFORM                 // 42
 )
 
 func (c Command) String() string {
-	commands := []string{"POSITION", "MOVE", "POSITION_RTT", "MOVE_RTT", "DEFAULT_RTT", "USER_ASSIGNMENT", "PORT_REQUEST", "PORT_ASSIGNMENT"}
+	commands := []string{
+		"POSITION", "MOVE", "POSITION_RTT", "MOVE_RTT", "DEFAULT_RTT", "USER_ASSIGNMENT", "PORT_REQUEST", "PORT_ASSIGNMENT",
+		"INTEREST_ENTER", "INTEREST_LEAVE",
+		"ROOM_CREATE", "ROOM_JOIN", "ROOM_LEAVE", "ROOM_LIST_REQUEST", "ROOM_LIST", "ROOM_ASSIGNMENT",
+		"MATCH_REQUEST", "MATCH_CANCEL", "MATCH_ASSIGNMENT",
+		"SNAPSHOT", "SNAPSHOT_ACK", "POSITION_COMPACT", "BATCH", "FRAGMENT",
+		"KEY_EXCHANGE", "SECURE", "CONNECT_CHALLENGE", "INPUT", "AUTH_STATE", "ENTITY_SPAWN", "ENTITY_DESPAWN", "ENTITY_PROPERTIES",
+		"RELIABLE", "RELIABLE_ACK", "CHAT", "PLAYER_INFO", "PROFILE_REJECTED",
+		"JOIN_SNAPSHOT", "PLAYER_JOINED", "PLAYER_LEFT", "PING", "PONG",
+		"ENTITY_TRANSFORM",
+	}
 	if int(c) < len(commands) {
 		return commands[c]
 	}
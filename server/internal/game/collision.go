@@ -0,0 +1,218 @@
+package game
+
+import "math"
+
+const (
+	// resolveIterations bounds how many contacts are pushed out of per resolve
+	resolveIterations = 8
+
+	// sweepIterations is the precision of SweepDown, halving the interval each time
+	sweepIterations = 12
+
+	// collisionSkin is how close the capsule may get to a surface before it counts as touching
+	collisionSkin = 1e-4
+
+	// walkableNormalY is the steepest surface players walk on (about 45°); steeper ones are walls
+	walkableNormalY = 0.7
+
+	// groundProbe is how far below the capsule Grounded looks for a floor
+	groundProbe = 0.01
+)
+
+// Capsule is an upright capsule centered on the player's position
+type Capsule struct {
+	Radius float32
+	Height float32 // total height including both caps
+}
+
+// segment returns the Y range of the capsule's core segment around centerY
+func (c Capsule) segment(centerY float32) (float32, float32) {
+	half := max(c.Height/2-c.Radius, 0)
+	return centerY - half, centerY + half
+}
+
+// contact is a penetration to resolve by pushing Depth along Normal
+type contact struct {
+	normal Vec3
+	depth  float32
+}
+
+// Collides reports whether a capsule at center overlaps any geometry
+func (l *Level) Collides(center Vec3, capsule Capsule) bool {
+	_, hit := l.deepestContact(center, capsule, nil)
+	return hit
+}
+
+// Grounded reports whether a capsule at center stands on a walkable surface
+func (l *Level) Grounded(center Vec3, capsule Capsule) bool {
+	_, hit := l.deepestContact(center.Sub(Vec3{Y: groundProbe}), capsule, isFloor)
+	return hit
+}
+
+// Resolve pushes a capsule at center out of the geometry it overlaps.
+// Pushing out along the contact normal keeps the tangential part of a move, so the capsule slides.
+func (l *Level) Resolve(center Vec3, capsule Capsule) Vec3 {
+	for i := 0; i < resolveIterations; i++ {
+		c, hit := l.deepestContact(center, capsule, nil)
+		if !hit {
+			break
+		}
+		center = center.Add(c.normal.Scale(c.depth))
+	}
+	return center
+}
+
+// ResolveWalls pushes a capsule horizontally out of walls, surfaces too steep to walk on.
+// Floors and ceilings are left to Resolve, so a horizontal move never lifts the capsule onto a ledge.
+func (l *Level) ResolveWalls(center Vec3, capsule Capsule) Vec3 {
+	for i := 0; i < resolveIterations; i++ {
+		c, hit := l.deepestContact(center, capsule, isWall)
+		if !hit {
+			break
+		}
+
+		// Moving along the horizontal part h of the normal clears depth after depth/|h|
+		horizontal := Vec3{X: c.normal.X, Z: c.normal.Z}
+		length := horizontal.Length()
+		center = center.Add(horizontal.Scale(c.depth / (length * length)))
+	}
+	return center
+}
+
+// SweepDown lowers a capsule by up to distance, stopping where it first touches geometry
+func (l *Level) SweepDown(center Vec3, distance float32, capsule Capsule) Vec3 {
+	lowest := center.Sub(Vec3{Y: distance})
+	if !l.Collides(lowest, capsule) {
+		return lowest
+	}
+	if l.Collides(center, capsule) {
+		return center
+	}
+
+	// Binary search between free (lo) and blocked (hi)
+	lo, hi := float32(0), distance
+	for i := 0; i < sweepIterations; i++ {
+		mid := (lo + hi) / 2
+		if l.Collides(center.Sub(Vec3{Y: mid}), capsule) {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	return center.Sub(Vec3{Y: lo})
+}
+
+// deepestContact finds the deepest penetration whose normal passes accept; nil accepts all
+func (l *Level) deepestContact(center Vec3, capsule Capsule, accept func(normal Vec3) bool) (contact, bool) {
+	var deepest contact
+	hit := false
+
+	consider := func(c contact, ok bool) {
+		if !ok || (accept != nil && !accept(c.normal)) {
+			return
+		}
+		if c.depth > collisionSkin && (!hit || c.depth > deepest.depth) {
+			deepest, hit = c, true
+		}
+	}
+
+	for _, box := range l.Boxes {
+		consider(boxContact(box, center, capsule))
+	}
+	for _, plane := range l.Planes {
+		consider(planeContact(plane, center, capsule))
+	}
+	for _, h := range l.Heightmaps {
+		consider(heightmapContact(h, center, capsule))
+	}
+	return deepest, hit
+}
+
+// isWall accepts surfaces too steep to walk on, excluding ceilings
+func isWall(normal Vec3) bool {
+	return float32(math.Abs(float64(normal.Y))) <= walkableNormalY
+}
+
+// isFloor accepts surfaces players can stand on
+func isFloor(normal Vec3) bool {
+	return normal.Y > walkableNormalY
+}
+
+// boxContact finds the penetration of a capsule into a box. The capsule's core is a vertical
+// segment and the box is axis aligned, so the closest points separate per axis.
+func boxContact(box Box, center Vec3, capsule Capsule) (contact, bool) {
+	y0, y1 := capsule.segment(center.Y)
+
+	onBox := Vec3{
+		X: min(max(center.X, box.Min.X), box.Max.X),
+		Z: min(max(center.Z, box.Min.Z), box.Max.Z),
+	}
+	onSegment := Vec3{X: center.X, Z: center.Z}
+	switch {
+	case y1 < box.Min.Y:
+		onSegment.Y, onBox.Y = y1, box.Min.Y
+	case y0 > box.Max.Y:
+		onSegment.Y, onBox.Y = y0, box.Max.Y
+	default:
+		y := min(max(center.Y, box.Min.Y), box.Max.Y)
+		onSegment.Y, onBox.Y = y, y
+	}
+
+	delta := onSegment.Sub(onBox)
+	if dist := delta.Length(); dist > 0 {
+		if dist >= capsule.Radius {
+			return contact{}, false
+		}
+		return contact{normal: delta.Scale(1 / dist), depth: capsule.Radius - dist}, true
+	}
+
+	// The core is inside the box: leave through the nearest face
+	exits := []contact{
+		{Vec3{X: 1}, box.Max.X - center.X + capsule.Radius},
+		{Vec3{X: -1}, center.X - box.Min.X + capsule.Radius},
+		{Vec3{Y: 1}, box.Max.Y - y0 + capsule.Radius},
+		{Vec3{Y: -1}, y1 - box.Min.Y + capsule.Radius},
+		{Vec3{Z: 1}, box.Max.Z - center.Z + capsule.Radius},
+		{Vec3{Z: -1}, center.Z - box.Min.Z + capsule.Radius},
+	}
+	nearest := exits[0]
+	for _, exit := range exits[1:] {
+		if exit.depth < nearest.depth {
+			nearest = exit
+		}
+	}
+	return nearest, true
+}
+
+// planeContact finds the penetration of a capsule into a solid half-space
+func planeContact(plane Plane, center Vec3, capsule Capsule) (contact, bool) {
+	y0, y1 := capsule.segment(center.Y)
+	bottom := plane.Normal.Dot(Vec3{center.X, y0, center.Z})
+	top := plane.Normal.Dot(Vec3{center.X, y1, center.Z})
+
+	distance := min(bottom, top) - plane.Distance
+	if distance >= capsule.Radius {
+		return contact{}, false
+	}
+	return contact{normal: plane.Normal, depth: capsule.Radius - distance}, true
+}
+
+// heightmapContact lifts a capsule whose bottom is below the terrain
+func heightmapContact(h Heightmap, center Vec3, capsule Capsule) (contact, bool) {
+	ground, ok := h.HeightAt(center.X, center.Z)
+	if !ok {
+		return contact{}, false
+	}
+
+	y0, _ := capsule.segment(center.Y)
+	bottom := y0 - capsule.Radius
+	if bottom >= ground {
+		return contact{}, false
+	}
+	return contact{normal: Vec3{Y: 1}, depth: ground - bottom}, true
+}
+
+// horizontalDistance is the distance between two points ignoring height
+func horizontalDistance(a, b Vec3) float32 {
+	return float32(math.Hypot(float64(a.X-b.X), float64(a.Z-b.Z)))
+}
@@ -0,0 +1,102 @@
+package game
+
+import (
+	"math"
+	"server/internal/message"
+	"testing"
+	"time"
+)
+
+func TestMoverCollision(t *testing.T) {
+	ground := Plane{Normal: Vec3{Y: 1}}
+	ramp := Heightmap{
+		Origin:   Vec3{X: -10, Z: -10},
+		CellSize: 5,
+		Heights: [][]float32{
+			{0, 1, 2, 3, 4},
+			{0, 1, 2, 3, 4},
+			{0, 1, 2, 3, 4},
+			{0, 1, 2, 3, 4},
+			{0, 1, 2, 3, 4},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		level  Level
+		speed  float32
+		start  Vec3
+		moveX  float32
+		moveZ  float32
+		delta  time.Duration
+		want   Vec3
+		margin float32
+	}{
+		{
+			name:  "wall slide keeps the tangential motion",
+			level: Level{Planes: []Plane{ground}, Boxes: []Box{{Min: Vec3{2, 0, -20}, Max: Vec3{3, 5, 20}}}},
+			speed: 10, start: Vec3{0, 1, 0}, moveX: 1, moveZ: 1, delta: time.Second,
+			// Stopped by the wall at X = 2 - radius, still moving the full distance along Z
+			want: Vec3{1.5, 1, 10 / math.Sqrt2}, margin: 0.05,
+		},
+		{
+			name:  "ledge no taller than StepHeight is climbed",
+			level: Level{Planes: []Plane{ground}, Boxes: []Box{{Min: Vec3{2, 0, -5}, Max: Vec3{20, 0.2, 5}}}},
+			speed: 10, start: Vec3{0, 1, 0}, moveX: 1, delta: time.Second,
+			// The sub-step that hits the ledge may lose part of its distance
+			want: Vec3{10, 1.2, 0}, margin: 0.15,
+		},
+		{
+			name:  "ledge taller than StepHeight blocks",
+			level: Level{Planes: []Plane{ground}, Boxes: []Box{{Min: Vec3{2, 0, -5}, Max: Vec3{20, 0.6, 5}}}},
+			speed: 10, start: Vec3{0, 1, 0}, moveX: 1, delta: time.Second,
+			want: Vec3{1.5, 1, 0}, margin: 0.05,
+		},
+		{
+			name:  "heightmap is followed uphill",
+			level: Level{Heightmaps: []Heightmap{ramp}},
+			speed: 10, start: Vec3{0, 3, 0}, moveX: 1, delta: 500 * time.Millisecond,
+			want: Vec3{5, 4, 0}, margin: 0.05,
+		},
+		{
+			name:  "heightmap is followed downhill",
+			level: Level{Heightmaps: []Heightmap{ramp}},
+			speed: 10, start: Vec3{0, 3, 0}, moveX: -1, delta: 500 * time.Millisecond,
+			want: Vec3{-5, 2, 0}, margin: 0.05,
+		},
+		{
+			name:  "thin wall is not tunnelled through at high speed",
+			level: Level{Planes: []Plane{ground}, Boxes: []Box{{Min: Vec3{2, 0, -5}, Max: Vec3{2.05, 5, 5}}}},
+			speed: 1000, start: Vec3{0, 1, 0}, moveX: 1, delta: 100 * time.Millisecond,
+			want: Vec3{1.5, 1, 0}, margin: 0.05,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			level := test.level
+			if err := level.validate(); err != nil {
+				t.Fatal(err)
+			}
+
+			mover := NewMover(MovementConfig{
+				MaxSpeed:         test.speed,
+				MaxVerticalSpeed: 20,
+				Bounds:           Bounds{-1000, -1000, -1000, 1000, 1000, 1000},
+				Capsule:          Capsule{Radius: 0.5, Height: 2},
+				StepHeight:       0.3,
+			})
+			mover.SetLevel(&level)
+
+			start := message.PositionDataRTT{X: test.start.X, Y: test.start.Y, Z: test.start.Z}
+			got := mover.Step(start, message.InputFrame{MoveX: test.moveX, MoveZ: test.moveZ}, test.delta)
+
+			if math.Abs(float64(got.X-test.want.X)) > float64(test.margin) ||
+				math.Abs(float64(got.Y-test.want.Y)) > float64(test.margin) ||
+				math.Abs(float64(got.Z-test.want.Z)) > float64(test.margin) {
+				t.Errorf("ended at (%.3f, %.3f, %.3f), want (%.3f, %.3f, %.3f)",
+					got.X, got.Y, got.Z, test.want.X, test.want.Y, test.want.Z)
+			}
+		})
+	}
+}
@@ -0,0 +1,433 @@
+package game
+
+import (
+	"errors"
+	"server/internal/message"
+	"sort"
+	"sync"
+	"time"
+)
+
+// EntityID identifies a replicated object. IDs 1-255 are players, keyed by their user ID;
+// every other entity gets an ID from FirstEntityID up.
+type EntityID uint16
+
+// FirstEntityID is the lowest ID given to non-player entities
+const FirstEntityID EntityID = 256
+
+var ErrNoEntityIDs = errors.New("no entity IDs available")
+
+// PlayerEntityID returns the entity ID a player is replicated under
+func PlayerEntityID(userID uint8) EntityID {
+	return EntityID(userID)
+}
+
+// IsPlayer reports whether the ID belongs to a player
+func (id EntityID) IsPlayer() bool {
+	return id != 0 && id < FirstEntityID
+}
+
+// EntityKind tells clients what an entity is and how to render it
+type EntityKind uint8
+
+const (
+	EntityPlayer EntityKind = iota
+	EntityNPC
+	EntityProjectile
+	EntityPickup
+)
+
+func (k EntityKind) String() string {
+	kinds := []string{"player", "npc", "projectile", "pickup"}
+	if int(k) < len(kinds) {
+		return kinds[k]
+	}
+	return "unknown"
+}
+
+// MarshalText encodes the kind by name for JSON
+func (k EntityKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// UnmarshalText decodes a kind from its name
+func (k *EntityKind) UnmarshalText(text []byte) error {
+	for kind := EntityPlayer; kind <= EntityPickup; kind++ {
+		if kind.String() == string(text) {
+			*k = kind
+			return nil
+		}
+	}
+	return errors.New("unknown entity kind " + string(text))
+}
+
+// Transform is where an entity is and which way it faces
+type Transform struct {
+	Position Vec3    `json:"position"`
+	RotY     float32 `json:"rotY"`
+}
+
+// Motion moves an entity by Velocity units per second every tick
+type Motion struct {
+	Velocity Vec3 `json:"velocity"`
+}
+
+// Lifetime despawns an entity once Expires has passed
+type Lifetime struct {
+	Expires time.Time `json:"expires"`
+}
+
+// Entity is a replicated non-player object. Optional components are nil when unused.
+type Entity struct {
+	ID         EntityID     `json:"id"`
+	Kind       EntityKind   `json:"kind"`
+	RoomID     uint8        `json:"roomId"`
+	Owner      uint8        `json:"owner"` // user who spawned it, 0 for the server
+	Transform  Transform    `json:"transform"`
+	Motion     *Motion      `json:"motion,omitempty"`
+	Lifetime   *Lifetime    `json:"lifetime,omitempty"`
+	Properties *PropertySet `json:"properties,omitempty"` // set on spawn from the kind's schema
+}
+
+// EntityChange is an entity entering or leaving a player's view
+type EntityChange struct {
+	Observer uint8
+	Entity   Entity
+	Spawned  bool
+}
+
+// State returns the entity's replicated state
+func (e *Entity) State() EntityState {
+	return EntityState{
+		Kind: e.Kind,
+		X:    e.Transform.Position.X,
+		Y:    e.Transform.Position.Y,
+		Z:    e.Transform.Position.Z,
+		RotY: e.Transform.RotY,
+	}
+}
+
+// EntityManager owns every non-player entity and advances their simulation. Entities are kept per
+// room, each room under its own lock, so rooms simulated on different goroutines do not wait for
+// each other; the room index of every entity ID is only locked to spawn and despawn.
+type EntityManager struct {
+	rooms   [256]roomEntities  // indexed by room ID
+	ids     map[EntityID]uint8 // room of every entity
+	nextID  EntityID
+	radius  float32 // players see entities in their room within this distance
+	bounds  Bounds  // entities leaving the world bounds are despawned
+	schemas map[EntityKind]PropertySchema
+	idMu    sync.Mutex // guards ids and nextID, taken after a room's lock
+}
+
+// roomEntities are the entities of one room
+type roomEntities struct {
+	entities map[EntityID]*Entity
+	mu       sync.RWMutex
+}
+
+// NewEntityManager creates an empty entity manager whose entities replicate the properties
+// their kind declares in schemas
+func NewEntityManager(interestRadius float32, bounds Bounds, schemas map[EntityKind]PropertySchema) *EntityManager {
+	em := &EntityManager{
+		ids:     make(map[EntityID]uint8),
+		nextID:  FirstEntityID,
+		radius:  interestRadius,
+		bounds:  bounds,
+		schemas: schemas,
+	}
+	for i := range em.rooms {
+		em.rooms[i].entities = make(map[EntityID]*Entity)
+	}
+	return em
+}
+
+// Spawn assigns the entity an ID, adds it to the world and returns it
+func (em *EntityManager) Spawn(entity Entity) (Entity, error) {
+	room := &em.rooms[entity.RoomID]
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	em.idMu.Lock()
+	defer em.idMu.Unlock()
+
+	// IDs wrap around, skipping player IDs and entities still alive
+	for i := 0; i < int(^EntityID(0)-FirstEntityID)+1; i++ {
+		id := em.nextID
+		em.nextID++
+		if em.nextID < FirstEntityID {
+			em.nextID = FirstEntityID
+		}
+		if _, used := em.ids[id]; used {
+			continue
+		}
+
+		entity.ID = id
+		entity.Properties = NewPropertySet(em.schemas[entity.Kind])
+		em.ids[id] = entity.RoomID
+		room.entities[id] = &entity
+		return entity, nil
+	}
+	return Entity{}, ErrNoEntityIDs
+}
+
+// Despawn removes an entity and returns it
+func (em *EntityManager) Despawn(id EntityID) (Entity, bool) {
+	em.idMu.Lock()
+	roomID, exists := em.ids[id]
+	em.idMu.Unlock()
+	if !exists {
+		return Entity{}, false
+	}
+
+	room := &em.rooms[roomID]
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	entity, exists := room.entities[id]
+	if !exists {
+		return Entity{}, false
+	}
+	delete(room.entities, id)
+	em.forget(id)
+	return *entity, true
+}
+
+// forget releases the IDs of removed entities; the caller holds their room's lock
+func (em *EntityManager) forget(ids ...EntityID) {
+	em.idMu.Lock()
+	defer em.idMu.Unlock()
+
+	for _, id := range ids {
+		delete(em.ids, id)
+	}
+}
+
+// DespawnRooms removes every entity whose room no longer exists
+func (em *EntityManager) DespawnRooms(exists func(roomID uint8) bool) {
+	for i := range em.rooms {
+		if exists(uint8(i)) {
+			continue
+		}
+
+		room := &em.rooms[i]
+		room.mu.Lock()
+		var removed []EntityID
+		for id := range room.entities {
+			delete(room.entities, id)
+			removed = append(removed, id)
+		}
+		if len(removed) > 0 {
+			em.forget(removed...)
+		}
+		room.mu.Unlock()
+	}
+}
+
+// Get returns a copy of an entity
+func (em *EntityManager) Get(id EntityID) (Entity, bool) {
+	em.idMu.Lock()
+	roomID, exists := em.ids[id]
+	em.idMu.Unlock()
+	if !exists {
+		return Entity{}, false
+	}
+
+	room := &em.rooms[roomID]
+	room.mu.RLock()
+	defer room.mu.RUnlock()
+
+	entity, exists := room.entities[id]
+	if !exists {
+		return Entity{}, false
+	}
+	return *entity, true
+}
+
+// All returns a copy of every entity
+func (em *EntityManager) All() []Entity {
+	var entities []Entity
+	for i := range em.rooms {
+		room := &em.rooms[i]
+		room.mu.RLock()
+		for _, entity := range room.entities {
+			entities = append(entities, *entity)
+		}
+		room.mu.RUnlock()
+	}
+	return entities
+}
+
+// Tick advances the moving entities of the rooms owns accepts by dt and despawns the ones that
+// expired or left the world
+func (em *EntityManager) Tick(now time.Time, dt time.Duration, owns func(roomID uint8) bool) {
+	seconds := float32(dt.Seconds())
+	for i := range em.rooms {
+		if !owns(uint8(i)) {
+			continue
+		}
+
+		room := &em.rooms[i]
+		room.mu.Lock()
+		var removed []EntityID
+		for id, entity := range room.entities {
+			if entity.Motion != nil {
+				entity.Transform.Position = entity.Transform.Position.Add(entity.Motion.Velocity.Scale(seconds))
+			}
+
+			pos := entity.Transform.Position
+			expired := entity.Lifetime != nil && !now.Before(entity.Lifetime.Expires)
+			if expired || !em.bounds.Contains(pos.X, pos.Y, pos.Z) {
+				delete(room.entities, id)
+				removed = append(removed, id)
+			}
+		}
+		if len(removed) > 0 {
+			em.forget(removed...)
+		}
+		room.mu.Unlock()
+	}
+}
+
+// Visible returns the states of the entities in the room within view of (x, z)
+func (em *EntityManager) Visible(roomID uint8, x, z float32) map[EntityID]EntityState {
+	room := &em.rooms[roomID]
+	room.mu.RLock()
+	defer room.mu.RUnlock()
+
+	states := make(map[EntityID]EntityState)
+	for id, entity := range room.entities {
+		if em.sees(entity, roomID, x, z) {
+			states[id] = entity.State()
+		}
+	}
+	return states
+}
+
+// Moving returns the transforms of the entities with a velocity in the rooms owns accepts
+func (em *EntityManager) Moving(owns func(roomID uint8) bool) map[EntityID]message.EntityTransform {
+	moving := make(map[EntityID]message.EntityTransform)
+	for i := range em.rooms {
+		if !owns(uint8(i)) {
+			continue
+		}
+
+		room := &em.rooms[i]
+		room.mu.RLock()
+		for id, entity := range room.entities {
+			if entity.Motion == nil {
+				continue
+			}
+			pos, velocity := entity.Transform.Position, entity.Motion.Velocity
+			moving[id] = message.EntityTransform{
+				EntityID: uint16(id),
+				X:        pos.X,
+				Y:        pos.Y,
+				Z:        pos.Z,
+				RotY:     entity.Transform.RotY,
+				VX:       velocity.X,
+				VY:       velocity.Y,
+				VZ:       velocity.Z,
+			}
+		}
+		room.mu.RUnlock()
+	}
+	return moving
+}
+
+// CollectProperties returns the changed properties of the entities in the rooms owns accepts,
+// see PropertySet.Collect
+func (em *EntityManager) CollectProperties(now time.Time, budget int, owns func(roomID uint8) bool) map[EntityID][]message.PropertyValue {
+	changed := make(map[EntityID][]message.PropertyValue)
+	for i := range em.rooms {
+		if !owns(uint8(i)) {
+			continue
+		}
+
+		room := &em.rooms[i]
+		room.mu.RLock()
+		for id, entity := range room.entities {
+			if values := entity.Properties.Collect(now, budget); len(values) > 0 {
+				changed[id] = values
+			}
+		}
+		room.mu.RUnlock()
+	}
+	return changed
+}
+
+// UpdateView brings the set of entities a player was told about in line with what they see
+// from their position in the room. Entities that came into view or were spawned are returned
+// as spawns, entities that left their view or were despawned as despawns.
+func (em *EntityManager) UpdateView(player *Player, roomID uint8) []EntityChange {
+	room := &em.rooms[roomID]
+	room.mu.RLock()
+	defer room.mu.RUnlock()
+
+	x, z := player.Position.X, player.Position.Z
+	var changes []EntityChange
+	for id, entity := range room.entities {
+		if _, known := player.KnownEntities[id]; known || !em.sees(entity, roomID, x, z) {
+			continue
+		}
+		player.KnownEntities[id] = struct{}{}
+		changes = append(changes, EntityChange{Observer: player.ID, Entity: *entity, Spawned: true})
+	}
+
+	// Entities of the player's previous rooms are not in this room's set and are despawned
+	for id := range player.KnownEntities {
+		if entity, exists := room.entities[id]; exists && em.sees(entity, roomID, x, z) {
+			continue
+		}
+		delete(player.KnownEntities, id)
+		changes = append(changes, EntityChange{Observer: player.ID, Entity: Entity{ID: id}, Spawned: false})
+	}
+	return changes
+}
+
+func (em *EntityManager) sees(entity *Entity, roomID uint8, x, z float32) bool {
+	if entity.RoomID != roomID {
+		return false
+	}
+	dx, dz := entity.Transform.Position.X-x, entity.Transform.Position.Z-z
+	return dx*dx+dz*dz <= em.radius*em.radius
+}
+
+// State returns every entity ordered by ID and the next ID to hand out, so the world can be saved
+func (em *EntityManager) State() ([]Entity, EntityID) {
+	entities := em.All()
+	sort.Slice(entities, func(i, j int) bool { return entities[i].ID < entities[j].ID })
+
+	em.idMu.Lock()
+	defer em.idMu.Unlock()
+	return entities, em.nextID
+}
+
+// Restore adds saved entities under their original IDs with default properties, and returns them
+// so the caller can set the saved property values
+func (em *EntityManager) Restore(entities []Entity, nextID EntityID) []Entity {
+	restored := make([]Entity, 0, len(entities))
+	for _, entity := range entities {
+		if entity.ID < FirstEntityID {
+			continue
+		}
+		entity.Properties = NewPropertySet(em.schemas[entity.Kind])
+
+		room := &em.rooms[entity.RoomID]
+		room.mu.Lock()
+		em.idMu.Lock()
+		em.ids[entity.ID] = entity.RoomID
+		em.idMu.Unlock()
+		room.entities[entity.ID] = &entity
+		room.mu.Unlock()
+
+		restored = append(restored, entity)
+	}
+
+	em.idMu.Lock()
+	defer em.idMu.Unlock()
+	if nextID >= FirstEntityID {
+		em.nextID = nextID
+	}
+	return restored
+}
@@ -0,0 +1,158 @@
+package game
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// PositionSample is where a player was at one server tick
+type PositionSample struct {
+	Time    time.Time
+	X, Y, Z float32
+	RotY    float32
+}
+
+// PositionHistory is a ring buffer of a player's recent positions, oldest overwritten first
+type PositionHistory struct {
+	samples []PositionSample
+	next    int
+	count   int
+	mu      sync.Mutex
+}
+
+// NewPositionHistory creates a history holding up to size samples
+func NewPositionHistory(size int) *PositionHistory {
+	if size < 2 {
+		size = 2
+	}
+	return &PositionHistory{samples: make([]PositionSample, size)}
+}
+
+// Record appends a sample; samples must be recorded in time order
+func (h *PositionHistory) Record(sample PositionSample) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.samples[h.next] = sample
+	h.next = (h.next + 1) % len(h.samples)
+	h.count = min(h.count+1, len(h.samples))
+}
+
+// At returns the position at time t, interpolated between the samples around it.
+// Times outside the recorded range are clamped to the oldest or newest sample.
+func (h *PositionHistory) At(t time.Time) (PositionSample, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.count == 0 {
+		return PositionSample{}, false
+	}
+
+	// Walk from the newest sample back to the first one not after t
+	newer := h.sample(0)
+	if !t.Before(newer.Time) {
+		return newer, true
+	}
+	for i := 1; i < h.count; i++ {
+		older := h.sample(i)
+		if !t.Before(older.Time) {
+			return interpolateSample(older, newer, t), true
+		}
+		newer = older
+	}
+	return newer, true
+}
+
+// sample returns the i-th newest sample, 0 being the latest
+func (h *PositionHistory) sample(i int) PositionSample {
+	return h.samples[(h.next-1-i+2*len(h.samples))%len(h.samples)]
+}
+
+func interpolateSample(older, newer PositionSample, t time.Time) PositionSample {
+	span := newer.Time.Sub(older.Time)
+	if span <= 0 {
+		return newer
+	}
+	f := float32(t.Sub(older.Time)) / float32(span)
+
+	// Rotate the short way round
+	dRot := NormalizeAngle(newer.RotY - older.RotY)
+
+	return PositionSample{
+		Time: t,
+		X:    older.X + (newer.X-older.X)*f,
+		Y:    older.Y + (newer.Y-older.Y)*f,
+		Z:    older.Z + (newer.Z-older.Z)*f,
+		RotY: NormalizeAngle(older.RotY + dRot*f),
+	}
+}
+
+// LagCompensationConfig controls how far back the world can be rewound
+type LagCompensationConfig struct {
+	HistoryInterval    time.Duration // how often positions are recorded
+	InterpolationDelay time.Duration // how far behind the server clients render other players
+	MaxRewind          time.Duration // rewinds further back than this are clamped
+}
+
+// LagCompensator rewinds players to where a lagged client saw them
+type LagCompensator struct {
+	config LagCompensationConfig
+}
+
+// NewLagCompensator creates a lag compensator with the given limits
+func NewLagCompensator(config LagCompensationConfig) *LagCompensator {
+	return &LagCompensator{config: config}
+}
+
+// HistorySize is how many samples a history needs to cover the maximum rewind window
+func (lc *LagCompensator) HistorySize() int {
+	if lc.config.HistoryInterval <= 0 {
+		return 2
+	}
+	return int(math.Ceil(float64(lc.config.MaxRewind)/float64(lc.config.HistoryInterval))) + 2
+}
+
+// RewindTime is the moment a client with the given RTT saw when it acted at now:
+// half the round trip plus the interpolation delay ago, but no more than MaxRewind
+func (lc *LagCompensator) RewindTime(rtt time.Duration, now time.Time) time.Time {
+	rewind := min(max(rtt, 0)/2+lc.config.InterpolationDelay, lc.config.MaxRewind)
+	return now.Add(-rewind)
+}
+
+// RewoundPlayer is where another player was at the moment a lagged client saw it
+type RewoundPlayer struct {
+	ID       uint8
+	Position PositionSample
+}
+
+// Rewind returns where the requester saw every other player, going back by the requester's
+// server-measured RTT. The players themselves are left untouched, so readers of their live
+// positions never see rewound ones. Players without history are returned where they are now.
+// The caller has to keep position updates to the players out while Rewind reads them.
+func (lc *LagCompensator) Rewind(players []*Player, requester *Player, now time.Time) []RewoundPlayer {
+	at := lc.RewindTime(requester.Latency.RTT(), now)
+
+	rewound := make([]RewoundPlayer, 0, len(players))
+	for _, player := range players {
+		if player.ID == requester.ID {
+			continue
+		}
+
+		sample, ok := PositionSample{}, false
+		if player.History != nil {
+			sample, ok = player.History.At(at)
+		}
+		if !ok {
+			sample = PositionSample{
+				Time: now,
+				X:    player.Position.X,
+				Y:    player.Position.Y,
+				Z:    player.Position.Z,
+				RotY: player.Position.RotY,
+			}
+		}
+		rewound = append(rewound, RewoundPlayer{ID: player.ID, Position: sample})
+	}
+	return rewound
+}
@@ -0,0 +1,116 @@
+package game
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func approx(a, b float32) bool {
+	return math.Abs(float64(a-b)) < 1e-4
+}
+
+func TestRewindTime(t *testing.T) {
+	lc := NewLagCompensator(LagCompensationConfig{
+		InterpolationDelay: 100 * time.Millisecond,
+		MaxRewind:          time.Second,
+	})
+	now := time.Unix(100, 0)
+
+	tests := []struct {
+		name string
+		rtt  time.Duration
+		want time.Duration
+	}{
+		{"unmeasured RTT rewinds by the interpolation delay", 0, 100 * time.Millisecond},
+		{"half the round trip plus the interpolation delay", 200 * time.Millisecond, 200 * time.Millisecond},
+		{"clamped to MaxRewind", 5 * time.Second, time.Second},
+		{"negative RTT is treated as zero", -time.Second, 100 * time.Millisecond},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := now.Sub(lc.RewindTime(test.rtt, now)); got != test.want {
+				t.Errorf("rewound by %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPositionHistoryAt(t *testing.T) {
+	start := time.Unix(100, 0)
+	history := NewPositionHistory(3)
+	history.Record(PositionSample{Time: start, X: 0, RotY: 3})
+	history.Record(PositionSample{Time: start.Add(100 * time.Millisecond), X: 10, RotY: -3})
+	history.Record(PositionSample{Time: start.Add(200 * time.Millisecond), X: 20, Z: 10, RotY: -3})
+
+	tests := []struct {
+		name    string
+		at      time.Duration
+		wantX   float32
+		wantZ   float32
+		wantRot float32
+	}{
+		{"exact sample", 100 * time.Millisecond, 10, 0, -3},
+		{"interpolated between samples", 150 * time.Millisecond, 15, 5, -3},
+		{"rotation interpolates the short way round", 50 * time.Millisecond, 5, 0, NormalizeAngle(math.Pi)},
+		{"before the oldest sample is clamped", -time.Second, 0, 0, 3},
+		{"after the newest sample is clamped", time.Second, 20, 10, -3},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := history.At(start.Add(test.at))
+			if !ok {
+				t.Fatal("no sample")
+			}
+			if !approx(got.X, test.wantX) || !approx(got.Z, test.wantZ) || !approx(got.RotY, test.wantRot) {
+				t.Errorf("got (%.3f, %.3f, rot %.3f), want (%.3f, %.3f, rot %.3f)",
+					got.X, got.Z, got.RotY, test.wantX, test.wantZ, test.wantRot)
+			}
+		})
+	}
+
+	// A fourth sample overwrites the oldest, which moves the clamp
+	history.Record(PositionSample{Time: start.Add(300 * time.Millisecond), X: 30})
+	if got, _ := history.At(start); !approx(got.X, 10) {
+		t.Errorf("after overwrite, oldest X = %.3f, want 10", got.X)
+	}
+
+	if _, ok := NewPositionHistory(4).At(start); ok {
+		t.Error("empty history returned a sample")
+	}
+}
+
+func TestRewindLeavesPlayersUntouched(t *testing.T) {
+	lc := NewLagCompensator(LagCompensationConfig{
+		HistoryInterval:    50 * time.Millisecond,
+		InterpolationDelay: 0,
+		MaxRewind:          time.Second,
+	})
+	now := time.Unix(100, 0)
+
+	requester := NewPlayer(1, nil, 0)
+	requester.Latency.SetRTT(200 * time.Millisecond)
+
+	target := NewPlayer(2, nil, 0)
+	target.History = NewPositionHistory(lc.HistorySize())
+	target.History.Record(PositionSample{Time: now.Add(-200 * time.Millisecond), X: 0})
+	target.History.Record(PositionSample{Time: now, X: 20})
+	target.Position.X = 20
+
+	untracked := NewPlayer(3, nil, 0)
+	untracked.Position.X = 7
+
+	rewound := lc.Rewind([]*Player{requester, target, untracked}, requester, now)
+	if len(rewound) != 2 {
+		t.Fatalf("got %d players, want 2 (the requester is left out)", len(rewound))
+	}
+	if rewound[0].ID != 2 || !approx(rewound[0].Position.X, 10) {
+		t.Errorf("target rewound to %+v, want X 10", rewound[0])
+	}
+	if rewound[1].ID != 3 || !approx(rewound[1].Position.X, 7) {
+		t.Errorf("player without history at %+v, want its current X 7", rewound[1])
+	}
+	if target.Position.X != 20 {
+		t.Errorf("live position changed to %.3f", target.Position.X)
+	}
+}
@@ -0,0 +1,147 @@
+package game
+
+import (
+	"math"
+	"server/internal/message"
+	"time"
+)
+
+// InputState is the authoritative input processing state of one player
+type InputState struct {
+	LastSequence uint32 // last input frame applied, 0 before the first
+	budget       time.Duration
+	lastAt       time.Time
+}
+
+// Mover runs the authoritative movement step on client input
+type Mover struct {
+	config MovementConfig
+	level  *Level // nil moves players without collision
+}
+
+// NewMover creates a mover using the speeds and bounds of the movement config
+func NewMover(config MovementConfig) *Mover {
+	return &Mover{config: config}
+}
+
+// SetLevel makes the movement step collide with the level geometry
+func (m *Mover) SetLevel(level *Level) {
+	m.level = level
+}
+
+// spawn returns where players without a position start
+func (m *Mover) spawn() Vec3 {
+	if m.level != nil && m.level.Spawn != nil {
+		return *m.level.Spawn
+	}
+	return m.config.Spawn
+}
+
+// Apply runs the frames that have not been processed yet, in sequence order.
+// Simulated time is limited by a budget that refills with wall-clock time, so inflating
+// Delta or sending extra frames cannot make a player move faster.
+// It returns the new position and whether any frame was applied.
+func (m *Mover) Apply(player *Player, frames []message.InputFrame, now time.Time) (message.PositionDataRTT, bool) {
+	state := &player.Input
+	if state.lastAt.IsZero() {
+		state.budget = m.config.MaxInputBudget
+	} else {
+		state.budget = min(state.budget+now.Sub(state.lastAt), m.config.MaxInputBudget)
+	}
+	state.lastAt = now
+
+	pos := player.Position
+	if !player.HasPosition {
+		spawn := m.spawn()
+		pos.X, pos.Y, pos.Z = spawn.X, spawn.Y, spawn.Z
+	}
+
+	applied := false
+	for _, frame := range frames {
+		if state.LastSequence != 0 && int32(frame.Sequence-state.LastSequence) <= 0 {
+			continue
+		}
+
+		var delta time.Duration
+		if finite(frame.Delta) && frame.Delta > 0 {
+			delta = time.Duration(min(float64(frame.Delta), state.budget.Seconds()) * float64(time.Second))
+		}
+		state.budget -= delta
+
+		pos = m.Step(pos, frame, delta)
+		state.LastSequence = frame.Sequence
+		applied = true
+	}
+	return pos, applied
+}
+
+// Step advances a position by one input frame held for delta
+func (m *Mover) Step(pos message.PositionDataRTT, frame message.InputFrame, delta time.Duration) message.PositionDataRTT {
+	moveX, moveZ := frame.MoveX, frame.MoveZ
+	if !finite(moveX) || !finite(moveZ) {
+		moveX, moveZ = 0, 0
+	}
+	if length := float32(math.Hypot(float64(moveX), float64(moveZ))); length > 1 {
+		moveX, moveZ = moveX/length, moveZ/length
+	}
+
+	seconds := float32(delta.Seconds())
+	distance := m.config.MaxSpeed * seconds
+	center := Vec3{pos.X, pos.Y, pos.Z}
+	move := Vec3{X: moveX * distance, Z: moveZ * distance}
+
+	if m.level == nil {
+		center = center.Add(move)
+	} else {
+		center = m.moveAndSlide(center, move, m.config.MaxVerticalSpeed*seconds)
+	}
+	pos.X, pos.Y, pos.Z = m.config.Bounds.Clamp(center.X, center.Y, center.Z)
+
+	if finite(frame.RotY) {
+		pos.RotY = NormalizeAngle(frame.RotY)
+	}
+	return pos
+}
+
+// moveAndSlide moves the capsule through the level in steps no longer than its radius so it
+// cannot tunnel, sliding along walls, climbing ledges up to StepHeight and falling at most fall
+func (m *Mover) moveAndSlide(center, move Vec3, fall float32) Vec3 {
+	capsule := m.config.Capsule
+	stepLength := max(capsule.Radius, 0.05)
+	steps := max(int(math.Ceil(float64(max(move.Length(), fall)/stepLength))), 1)
+
+	move = move.Scale(1 / float32(steps))
+	fall /= float32(steps)
+	for i := 0; i < steps; i++ {
+		center = m.slide(center, move, capsule)
+
+		// Follow the ground down, or fall when there is none
+		center = m.level.Resolve(m.level.SweepDown(center, fall, capsule), capsule)
+	}
+	return center
+}
+
+// slide makes one horizontal move, retrying from StepHeight higher when a ledge blocked it
+func (m *Mover) slide(center, move Vec3, capsule Capsule) Vec3 {
+	moved := m.level.ResolveWalls(center.Add(move), capsule)
+
+	blocked := horizontalDistance(moved, center) < move.Length()-collisionSkin
+	if !blocked || m.config.StepHeight <= 0 {
+		return moved
+	}
+
+	raised := center.Add(Vec3{Y: m.config.StepHeight})
+	if m.level.Collides(raised, capsule) {
+		return moved
+	}
+
+	stepped := m.level.ResolveWalls(raised.Add(move), capsule)
+	stepped = m.level.SweepDown(stepped, m.config.StepHeight, capsule)
+	if !m.level.Grounded(stepped, capsule) {
+		return moved
+	}
+	if horizontalDistance(stepped, center) > horizontalDistance(moved, center)+collisionSkin {
+		return stepped
+	}
+	return moved
+}
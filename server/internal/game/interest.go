@@ -0,0 +1,208 @@
+package game
+
+import (
+	"math"
+	"sync"
+)
+
+// cellKey identifies a single cell of the spatial grid
+type cellKey struct {
+	X, Z int32
+}
+
+// SpatialGrid buckets players into uniform cells over the X/Z plane
+type SpatialGrid struct {
+	cellSize  float32
+	cells     map[cellKey]map[uint8]struct{}
+	locations map[uint8]cellKey
+	positions map[uint8][2]float32
+}
+
+// NewSpatialGrid creates a grid with the given cell size
+func NewSpatialGrid(cellSize float32) *SpatialGrid {
+	if cellSize <= 0 {
+		cellSize = 1
+	}
+	return &SpatialGrid{
+		cellSize:  cellSize,
+		cells:     make(map[cellKey]map[uint8]struct{}),
+		locations: make(map[uint8]cellKey),
+		positions: make(map[uint8][2]float32),
+	}
+}
+
+func (g *SpatialGrid) keyFor(x, z float32) cellKey {
+	return cellKey{
+		X: int32(math.Floor(float64(x / g.cellSize))),
+		Z: int32(math.Floor(float64(z / g.cellSize))),
+	}
+}
+
+// Update inserts or moves a player to the given X/Z position
+func (g *SpatialGrid) Update(id uint8, x, z float32) {
+	key := g.keyFor(x, z)
+	g.positions[id] = [2]float32{x, z}
+
+	if old, exists := g.locations[id]; exists {
+		if old == key {
+			return
+		}
+		g.removeFromCell(id, old)
+	}
+
+	cell, exists := g.cells[key]
+	if !exists {
+		cell = make(map[uint8]struct{})
+		g.cells[key] = cell
+	}
+	cell[id] = struct{}{}
+	g.locations[id] = key
+}
+
+// Remove deletes a player from the grid
+func (g *SpatialGrid) Remove(id uint8) {
+	if key, exists := g.locations[id]; exists {
+		g.removeFromCell(id, key)
+	}
+	delete(g.locations, id)
+	delete(g.positions, id)
+}
+
+func (g *SpatialGrid) removeFromCell(id uint8, key cellKey) {
+	cell := g.cells[key]
+	delete(cell, id)
+	if len(cell) == 0 {
+		delete(g.cells, key)
+	}
+}
+
+// QueryRadius returns all players within radius of the given point
+func (g *SpatialGrid) QueryRadius(x, z, radius float32) []uint8 {
+	min := g.keyFor(x-radius, z-radius)
+	max := g.keyFor(x+radius, z+radius)
+	radiusSq := radius * radius
+
+	var result []uint8
+	for cx := min.X; cx <= max.X; cx++ {
+		for cz := min.Z; cz <= max.Z; cz++ {
+			for id := range g.cells[cellKey{X: cx, Z: cz}] {
+				pos := g.positions[id]
+				dx, dz := pos[0]-x, pos[1]-z
+				if dx*dx+dz*dz <= radiusSq {
+					result = append(result, id)
+				}
+			}
+		}
+	}
+	return result
+}
+
+// InterestChange describes a subject entering or leaving an observer's area of interest
+type InterestChange struct {
+	Observer uint8
+	Subject  uint8
+	Entered  bool
+}
+
+// InterestManager tracks which players can see each other.
+// Visibility is symmetric: A sees B exactly when B sees A.
+type InterestManager struct {
+	grid    *SpatialGrid
+	radius  float32
+	visible map[uint8]map[uint8]struct{}
+	mu      sync.Mutex
+}
+
+// NewInterestManager creates an interest manager with the given view radius and grid cell size
+func NewInterestManager(radius, cellSize float32) *InterestManager {
+	return &InterestManager{
+		grid:    NewSpatialGrid(cellSize),
+		radius:  radius,
+		visible: make(map[uint8]map[uint8]struct{}),
+	}
+}
+
+// Move updates a player's position and returns the resulting enter/leave events
+func (im *InterestManager) Move(id uint8, x, z float32) []InterestChange {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	im.grid.Update(id, x, z)
+
+	current := make(map[uint8]struct{})
+	for _, other := range im.grid.QueryRadius(x, z, im.radius) {
+		if other != id {
+			current[other] = struct{}{}
+		}
+	}
+
+	previous := im.visible[id]
+	var changes []InterestChange
+
+	for other := range current {
+		if _, seen := previous[other]; !seen {
+			im.link(id, other)
+			changes = append(changes,
+				InterestChange{Observer: id, Subject: other, Entered: true},
+				InterestChange{Observer: other, Subject: id, Entered: true})
+		}
+	}
+
+	for other := range previous {
+		if _, still := current[other]; !still {
+			im.unlink(id, other)
+			changes = append(changes,
+				InterestChange{Observer: id, Subject: other, Entered: false},
+				InterestChange{Observer: other, Subject: id, Entered: false})
+		}
+	}
+
+	return changes
+}
+
+// Remove drops a player and returns leave events for everyone who could see them
+func (im *InterestManager) Remove(id uint8) []InterestChange {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	var changes []InterestChange
+	for other := range im.visible[id] {
+		im.unlink(id, other)
+		changes = append(changes, InterestChange{Observer: other, Subject: id, Entered: false})
+	}
+
+	delete(im.visible, id)
+	im.grid.Remove(id)
+	return changes
+}
+
+// Visible returns the players currently inside the given player's area of interest
+func (im *InterestManager) Visible(id uint8) []uint8 {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	ids := make([]uint8, 0, len(im.visible[id]))
+	for other := range im.visible[id] {
+		ids = append(ids, other)
+	}
+	return ids
+}
+
+func (im *InterestManager) link(a, b uint8) {
+	im.visibleSet(a)[b] = struct{}{}
+	im.visibleSet(b)[a] = struct{}{}
+}
+
+func (im *InterestManager) unlink(a, b uint8) {
+	delete(im.visible[a], b)
+	delete(im.visible[b], a)
+}
+
+func (im *InterestManager) visibleSet(id uint8) map[uint8]struct{} {
+	set, exists := im.visible[id]
+	if !exists {
+		set = make(map[uint8]struct{})
+		im.visible[id] = set
+	}
+	return set
+}
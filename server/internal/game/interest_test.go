@@ -0,0 +1,99 @@
+package game
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// hasChange reports whether changes contain the given event
+func hasChange(changes []InterestChange, observer, subject uint8, entered bool) bool {
+	for _, change := range changes {
+		if change == (InterestChange{Observer: observer, Subject: subject, Entered: entered}) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestInterestChangesAcrossCells(t *testing.T) {
+	im := NewInterestManager(10, 5)
+	im.Move(1, 0, 0)
+	if changes := im.Move(2, 12, 0); len(changes) != 0 {
+		t.Fatalf("joining out of range: %v", changes)
+	}
+
+	tests := []struct {
+		name    string
+		x, z    float32
+		entered bool
+		changed bool
+	}{
+		{"into range from another cell", 9, 0, true, true},
+		{"within the cell", 9.5, 0.5, false, false},
+		{"across several cells, still in range", -7, -7, false, false},
+		{"across a negative cell boundary", -0.1, 0.1, false, false},
+		{"out of range", -11, 0, false, true},
+		{"back out of range in another cell", -20, 20, false, false},
+		{"diagonally into range", 6, 7, true, true},
+	}
+	for _, test := range tests {
+		changes := im.Move(2, test.x, test.z)
+		if !test.changed {
+			if len(changes) != 0 {
+				t.Errorf("%s: got %v, want no changes", test.name, changes)
+			}
+			continue
+		}
+		if len(changes) != 2 || !hasChange(changes, 1, 2, test.entered) || !hasChange(changes, 2, 1, test.entered) {
+			t.Errorf("%s: got %v, want entered=%v for both players", test.name, changes, test.entered)
+		}
+	}
+
+	changes := im.Remove(2)
+	if len(changes) != 1 || !hasChange(changes, 1, 2, false) {
+		t.Errorf("remove: got %v, want 2 leaving 1's view", changes)
+	}
+	if visible := im.Visible(1); len(visible) != 0 {
+		t.Errorf("1 still sees %v after 2 was removed", visible)
+	}
+}
+
+func TestInterestVisibilityIsSymmetric(t *testing.T) {
+	const players, radius = 20, 10
+	im := NewInterestManager(radius, 4)
+	rng := rand.New(rand.NewSource(1))
+	positions := make(map[uint8][2]float32)
+
+	for step := 0; step < 2000; step++ {
+		id := uint8(rng.Intn(players) + 1)
+		if step%97 == 0 {
+			im.Remove(id)
+			delete(positions, id)
+			continue
+		}
+		x, z := rng.Float32()*80-40, rng.Float32()*80-40
+		im.Move(id, x, z)
+		positions[id] = [2]float32{x, z}
+
+		visible := make(map[[2]uint8]bool)
+		for a := range positions {
+			for _, b := range im.Visible(a) {
+				visible[[2]uint8{a, b}] = true
+			}
+		}
+		for a, pa := range positions {
+			for b, pb := range positions {
+				if a == b {
+					continue
+				}
+				if visible[[2]uint8{a, b}] != visible[[2]uint8{b, a}] {
+					t.Fatalf("step %d: %d sees %d is %v, but the reverse is not", step, a, b, visible[[2]uint8{a, b}])
+				}
+				dx, dz := pa[0]-pb[0], pa[1]-pb[1]
+				if want := dx*dx+dz*dz <= radius*radius; visible[[2]uint8{a, b}] != want {
+					t.Fatalf("step %d: %d sees %d is %v, want %v", step, a, b, !want, want)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package game
+
+import (
+	"sync"
+	"time"
+)
+
+// LatencyProbe measures a player's round-trip time from pings the server sends and the client echoes.
+// Only the echo of the outstanding ping is timed, so a client can delay its answers to look slower
+// but cannot make its round-trip time look shorter than it is. It has its own lock, so pings, echoes
+// and readers of the round-trip time need no other.
+type LatencyProbe struct {
+	sequence uint32
+	sentAt   time.Time
+	pending  bool
+	smoothed time.Duration
+	measured bool
+	mu       sync.Mutex
+}
+
+// Ping starts a new measurement and returns the sequence the client must echo.
+// An unanswered previous ping is abandoned.
+func (lp *LatencyProbe) Ping(now time.Time) uint32 {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+
+	lp.sequence++
+	lp.sentAt = now
+	lp.pending = true
+	return lp.sequence
+}
+
+// Pong completes the measurement of the echoed sequence and returns the smoothed round-trip time.
+// Echoes of old or unknown pings are ignored.
+func (lp *LatencyProbe) Pong(sequence uint32, now time.Time) (time.Duration, bool) {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+
+	if !lp.pending || sequence != lp.sequence {
+		return 0, false
+	}
+	lp.pending = false
+
+	sample := now.Sub(lp.sentAt)
+	if !lp.measured {
+		lp.smoothed = sample
+		lp.measured = true
+	} else {
+		// Same smoothing factor as TCP's SRTT
+		lp.smoothed += (sample - lp.smoothed) / 8
+	}
+	return lp.smoothed, true
+}
+
+// RTT returns the smoothed round-trip time, zero until the first echo
+func (lp *LatencyProbe) RTT() time.Duration {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+
+	return lp.smoothed
+}
+
+// SetRTT continues from a known round-trip time, such as a restored player's
+func (lp *LatencyProbe) SetRTT(rtt time.Duration) {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+
+	lp.smoothed = rtt
+	lp.measured = rtt > 0
+}
@@ -0,0 +1,146 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+)
+
+// Box is a solid axis-aligned box
+type Box struct {
+	Name string `json:"name,omitempty"`
+	Min  Vec3   `json:"min"`
+	Max  Vec3   `json:"max"`
+}
+
+// Plane is a solid half-space: every point p with Normal·p < Distance is inside it
+type Plane struct {
+	Normal   Vec3    `json:"normal"`
+	Distance float32 `json:"distance"`
+}
+
+// Heightmap is terrain solid below a grid of heights. Rows run along Z and columns along X,
+// starting at Origin and CellSize apart.
+type Heightmap struct {
+	Origin   Vec3        `json:"origin"` // Y is added to every height
+	CellSize float32     `json:"cellSize"`
+	Heights  [][]float32 `json:"heights"`
+}
+
+// HeightAt returns the interpolated terrain height at (x, z) and whether the point is on the heightmap
+func (h Heightmap) HeightAt(x, z float32) (float32, bool) {
+	col := (x - h.Origin.X) / h.CellSize
+	row := (z - h.Origin.Z) / h.CellSize
+	rows, cols := len(h.Heights), len(h.Heights[0])
+	if col < 0 || row < 0 || col > float32(cols-1) || row > float32(rows-1) {
+		return 0, false
+	}
+
+	c0, r0 := min(int(col), cols-2), min(int(row), rows-2)
+	fx, fz := col-float32(c0), row-float32(r0)
+
+	top := h.Heights[r0][c0]*(1-fx) + h.Heights[r0][c0+1]*fx
+	bottom := h.Heights[r0+1][c0]*(1-fx) + h.Heights[r0+1][c0+1]*fx
+	return h.Origin.Y + top*(1-fz) + bottom*fz, true
+}
+
+// Level is the static world geometry players collide with
+type Level struct {
+	Name       string      `json:"name"`
+	Spawn      *Vec3       `json:"spawn,omitempty"` // overrides the configured spawn when set
+	Boxes      []Box       `json:"boxes"`
+	Planes     []Plane     `json:"planes"`
+	Heightmaps []Heightmap `json:"heightmaps"`
+}
+
+// LoadLevel reads and validates a level file
+func LoadLevel(path string) (*Level, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var level Level
+	if err := json.Unmarshal(data, &level); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if err := level.validate(); err != nil {
+		return nil, fmt.Errorf("invalid level %s: %w", path, err)
+	}
+	return &level, nil
+}
+
+// validate checks the geometry and normalizes plane normals
+func (l *Level) validate() error {
+	for _, box := range l.Boxes {
+		if box.Min.X > box.Max.X || box.Min.Y > box.Max.Y || box.Min.Z > box.Max.Z {
+			return fmt.Errorf("box %q has min greater than max", box.Name)
+		}
+	}
+
+	for i := range l.Planes {
+		length := l.Planes[i].Normal.Length()
+		if length == 0 {
+			return fmt.Errorf("plane %d has a zero normal", i)
+		}
+		l.Planes[i].Normal = l.Planes[i].Normal.Scale(1 / length)
+		l.Planes[i].Distance /= length
+	}
+
+	for i, h := range l.Heightmaps {
+		if h.CellSize <= 0 {
+			return fmt.Errorf("heightmap %d has a non-positive cell size", i)
+		}
+		if len(h.Heights) < 2 || len(h.Heights[0]) < 2 {
+			return fmt.Errorf("heightmap %d needs at least 2x2 heights", i)
+		}
+		for _, row := range h.Heights {
+			if len(row) != len(h.Heights[0]) {
+				return errors.New("heightmap rows must all have the same length")
+			}
+		}
+	}
+	return nil
+}
+
+// MarshalJSON encodes the vector as [x, y, z]
+func (v Vec3) MarshalJSON() ([]byte, error) {
+	return json.Marshal([3]float32{v.X, v.Y, v.Z})
+}
+
+// UnmarshalJSON decodes the vector from [x, y, z]
+func (v *Vec3) UnmarshalJSON(data []byte) error {
+	var xyz [3]float32
+	if err := json.Unmarshal(data, &xyz); err != nil {
+		return err
+	}
+	v.X, v.Y, v.Z = xyz[0], xyz[1], xyz[2]
+	return nil
+}
+
+// Add returns v + o
+func (v Vec3) Add(o Vec3) Vec3 {
+	return Vec3{v.X + o.X, v.Y + o.Y, v.Z + o.Z}
+}
+
+// Sub returns v - o
+func (v Vec3) Sub(o Vec3) Vec3 {
+	return Vec3{v.X - o.X, v.Y - o.Y, v.Z - o.Z}
+}
+
+// Scale returns v * s
+func (v Vec3) Scale(s float32) Vec3 {
+	return Vec3{v.X * s, v.Y * s, v.Z * s}
+}
+
+// Dot returns the dot product of v and o
+func (v Vec3) Dot(o Vec3) float32 {
+	return v.X*o.X + v.Y*o.Y + v.Z*o.Z
+}
+
+// Length returns the length of v
+func (v Vec3) Length() float32 {
+	return float32(math.Sqrt(float64(v.Dot(v))))
+}
@@ -0,0 +1,187 @@
+package game
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// MatchTicket is a player waiting in the matchmaking queue
+type MatchTicket struct {
+	UserID     uint8
+	RTT        time.Duration
+	Skill      int
+	HasSkill   bool
+	EnqueuedAt time.Time
+}
+
+// Match is a group of players the matchmaker decided to put in one room
+type Match struct {
+	Players []uint8
+}
+
+// MatchmakerConfig controls how waiting players are grouped
+type MatchmakerConfig struct {
+	MatchSize      int
+	MaxRTTSpread   time.Duration // allowed RTT difference to the oldest ticket
+	MaxSkillSpread int           // allowed skill difference to the oldest ticket
+	RelaxInterval  time.Duration // both spreads grow by their base value after each interval of waiting
+}
+
+// Matchmaker groups queued players into matches by size, RTT and skill
+type Matchmaker struct {
+	config MatchmakerConfig
+	queue  []MatchTicket
+	mu     sync.Mutex
+}
+
+// NewMatchmaker creates an empty matchmaking queue
+func NewMatchmaker(config MatchmakerConfig) *Matchmaker {
+	if config.MatchSize < 2 {
+		config.MatchSize = 2
+	}
+	return &Matchmaker{config: config}
+}
+
+// Enqueue adds a ticket to the queue, replacing any ticket the player already had
+func (mm *Matchmaker) Enqueue(ticket MatchTicket) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	mm.removeLocked(ticket.UserID)
+	mm.queue = append(mm.queue, ticket)
+}
+
+// Cancel removes a player's ticket and reports whether one was queued
+func (mm *Matchmaker) Cancel(userID uint8) bool {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	return mm.removeLocked(userID)
+}
+
+// Prune drops tickets of players for which keep returns false
+func (mm *Matchmaker) Prune(keep func(userID uint8) bool) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	kept := mm.queue[:0]
+	for _, ticket := range mm.queue {
+		if keep(ticket.UserID) {
+			kept = append(kept, ticket)
+		}
+	}
+	mm.queue = kept
+}
+
+// QueueLength returns the number of waiting players
+func (mm *Matchmaker) QueueLength() int {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	return len(mm.queue)
+}
+
+// Process forms as many matches as possible from the current queue.
+// The oldest ticket anchors each match; its allowed spreads widen the longer it waits.
+func (mm *Matchmaker) Process(now time.Time) []Match {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	sort.SliceStable(mm.queue, func(i, j int) bool {
+		return mm.queue[i].EnqueuedAt.Before(mm.queue[j].EnqueuedAt)
+	})
+
+	var matches []Match
+	matched := make(map[uint8]bool)
+
+	for _, anchor := range mm.queue {
+		if matched[anchor.UserID] {
+			continue
+		}
+
+		rttSpread, skillSpread := mm.spreads(now.Sub(anchor.EnqueuedAt))
+
+		var candidates []MatchTicket
+		for _, other := range mm.queue {
+			if other.UserID == anchor.UserID || matched[other.UserID] {
+				continue
+			}
+			if absDuration(other.RTT-anchor.RTT) > rttSpread {
+				continue
+			}
+			if anchor.HasSkill && other.HasSkill && absInt(other.Skill-anchor.Skill) > skillSpread {
+				continue
+			}
+			candidates = append(candidates, other)
+		}
+
+		if len(candidates) < mm.config.MatchSize-1 {
+			continue
+		}
+
+		// Prefer the candidates closest to the anchor
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return ticketDistance(anchor, candidates[i]) < ticketDistance(anchor, candidates[j])
+		})
+
+		match := Match{Players: []uint8{anchor.UserID}}
+		matched[anchor.UserID] = true
+		for _, candidate := range candidates[:mm.config.MatchSize-1] {
+			match.Players = append(match.Players, candidate.UserID)
+			matched[candidate.UserID] = true
+		}
+		matches = append(matches, match)
+	}
+
+	remaining := mm.queue[:0]
+	for _, ticket := range mm.queue {
+		if !matched[ticket.UserID] {
+			remaining = append(remaining, ticket)
+		}
+	}
+	mm.queue = remaining
+
+	return matches
+}
+
+func (mm *Matchmaker) spreads(waited time.Duration) (time.Duration, int) {
+	factor := 1
+	if mm.config.RelaxInterval > 0 {
+		factor += int(waited / mm.config.RelaxInterval)
+	}
+	return mm.config.MaxRTTSpread * time.Duration(factor), mm.config.MaxSkillSpread * factor
+}
+
+func (mm *Matchmaker) removeLocked(userID uint8) bool {
+	for i, ticket := range mm.queue {
+		if ticket.UserID == userID {
+			mm.queue = append(mm.queue[:i], mm.queue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// ticketDistance scores how far apart two tickets are, treating 1ms of RTT like 1 skill point
+func ticketDistance(a, b MatchTicket) float64 {
+	distance := float64(absDuration(a.RTT-b.RTT)) / float64(time.Millisecond)
+	if a.HasSkill && b.HasSkill {
+		distance += float64(absInt(a.Skill - b.Skill))
+	}
+	return distance
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
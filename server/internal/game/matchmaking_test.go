@@ -0,0 +1,62 @@
+package game
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestMatchmakerQueueOrder(t *testing.T) {
+	start := time.Now()
+	mm := NewMatchmaker(MatchmakerConfig{MatchSize: 2, MaxRTTSpread: 20 * time.Millisecond})
+
+	// Queued out of order: the oldest ticket anchors the first match and picks the closest RTT
+	mm.Enqueue(MatchTicket{UserID: 1, RTT: 50 * time.Millisecond, EnqueuedAt: start.Add(2 * time.Second)})
+	mm.Enqueue(MatchTicket{UserID: 2, RTT: 40 * time.Millisecond, EnqueuedAt: start})
+	mm.Enqueue(MatchTicket{UserID: 3, RTT: 45 * time.Millisecond, EnqueuedAt: start.Add(time.Second)})
+	mm.Enqueue(MatchTicket{UserID: 4, RTT: 200 * time.Millisecond, EnqueuedAt: start.Add(3 * time.Second)})
+
+	matches := mm.Process(start.Add(3 * time.Second))
+	if len(matches) != 1 || !slices.Equal(matches[0].Players, []uint8{2, 3}) {
+		t.Fatalf("matches %v, want [2 3]", matches)
+	}
+	if mm.QueueLength() != 2 {
+		t.Errorf("%d tickets left, want 1 and 4", mm.QueueLength())
+	}
+
+	// Enqueueing again replaces the ticket, here with one close enough to match 1
+	mm.Enqueue(MatchTicket{UserID: 4, RTT: 60 * time.Millisecond, EnqueuedAt: start.Add(4 * time.Second)})
+	if mm.QueueLength() != 2 {
+		t.Errorf("re-enqueued ticket was added twice: %d tickets", mm.QueueLength())
+	}
+	matches = mm.Process(start.Add(4 * time.Second))
+	if len(matches) != 1 || !slices.Equal(matches[0].Players, []uint8{1, 4}) {
+		t.Fatalf("matches %v, want [1 4]", matches)
+	}
+
+	mm.Enqueue(MatchTicket{UserID: 5, EnqueuedAt: start})
+	if !mm.Cancel(5) || mm.Cancel(5) || mm.QueueLength() != 0 {
+		t.Error("Cancel did not remove the ticket exactly once")
+	}
+}
+
+func TestMatchmakerRelaxesSpreads(t *testing.T) {
+	start := time.Now()
+	mm := NewMatchmaker(MatchmakerConfig{
+		MatchSize:      3,
+		MaxRTTSpread:   10 * time.Millisecond,
+		MaxSkillSpread: 100,
+		RelaxInterval:  10 * time.Second,
+	})
+	mm.Enqueue(MatchTicket{UserID: 1, RTT: 50 * time.Millisecond, Skill: 1000, HasSkill: true, EnqueuedAt: start})
+	mm.Enqueue(MatchTicket{UserID: 2, RTT: 55 * time.Millisecond, Skill: 1050, HasSkill: true, EnqueuedAt: start})
+	mm.Enqueue(MatchTicket{UserID: 3, RTT: 65 * time.Millisecond, Skill: 1180, HasSkill: true, EnqueuedAt: start})
+
+	if matches := mm.Process(start.Add(9 * time.Second)); len(matches) != 0 {
+		t.Fatalf("matched %v before the spreads relaxed", matches)
+	}
+	matches := mm.Process(start.Add(10 * time.Second))
+	if len(matches) != 1 || len(matches[0].Players) != 3 || mm.QueueLength() != 0 {
+		t.Errorf("after waiting an interval: %v, %d left, want all three matched", matches, mm.QueueLength())
+	}
+}
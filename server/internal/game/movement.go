@@ -0,0 +1,268 @@
+package game
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"server/internal/message"
+	"sync"
+	"time"
+)
+
+// Violation is a reason a position update was rejected
+type Violation uint8
+
+const (
+	ViolationNone    Violation = iota
+	ViolationInvalid           // NaN or infinite coordinates
+	ViolationBounds            // outside the world bounds
+	ViolationSpeed             // moved faster than allowed since the last update
+)
+
+// String returns the name of the violation
+func (v Violation) String() string {
+	return [...]string{"none", "invalid", "bounds", "speed"}[v]
+}
+
+// MarshalText encodes the violation by name
+func (v Violation) MarshalText() ([]byte, error) {
+	return []byte(v.String()), nil
+}
+
+// ValidationAction is what the server does about a violation
+type ValidationAction uint8
+
+const (
+	ActionCorrect ValidationAction = iota // apply a corrected position and tell the client
+	ActionIgnore                          // drop the update, the last accepted position stays
+	ActionFlag                            // accept the update as sent, only record it
+	ActionKick                            // disconnect the player
+)
+
+// String returns the name of the action
+func (a ValidationAction) String() string {
+	return [...]string{"correct", "ignore", "flag", "kick"}[a]
+}
+
+// MarshalText encodes the action by name
+func (a ValidationAction) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// Vec3 is a point or direction in world space
+type Vec3 struct {
+	X, Y, Z float32
+}
+
+// Bounds is an axis-aligned box players must stay within
+type Bounds struct {
+	MinX, MinY, MinZ float32
+	MaxX, MaxY, MaxZ float32
+}
+
+// Contains reports whether the point lies inside the box
+func (b Bounds) Contains(x, y, z float32) bool {
+	return x >= b.MinX && x <= b.MaxX && y >= b.MinY && y <= b.MaxY && z >= b.MinZ && z <= b.MaxZ
+}
+
+// Clamp moves the point to the nearest point inside the box
+func (b Bounds) Clamp(x, y, z float32) (float32, float32, float32) {
+	return min(max(x, b.MinX), b.MaxX), min(max(y, b.MinY), b.MaxY), min(max(z, b.MinZ), b.MaxZ)
+}
+
+// MovementConfig sets the limits position updates are checked against
+type MovementConfig struct {
+	MaxSpeed         float32       // horizontal units per second
+	MaxVerticalSpeed float32       // vertical units per second, covers jumping and falling
+	Tolerance        float32       // multiplier on both speeds for jitter and float error
+	MaxRTTAllowance  time.Duration // most travel time a player can save up for updates bunched up by latency, capped at its measured RTT
+	Bounds           Bounds
+	Spawn            Vec3                           // where authoritative movement starts
+	Capsule          Capsule                        // player shape for collision with the level
+	StepHeight       float32                        // tallest ledge a player walks up without jumping
+	MaxInputBudget   time.Duration                  // most simulated input time a player can save up
+	Actions          map[Violation]ValidationAction // violations not listed are corrected
+}
+
+// ValidationResult is the outcome of checking one position update
+type ValidationResult struct {
+	Position  message.PositionDataRTT // the position to apply when the action accepts one
+	Violation Violation
+	Action    ValidationAction
+	Detail    string
+}
+
+// ValidationState is what the validator remembers about a player between position updates
+type ValidationState struct {
+	reserve time.Duration // travel time saved up by moving slower than allowed, at most the RTT allowance
+}
+
+// MovementValidator checks client-reported positions against the movement rules
+type MovementValidator struct {
+	config MovementConfig
+}
+
+// NewMovementValidator creates a validator with the given limits
+func NewMovementValidator(config MovementConfig) *MovementValidator {
+	if config.Tolerance <= 0 {
+		config.Tolerance = 1
+	}
+	return &MovementValidator{config: config}
+}
+
+// Validate checks an update from player against its last accepted position.
+// RotY is always normalized to [-π, π); the other fields are only changed when correcting.
+// Updates delayed and then bunched up by latency may travel further than the time between them
+// allows, but only by time the player saved up earlier by moving slower than allowed. The saved
+// time refills with wall-clock time like the Mover's input budget and is capped at the player's
+// server-measured RTT, so sending updates faster never adds up to more than the speed limit.
+func (mv *MovementValidator) Validate(player *Player, pos message.PositionDataRTT, now time.Time) ValidationResult {
+	last := player.Position
+	pos.RotY = NormalizeAngle(pos.RotY)
+
+	if !finite(pos.X) || !finite(pos.Y) || !finite(pos.Z) || !finite(pos.RotY) {
+		corrected := pos
+		corrected.X, corrected.Y, corrected.Z, corrected.RotY = last.X, last.Y, last.Z, last.RotY
+		return mv.result(corrected, ViolationInvalid, "non-finite coordinates")
+	}
+
+	if !mv.config.Bounds.Contains(pos.X, pos.Y, pos.Z) {
+		corrected := pos
+		corrected.X, corrected.Y, corrected.Z = mv.config.Bounds.Clamp(pos.X, pos.Y, pos.Z)
+		return mv.result(corrected, ViolationBounds,
+			fmt.Sprintf("(%.2f, %.2f, %.2f) outside world bounds", pos.X, pos.Y, pos.Z))
+	}
+
+	// The first position after joining has nothing to be compared to
+	if !player.HasPosition {
+		return ValidationResult{Position: pos}
+	}
+
+	state := &player.Validation
+	allowance := min(player.Latency.RTT(), mv.config.MaxRTTAllowance)
+	elapsed := max(now.Sub(player.LastSeen), 0) + min(state.reserve, allowance)
+	seconds := float32(elapsed.Seconds())
+	horizontalSpeed := mv.config.MaxSpeed * mv.config.Tolerance
+	verticalSpeed := mv.config.MaxVerticalSpeed * mv.config.Tolerance
+	maxHorizontal := horizontalSpeed * seconds
+	maxVertical := verticalSpeed * seconds
+
+	dx, dy, dz := pos.X-last.X, pos.Y-last.Y, pos.Z-last.Z
+	horizontal := float32(math.Hypot(float64(dx), float64(dz)))
+	vertical := float32(math.Abs(float64(dy)))
+	if horizontal <= maxHorizontal && vertical <= maxVertical {
+		// Whatever time the move did not need is saved up for later updates
+		needed := max(travelTime(horizontal, horizontalSpeed), travelTime(vertical, verticalSpeed))
+		state.reserve = min(max(elapsed-needed, 0), allowance)
+		return ValidationResult{Position: pos}
+	}
+	state.reserve = 0
+
+	// Correct to as far as the player could have moved in the same direction
+	corrected := pos
+	if horizontal > maxHorizontal {
+		scale := maxHorizontal / horizontal
+		corrected.X, corrected.Z = last.X+dx*scale, last.Z+dz*scale
+	}
+	if vertical > maxVertical {
+		corrected.Y = last.Y + float32(math.Copysign(float64(maxVertical), float64(dy)))
+	}
+	return mv.result(corrected, ViolationSpeed,
+		fmt.Sprintf("moved %.2f horizontal, %.2f vertical in %v (max %.2f, %.2f)",
+			horizontal, vertical, elapsed.Round(time.Millisecond), maxHorizontal, maxVertical))
+}
+
+// travelTime returns how long covering distance takes at speed
+func travelTime(distance, speed float32) time.Duration {
+	if distance <= 0 {
+		return 0
+	}
+	if speed <= 0 {
+		return math.MaxInt64
+	}
+	return time.Duration(float64(distance/speed) * float64(time.Second))
+}
+
+func (mv *MovementValidator) result(corrected message.PositionDataRTT, violation Violation, detail string) ValidationResult {
+	return ValidationResult{
+		Position:  corrected,
+		Violation: violation,
+		Action:    mv.config.Actions[violation],
+		Detail:    detail,
+	}
+}
+
+// NormalizeAngle wraps an angle in radians into [-π, π)
+func NormalizeAngle(angle float32) float32 {
+	if !finite(angle) {
+		return angle
+	}
+	wrapped := math.Mod(float64(angle)+math.Pi, 2*math.Pi)
+	if wrapped < 0 {
+		wrapped += 2 * math.Pi
+	}
+	return float32(wrapped - math.Pi)
+}
+
+func finite(f float32) bool {
+	return !math.IsNaN(float64(f)) && !math.IsInf(float64(f), 0)
+}
+
+// AuditEntry records one violation and what was done about it
+type AuditEntry struct {
+	Time      time.Time
+	UserID    uint8
+	Violation Violation
+	Action    ValidationAction
+	Detail    string
+}
+
+// String formats the entry as one audit log line
+func (e AuditEntry) String() string {
+	return fmt.Sprintf("%s UserID=%d violation=%s action=%s %s",
+		e.Time.Format(time.RFC3339Nano), e.UserID, e.Violation, e.Action, e.Detail)
+}
+
+// AuditLog keeps the most recent violations in memory and writes every one to an optional writer
+type AuditLog struct {
+	entries []AuditEntry
+	next    int
+	full    bool
+	out     io.Writer // nil keeps entries in memory only
+	mu      sync.Mutex
+}
+
+// NewAuditLog creates an audit log remembering the last size entries
+func NewAuditLog(size int, out io.Writer) *AuditLog {
+	if size < 1 {
+		size = 1
+	}
+	return &AuditLog{entries: make([]AuditEntry, size), out: out}
+}
+
+// Record adds an entry to the log
+func (al *AuditLog) Record(entry AuditEntry) {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	al.entries[al.next] = entry
+	al.next = (al.next + 1) % len(al.entries)
+	if al.next == 0 {
+		al.full = true
+	}
+
+	if al.out != nil {
+		fmt.Fprintln(al.out, entry)
+	}
+}
+
+// Recent returns the remembered entries, oldest first
+func (al *AuditLog) Recent() []AuditEntry {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	if !al.full {
+		return append([]AuditEntry(nil), al.entries[:al.next]...)
+	}
+	return append(append([]AuditEntry(nil), al.entries[al.next:]...), al.entries[:al.next]...)
+}
@@ -0,0 +1,130 @@
+package game
+
+import (
+	"server/internal/message"
+	"testing"
+	"time"
+)
+
+func newValidationPlayer(rtt time.Duration, now time.Time) *Player {
+	player := NewPlayer(1, nil, 0)
+	player.HasPosition = true
+	player.LastSeen = now
+	player.Latency.SetRTT(rtt)
+	return player
+}
+
+func TestValidateRTTAllowanceIsABudget(t *testing.T) {
+	config := MovementConfig{
+		MaxSpeed:         10,
+		MaxVerticalSpeed: 10,
+		Tolerance:        1,
+		MaxRTTAllowance:  250 * time.Millisecond,
+		Bounds:           Bounds{-1000, -1000, -1000, 1000, 1000, 1000},
+	}
+	validator := NewMovementValidator(config)
+	start := time.Unix(0, 0)
+	interval := time.Second / 120
+
+	// Standing still for a while saves up the whole allowance
+	player := newValidationPlayer(time.Second, start)
+	now := start.Add(time.Second)
+	if result := validator.Validate(player, player.Position, now); result.Violation != ViolationNone {
+		t.Fatalf("standing still: got violation %v", result.Violation)
+	}
+	player.LastSeen = now
+
+	// A speed hack moving 1 unit every 1/120 s (120 u/s) only gets the saved-up allowance once
+	var travelled float32
+	corrected := 0
+	for i := 0; i < 120; i++ {
+		now = now.Add(interval)
+		pos := player.Position
+		pos.X += 1
+		result := validator.Validate(player, pos, now)
+		if result.Violation == ViolationSpeed {
+			corrected++
+		}
+		travelled += result.Position.X - player.Position.X
+		player.Position = result.Position
+		player.LastSeen = now
+	}
+
+	// One second at 10 u/s plus at most 250 ms of saved-up travel
+	limit := float32(10 * 1.25)
+	if travelled > limit+0.01 {
+		t.Errorf("travelled %.2f units in 1s, want at most %.2f", travelled, limit)
+	}
+	if corrected == 0 {
+		t.Error("speed hack was never corrected")
+	}
+}
+
+func TestValidateBunchedUpdates(t *testing.T) {
+	config := MovementConfig{
+		MaxSpeed:         10,
+		MaxVerticalSpeed: 10,
+		Tolerance:        1,
+		MaxRTTAllowance:  250 * time.Millisecond,
+		Bounds:           Bounds{-1000, -1000, -1000, 1000, 1000, 1000},
+	}
+	start := time.Unix(0, 0)
+
+	tests := []struct {
+		name    string
+		rtt     time.Duration
+		saved   time.Duration // time spent standing before the burst
+		move    float32       // units moved by the update arriving 10 ms after the last
+		allowed bool
+	}{
+		{"within the time since the last update", 100 * time.Millisecond, 0, 0.09, true},
+		{"late update covered by saved-up time", 100 * time.Millisecond, time.Second, 1, true},
+		{"saved-up time is capped at the RTT", 100 * time.Millisecond, time.Second, 2, false},
+		{"saved-up time is capped at the allowance", time.Second, time.Second, 3, false},
+		{"no allowance before the RTT is measured", 0, time.Second, 1, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			validator := NewMovementValidator(config)
+			player := newValidationPlayer(test.rtt, start)
+			now := start.Add(test.saved)
+			validator.Validate(player, player.Position, now)
+			player.LastSeen = now
+
+			pos := player.Position
+			pos.X += test.move
+			result := validator.Validate(player, pos, now.Add(10*time.Millisecond))
+			if allowed := result.Violation == ViolationNone; allowed != test.allowed {
+				t.Errorf("allowed = %v, want %v (%s)", allowed, test.allowed, result.Detail)
+			}
+		})
+	}
+}
+
+func TestValidateRejectsInvalidPositions(t *testing.T) {
+	validator := NewMovementValidator(MovementConfig{
+		MaxSpeed:         10,
+		MaxVerticalSpeed: 10,
+		Bounds:           Bounds{-10, -10, -10, 10, 10, 10},
+	})
+	player := newValidationPlayer(0, time.Unix(0, 0))
+
+	nan := float32(0)
+	nan = nan / nan
+	tests := []struct {
+		name string
+		pos  message.PositionDataRTT
+		want Violation
+	}{
+		{"non-finite", message.PositionDataRTT{X: nan}, ViolationInvalid},
+		{"out of bounds", message.PositionDataRTT{X: 11}, ViolationBounds},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := validator.Validate(player, test.pos, time.Unix(10, 0)).Violation; got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -8,11 +8,25 @@ import (
 
 // Player represents a connected game client
 type Player struct {
-	ID         uint8
-	Address    *net.UDPAddr
-	ListenPort int
-	LastSeen   time.Time
-	Position   message.PositionDataRTT
+	ID          uint8
+	Identity    string // stable across sessions, empty for anonymous players
+	Profile     Profile
+	Address     *net.UDPAddr
+	ListenPort  int
+	LastSeen    time.Time
+	Position    message.PositionDataRTT
+	HasPosition bool         // set once the server accepted a first position
+	Latency     LatencyProbe // Round-trip time measured by the server's pings
+	Snapshots   *SnapshotHistory
+	Input       InputState       // authoritative movement progress
+	Validation  ValidationState  // latency allowance left for client-reported positions
+	History     *PositionHistory // recent positions for lag compensation, nil until recorded
+
+	// KnownEntities are the entities the player was sent a spawn for and no despawn since
+	KnownEntities map[EntityID]struct{}
+	Priorities    *PriorityAccumulator // ranks snapshot entries over budget, nil sends them all
+	Reliable      *ReliableChannel     // ordered delivery of chat and other messages that must arrive
+	Properties    *PropertyQueue       // property updates not yet handed to Reliable
 }
 
 // NewPlayer creates a new player instance
@@ -25,12 +39,16 @@ func NewPlayer(id uint8, addr *net.UDPAddr, port int) *Player {
 		Position: message.PositionDataRTT{
 			UserID: id,
 		},
+		Snapshots:     NewSnapshotHistory(),
+		KnownEntities: make(map[EntityID]struct{}),
+		Properties:    NewPropertyQueue(),
 	}
 }
 
 // UpdatePosition updates the player's position and last seen time
 func (p *Player) UpdatePosition(pos message.PositionDataRTT) {
 	p.Position = pos
+	p.HasPosition = true
 	p.LastSeen = time.Now()
 }
 
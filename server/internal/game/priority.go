@@ -0,0 +1,92 @@
+package game
+
+import (
+	"math"
+	"server/internal/message"
+	"sort"
+	"sync"
+)
+
+// PriorityConfig controls how quickly pending entity updates gain priority
+type PriorityConfig struct {
+	Importance    map[EntityKind]float32 // per-tick priority gain by kind, 1 when missing
+	DistanceScale float32                // distance at which the gain halves; zero ignores distance
+}
+
+// PriorityAccumulator ranks a client's pending snapshot entries when they do not all fit its
+// bandwidth budget. Every snapshot an entry is left out, its entity gains priority by importance
+// and closeness, so even the least important entity is eventually sent.
+type PriorityAccumulator struct {
+	config   PriorityConfig
+	priority map[EntityID]float32
+	mu       sync.Mutex
+}
+
+// NewPriorityAccumulator creates an accumulator with no pending priority
+func NewPriorityAccumulator(config PriorityConfig) *PriorityAccumulator {
+	return &PriorityAccumulator{
+		config:   config,
+		priority: make(map[EntityID]float32),
+	}
+}
+
+// Select picks the entries to send within budget bytes, highest accumulated priority first.
+// Removals are cheap and always go first. At least one entry is sent so a small budget cannot
+// stall the snapshot stream. Entries left out keep their priority, sent ones start over.
+func (pa *PriorityAccumulator) Select(entries []message.SnapshotEntry, states map[EntityID]EntityState, viewerX, viewerZ float32, budget int) []message.SnapshotEntry {
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+
+	for _, entry := range entries {
+		id := EntityID(entry.EntityID)
+		if state, ok := states[id]; ok {
+			pa.priority[id] += pa.gain(state, viewerX, viewerZ)
+		}
+	}
+
+	ranked := append([]message.SnapshotEntry(nil), entries...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		iRemoved := ranked[i].Mask&message.SNAPSHOT_REMOVED != 0
+		jRemoved := ranked[j].Mask&message.SNAPSHOT_REMOVED != 0
+		if iRemoved != jRemoved {
+			return iRemoved
+		}
+		return pa.priority[EntityID(ranked[i].EntityID)] > pa.priority[EntityID(ranked[j].EntityID)]
+	})
+
+	var selected []message.SnapshotEntry
+	for _, entry := range ranked {
+		if entry.Size() > budget && len(selected) > 0 {
+			continue
+		}
+		budget -= entry.Size()
+		selected = append(selected, entry)
+		delete(pa.priority, EntityID(entry.EntityID))
+	}
+
+	// Entities that are no longer pending do not keep their priority
+	pending := make(map[EntityID]struct{}, len(entries))
+	for _, entry := range entries {
+		pending[EntityID(entry.EntityID)] = struct{}{}
+	}
+	for id := range pa.priority {
+		if _, ok := pending[id]; !ok {
+			delete(pa.priority, id)
+		}
+	}
+	return selected
+}
+
+// gain is how much priority an entity earns in one snapshot it is left out of
+func (pa *PriorityAccumulator) gain(state EntityState, viewerX, viewerZ float32) float32 {
+	importance, ok := pa.config.Importance[state.Kind]
+	if !ok {
+		importance = 1
+	}
+	if pa.config.DistanceScale <= 0 {
+		return importance
+	}
+
+	distance := float32(math.Hypot(float64(state.X-viewerX), float64(state.Z-viewerZ)))
+	return importance / (1 + distance/pa.config.DistanceScale)
+}
@@ -0,0 +1,59 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	ErrNameInvalid = errors.New("invalid player name")
+	ErrNameTaken   = errors.New("player name is taken")
+)
+
+// defaultNamePattern matches names of the DefaultName form, which players cannot choose
+var defaultNamePattern = regexp.MustCompile(`(?i)^player \d+$`)
+
+// Profile is how a player presents themselves to others
+type Profile struct {
+	Name  string
+	Color uint32 // RGBA8 tint for the player's model and label, 0 for the client's default
+	Skin  uint8  // model variant, interpreted by clients
+}
+
+// ProfileConfig limits the names players may choose
+type ProfileConfig struct {
+	MinNameLength int // in characters
+	MaxNameLength int // in characters, at most 255 bytes once encoded
+}
+
+// DefaultName is the name of a player that did not choose one
+func DefaultName(userID uint8) string {
+	return fmt.Sprintf("Player %d", userID)
+}
+
+// ValidateName checks a chosen name's length and characters. Letters, digits, single inner
+// spaces and _-. are allowed.
+func (c ProfileConfig) ValidateName(name string) error {
+	length := utf8.RuneCountInString(name)
+	switch {
+	case !utf8.ValidString(name) || len(name) > 255:
+		return fmt.Errorf("%w: not valid UTF-8 of at most 255 bytes", ErrNameInvalid)
+	case length < c.MinNameLength || length > c.MaxNameLength:
+		return fmt.Errorf("%w: must be %d to %d characters", ErrNameInvalid, c.MinNameLength, c.MaxNameLength)
+	case strings.TrimSpace(name) != name || strings.Contains(name, "  "):
+		return fmt.Errorf("%w: leading, trailing or repeated spaces", ErrNameInvalid)
+	case defaultNamePattern.MatchString(name):
+		return fmt.Errorf("%w: reserved", ErrNameInvalid)
+	}
+
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune(" _-.", r) {
+			return fmt.Errorf("%w: character %q is not allowed", ErrNameInvalid, r)
+		}
+	}
+	return nil
+}
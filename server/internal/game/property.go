@@ -0,0 +1,276 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"server/internal/message"
+	"sort"
+	"sync"
+	"time"
+)
+
+// PropertyDef declares a replicated property of an entity kind
+type PropertyDef struct {
+	ID       uint8
+	Name     string
+	Default  interface{}   // bool, uint8, int32, float32, Vec3 or string; fixes the property's type
+	Priority uint8         // higher priority properties are packed first when an update is full
+	Interval time.Duration // minimum time between updates of the property, zero sends every tick
+}
+
+// PropertySchema is the set of properties every entity of a kind has
+type PropertySchema []PropertyDef
+
+// DefaultPropertySchemas declares the properties of the built-in entity kinds
+func DefaultPropertySchemas() map[EntityKind]PropertySchema {
+	return map[EntityKind]PropertySchema{
+		EntityNPC: {
+			{ID: 0, Name: "health", Default: int32(100), Priority: 200},
+			{ID: 1, Name: "animation", Default: uint8(0), Priority: 100},
+			{ID: 2, Name: "name", Default: "", Priority: 50, Interval: time.Second},
+		},
+		EntityProjectile: {
+			{ID: 0, Name: "damage", Default: int32(10), Priority: 50, Interval: time.Second},
+		},
+		EntityPickup: {
+			{ID: 0, Name: "available", Default: true, Priority: 200},
+			{ID: 1, Name: "item", Default: uint8(0), Priority: 100},
+			{ID: 2, Name: "amount", Default: int32(1), Priority: 100},
+		},
+	}
+}
+
+// Validate checks that property IDs and names are unique and every default can be replicated
+func (s PropertySchema) Validate() error {
+	ids := make(map[uint8]bool)
+	names := make(map[string]bool)
+	for _, def := range s {
+		if ids[def.ID] || names[def.Name] {
+			return fmt.Errorf("property %d %q declared twice", def.ID, def.Name)
+		}
+		ids[def.ID], names[def.Name] = true, true
+
+		if _, ok := message.PropertyTypeOf(wireValue(def.Default)); !ok {
+			return fmt.Errorf("property %q has unsupported type %T", def.Name, def.Default)
+		}
+	}
+	return nil
+}
+
+// PropertySet holds an entity's property values and tracks which changed since they were last sent
+type PropertySet struct {
+	defs   []PropertyDef // by descending priority
+	values []interface{} // indexed like defs
+	dirty  []bool
+	sentAt []time.Time
+	mu     sync.Mutex
+}
+
+// NewPropertySet creates a property set with every property at its default
+func NewPropertySet(schema PropertySchema) *PropertySet {
+	defs := append(PropertySchema(nil), schema...)
+	sort.SliceStable(defs, func(i, j int) bool { return defs[i].Priority > defs[j].Priority })
+
+	ps := &PropertySet{
+		defs:   defs,
+		values: make([]interface{}, len(defs)),
+		dirty:  make([]bool, len(defs)),
+		sentAt: make([]time.Time, len(defs)),
+	}
+	for i, def := range defs {
+		ps.values[i] = def.Default
+	}
+	return ps
+}
+
+// Set changes a property to a value of its default's type. Setting the current value changes nothing.
+func (ps *PropertySet) Set(name string, value interface{}) error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	i, err := ps.index(name)
+	if err != nil {
+		return err
+	}
+	if reflect.TypeOf(value) != reflect.TypeOf(ps.defs[i].Default) {
+		return fmt.Errorf("property %q is a %T, not a %T", name, ps.defs[i].Default, value)
+	}
+	if text, ok := value.(string); ok && len(text) > 255 {
+		return fmt.Errorf("property %q is longer than 255 bytes", name)
+	}
+
+	if ps.values[i] != value {
+		ps.values[i] = value
+		ps.dirty[i] = true
+	}
+	return nil
+}
+
+// SetJSON decodes a JSON value into the property's type and sets it
+func (ps *PropertySet) SetJSON(name string, data []byte) error {
+	ps.mu.Lock()
+	i, err := ps.index(name)
+	if err != nil {
+		ps.mu.Unlock()
+		return err
+	}
+	value := reflect.New(reflect.TypeOf(ps.defs[i].Default))
+	ps.mu.Unlock()
+
+	if err := json.Unmarshal(data, value.Interface()); err != nil {
+		return fmt.Errorf("property %q: %w", name, err)
+	}
+	return ps.Set(name, value.Elem().Interface())
+}
+
+// Get returns a property's current value
+func (ps *PropertySet) Get(name string) (interface{}, bool) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	i, err := ps.index(name)
+	if err != nil {
+		return nil, false
+	}
+	return ps.values[i], true
+}
+
+// Values returns every property, highest priority first, for clients that have not seen the entity yet
+func (ps *PropertySet) Values() []message.PropertyValue {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	values := make([]message.PropertyValue, len(ps.defs))
+	for i, def := range ps.defs {
+		values[i] = message.PropertyValue{ID: def.ID, Value: wireValue(ps.values[i])}
+	}
+	return values
+}
+
+// Collect returns the changed properties whose update interval has passed, highest priority first,
+// for as long as they fit in budget bytes. Collected properties are marked clean for every client,
+// so callers must deliver them reliably; the rest stay dirty for the next update.
+func (ps *PropertySet) Collect(now time.Time, budget int) []message.PropertyValue {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	var values []message.PropertyValue
+	for i, def := range ps.defs {
+		if !ps.dirty[i] || now.Sub(ps.sentAt[i]) < def.Interval {
+			continue
+		}
+
+		value := message.PropertyValue{ID: def.ID, Value: wireValue(ps.values[i])}
+		if value.Size() > budget {
+			continue
+		}
+		budget -= value.Size()
+
+		values = append(values, value)
+		ps.dirty[i] = false
+		ps.sentAt[i] = now
+	}
+	return values
+}
+
+// MarshalJSON encodes the properties as an object keyed by name
+func (ps *PropertySet) MarshalJSON() ([]byte, error) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	byName := make(map[string]interface{}, len(ps.defs))
+	for i, def := range ps.defs {
+		byName[def.Name] = ps.values[i]
+	}
+	return json.Marshal(byName)
+}
+
+func (ps *PropertySet) index(name string) (int, error) {
+	for i, def := range ps.defs {
+		if def.Name == name {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown property %q", name)
+}
+
+// wireValue converts a property value to the type it is serialized as
+func wireValue(value interface{}) interface{} {
+	if v, ok := value.(Vec3); ok {
+		return [3]float32{v.X, v.Y, v.Z}
+	}
+	return value
+}
+
+// PropertyQueue holds the property updates waiting to be sent to one player. A property queued
+// again before it went out replaces the older value, so a client that is slow to acknowledge is
+// sent the latest values instead of every change in between.
+type PropertyQueue struct {
+	entities map[uint16][]message.PropertyValue
+	order    []uint16 // entity IDs in the order they were first queued
+	mu       sync.Mutex
+}
+
+// NewPropertyQueue creates an empty property queue
+func NewPropertyQueue() *PropertyQueue {
+	return &PropertyQueue{entities: make(map[uint16][]message.PropertyValue)}
+}
+
+// Add queues property values, replacing the queued values of the same properties
+func (q *PropertyQueue) Add(entities []message.EntityProperties) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, entity := range entities {
+		values, queued := q.entities[entity.EntityID]
+		if !queued {
+			q.order = append(q.order, entity.EntityID)
+		}
+	next:
+		for _, value := range entity.Values {
+			for i := range values {
+				if values[i].ID == value.ID {
+					values[i] = value
+					continue next
+				}
+			}
+			values = append(values, value)
+		}
+		q.entities[entity.EntityID] = values
+	}
+}
+
+// Forget drops the queued values of an entity, such as one the player was sent a despawn for
+func (q *PropertyQueue) Forget(id uint16) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, queued := q.entities[id]; !queued {
+		return
+	}
+	delete(q.entities, id)
+	for i, queued := range q.order {
+		if queued == id {
+			q.order = append(q.order[:i], q.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// Take empties the queue and returns its updates, in the order the entities were first queued
+func (q *PropertyQueue) Take() []message.EntityProperties {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.order) == 0 {
+		return nil
+	}
+	entities := make([]message.EntityProperties, len(q.order))
+	for i, id := range q.order {
+		entities[i] = message.EntityProperties{EntityID: id, Values: q.entities[id]}
+	}
+	q.entities = make(map[uint16][]message.PropertyValue)
+	q.order = nil
+	return entities
+}
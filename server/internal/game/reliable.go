@@ -0,0 +1,171 @@
+package game
+
+import (
+	"errors"
+	"server/internal/command"
+	"server/internal/message"
+	"sync"
+	"time"
+)
+
+// reliableWindow is how far ahead of the next expected sequence a message may arrive and still be buffered
+const reliableWindow = 256
+
+var ErrReliableBacklog = errors.New("too many unacknowledged reliable messages")
+
+// pendingReliable is a sent message waiting for its acknowledgement
+type pendingReliable struct {
+	payload []byte
+	sentAt  time.Time
+}
+
+// ReliableChannel provides ordered, exactly-once delivery over UDP between the server and one client.
+// Outgoing messages are numbered and resent until acknowledged; incoming ones are acknowledged,
+// de-duplicated and released in sequence order.
+type ReliableChannel struct {
+	maxPending int
+	nextSend   uint16
+	pending    map[uint16]*pendingReliable
+	nextRecv   uint16
+	received   map[uint16][]byte // arrived ahead of nextRecv
+	mu         sync.Mutex
+}
+
+// NewReliableChannel creates a channel allowing up to maxPending unacknowledged messages
+func NewReliableChannel(maxPending int) *ReliableChannel {
+	return &ReliableChannel{
+		maxPending: maxPending,
+		pending:    make(map[uint16]*pendingReliable),
+		received:   make(map[uint16][]byte),
+	}
+}
+
+// Send numbers a payload and keeps it until acknowledged. It returns the message to transmit.
+func (rc *ReliableChannel) Send(payload []byte, now time.Time) (message.Reliable, error) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if len(rc.pending) >= rc.maxPending {
+		return message.Reliable{}, ErrReliableBacklog
+	}
+
+	seq := rc.nextSend
+	rc.nextSend++
+	rc.pending[seq] = &pendingReliable{payload: payload, sentAt: now}
+	return message.Reliable{CommandID: command.RELIABLE, Sequence: seq, Payload: payload}, nil
+}
+
+// Pending returns how many sent messages are not acknowledged yet
+func (rc *ReliableChannel) Pending() int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	return len(rc.pending)
+}
+
+// Ack stops resending an acknowledged message
+func (rc *ReliableChannel) Ack(seq uint16) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	delete(rc.pending, seq)
+}
+
+// Due returns the unacknowledged messages last sent at least interval ago, oldest first,
+// and marks them as sent again
+func (rc *ReliableChannel) Due(now time.Time, interval time.Duration) []message.Reliable {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	// Walk forward from the oldest pending sequence so resends keep their original order
+	oldest := rc.nextSend
+	for seq := range rc.pending {
+		if int16(seq-oldest) < 0 {
+			oldest = seq
+		}
+	}
+	var due []message.Reliable
+	for seq := oldest; seq != rc.nextSend; seq++ {
+		entry, exists := rc.pending[seq]
+		if !exists || now.Sub(entry.sentAt) < interval {
+			continue
+		}
+		entry.sentAt = now
+		due = append(due, message.Reliable{CommandID: command.RELIABLE, Sequence: seq, Payload: entry.payload})
+	}
+	return due
+}
+
+// Receive accepts an incoming message and returns the payloads that are now deliverable in order,
+// and whether the message should be acknowledged. Duplicates are acknowledged again but deliver
+// nothing; messages too far ahead are not acknowledged, so the sender resends them later.
+func (rc *ReliableChannel) Receive(seq uint16, payload []byte) ([][]byte, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	ahead := int16(seq - rc.nextRecv)
+	if ahead < 0 {
+		return nil, true
+	}
+	if ahead >= reliableWindow {
+		return nil, false
+	}
+	if _, buffered := rc.received[seq]; !buffered {
+		rc.received[seq] = payload
+	}
+
+	var deliver [][]byte
+	for {
+		next, ok := rc.received[rc.nextRecv]
+		if !ok {
+			return deliver, true
+		}
+		delete(rc.received, rc.nextRecv)
+		deliver = append(deliver, next)
+		rc.nextRecv++
+	}
+}
+
+// ReliableState is the sequence numbers and undelivered messages of a channel, saved across restarts
+type ReliableState struct {
+	NextSend uint16            `json:"nextSend"`
+	NextRecv uint16            `json:"nextRecv"`
+	Pending  map[uint16][]byte `json:"pending,omitempty"`  // sent and not yet acknowledged
+	Received map[uint16][]byte `json:"received,omitempty"` // arrived ahead of NextRecv
+}
+
+// State returns a copy of the channel's sequence numbers and undelivered messages
+func (rc *ReliableChannel) State() ReliableState {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	state := ReliableState{
+		NextSend: rc.nextSend,
+		NextRecv: rc.nextRecv,
+		Pending:  make(map[uint16][]byte, len(rc.pending)),
+		Received: make(map[uint16][]byte, len(rc.received)),
+	}
+	for seq, entry := range rc.pending {
+		state.Pending[seq] = entry.payload
+	}
+	for seq, payload := range rc.received {
+		state.Received[seq] = payload
+	}
+	return state
+}
+
+// Restore continues a saved channel. Pending messages are resent on the next Due.
+func (rc *ReliableChannel) Restore(state ReliableState) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rc.nextSend, rc.nextRecv = state.NextSend, state.NextRecv
+	rc.pending = make(map[uint16]*pendingReliable, len(state.Pending))
+	for seq, payload := range state.Pending {
+		rc.pending[seq] = &pendingReliable{payload: payload}
+	}
+	rc.received = make(map[uint16][]byte, len(state.Received))
+	for seq, payload := range state.Received {
+		rc.received[seq] = payload
+	}
+}
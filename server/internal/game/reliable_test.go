@@ -0,0 +1,115 @@
+package game
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReliableReceiveInOrder(t *testing.T) {
+	rc := NewReliableChannel(8)
+
+	// Early messages are held back until the gap before them is filled
+	if deliver, ack := rc.Receive(1, []byte("b")); len(deliver) != 0 || !ack {
+		t.Fatalf("seq 1 first: delivered %q, ack %v", deliver, ack)
+	}
+	if deliver, ack := rc.Receive(2, []byte("c")); len(deliver) != 0 || !ack {
+		t.Fatalf("seq 2: delivered %q, ack %v", deliver, ack)
+	}
+	deliver, ack := rc.Receive(0, []byte("a"))
+	if !ack || len(deliver) != 3 || string(deliver[0]) != "a" || string(deliver[1]) != "b" || string(deliver[2]) != "c" {
+		t.Fatalf("seq 0: delivered %q, ack %v, want a, b, c", deliver, ack)
+	}
+
+	// Duplicates are acknowledged again, as the first ack may have been lost, but not delivered
+	for _, seq := range []uint16{0, 2} {
+		if deliver, ack := rc.Receive(seq, []byte("again")); len(deliver) != 0 || !ack {
+			t.Errorf("duplicate seq %d: delivered %q, ack %v", seq, deliver, ack)
+		}
+	}
+	rc.Receive(5, []byte("f"))
+	if deliver, _ := rc.Receive(5, []byte("f again")); len(deliver) != 0 {
+		t.Errorf("duplicate buffered seq 5 delivered %q", deliver)
+	}
+	rc.Receive(3, []byte("d"))
+	if deliver, _ := rc.Receive(4, []byte("e")); len(deliver) != 2 || string(deliver[1]) != "f" {
+		t.Errorf("seq 4 delivered %q, want e, f", deliver)
+	}
+
+	// Messages beyond the window are neither buffered nor acknowledged
+	if deliver, ack := rc.Receive(6+reliableWindow, []byte("far")); len(deliver) != 0 || ack {
+		t.Errorf("seq beyond the window: delivered %q, ack %v", deliver, ack)
+	}
+}
+
+func TestReliableSendAckAndResend(t *testing.T) {
+	const interval = 100 * time.Millisecond
+	rc := NewReliableChannel(3)
+	start := time.Now()
+
+	for i, payload := range []string{"a", "b", "c"} {
+		msg, err := rc.Send([]byte(payload), start)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg.Sequence != uint16(i) {
+			t.Errorf("message %d got sequence %d", i, msg.Sequence)
+		}
+	}
+	if _, err := rc.Send([]byte("d"), start); !errors.Is(err, ErrReliableBacklog) {
+		t.Fatalf("send over the limit: %v, want ErrReliableBacklog", err)
+	}
+
+	if due := rc.Due(start.Add(interval-time.Millisecond), interval); len(due) != 0 {
+		t.Errorf("%d messages due before the interval", len(due))
+	}
+
+	rc.Ack(1)
+	due := rc.Due(start.Add(interval), interval)
+	if len(due) != 2 || due[0].Sequence != 0 || due[1].Sequence != 2 {
+		t.Fatalf("due after ack of 1: %v, want 0 and 2", due)
+	}
+	// Resending restarts the interval
+	if due := rc.Due(start.Add(interval+time.Millisecond), interval); len(due) != 0 {
+		t.Errorf("%d messages due right after being resent", len(due))
+	}
+	if due := rc.Due(start.Add(2*interval), interval); len(due) != 2 {
+		t.Errorf("%d messages due a second interval later, want 2", len(due))
+	}
+
+	// Acks make room again
+	if _, err := rc.Send([]byte("d"), start); err != nil {
+		t.Errorf("send after an ack: %v", err)
+	}
+	rc.Ack(0)
+	rc.Ack(2)
+	rc.Ack(3)
+	if pending := rc.Pending(); pending != 0 {
+		t.Errorf("%d messages pending after every ack", pending)
+	}
+	if due := rc.Due(start.Add(time.Hour), interval); len(due) != 0 {
+		t.Errorf("acknowledged messages still due: %v", due)
+	}
+}
+
+func TestReliableResendOrderAcrossWrap(t *testing.T) {
+	rc := NewReliableChannel(8)
+	rc.Restore(ReliableState{NextSend: 65534})
+	start := time.Now()
+
+	for range 4 {
+		if _, err := rc.Send([]byte("x"), start); err != nil {
+			t.Fatal(err)
+		}
+	}
+	due := rc.Due(start.Add(time.Second), time.Second)
+	want := []uint16{65534, 65535, 0, 1}
+	if len(due) != len(want) {
+		t.Fatalf("%d messages due, want %d", len(due), len(want))
+	}
+	for i, msg := range due {
+		if msg.Sequence != want[i] {
+			t.Errorf("resend %d has sequence %d, want %d", i, msg.Sequence, want[i])
+		}
+	}
+}
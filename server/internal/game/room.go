@@ -0,0 +1,384 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// LobbyRoomID is the default room every player joins after the handshake
+const LobbyRoomID uint8 = 0
+
+var (
+	ErrRoomNotFound = errors.New("room not found")
+	ErrRoomFull     = errors.New("room is full")
+	ErrNoRoomIDs    = errors.New("no room IDs available")
+)
+
+// Room is an isolated set of players with its own simulation state and broadcast scope
+type Room struct {
+	ID       uint8
+	Name     string
+	Capacity int // 0 means unlimited
+	players  map[uint8]*Player
+	interest *InterestManager
+	views    atomic.Pointer[map[uint8][]*Player] // visible members per player, see Visible
+	viewMu   sync.Mutex                          // serializes publishing views
+	mu       sync.RWMutex
+}
+
+// RoomInfo is a read-only summary of a room
+type RoomInfo struct {
+	ID          uint8
+	Name        string
+	PlayerCount int
+	Capacity    int
+}
+
+// NewRoom creates an empty room
+func NewRoom(id uint8, name string, capacity int, interestRadius, interestCellSize float32) *Room {
+	r := &Room{
+		ID:       id,
+		Name:     name,
+		Capacity: capacity,
+		players:  make(map[uint8]*Player),
+		interest: NewInterestManager(interestRadius, interestCellSize),
+	}
+	r.views.Store(&map[uint8][]*Player{})
+	return r
+}
+
+// Join adds a player to the room and places them in the room's area of interest grid
+func (r *Room) Join(player *Player) ([]InterestChange, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.Capacity > 0 && len(r.players) >= r.Capacity {
+		return nil, ErrRoomFull
+	}
+
+	r.players[player.ID] = player
+	changes := r.interest.Move(player.ID, player.Position.X, player.Position.Z)
+	r.publishViews(changes)
+	return changes, nil
+}
+
+// Leave removes a player from the room and returns leave events for their observers
+func (r *Room) Leave(userID uint8) []InterestChange {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.players[userID]; !exists {
+		return nil
+	}
+
+	delete(r.players, userID)
+	changes := r.interest.Remove(userID)
+	r.publishViews(changes, userID)
+	return changes
+}
+
+// Move updates a player's position inside the room's area of interest grid
+func (r *Room) Move(userID uint8, x, z float32) []InterestChange {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if _, exists := r.players[userID]; !exists {
+		return nil
+	}
+	changes := r.interest.Move(userID, x, z)
+	r.publishViews(changes)
+	return changes
+}
+
+// Visible returns the room members inside the given player's area of interest. It takes no
+// lock and the slice is shared by every caller, so it must not be modified.
+func (r *Room) Visible(userID uint8) []*Player {
+	return (*r.views.Load())[userID]
+}
+
+// publishViews replaces the published views with a copy in which the observers of the changes
+// see their current area of interest and the removed players have no view. Views only change
+// with interest, not on every move. The caller holds r.mu.
+func (r *Room) publishViews(changes []InterestChange, removed ...uint8) {
+	if len(changes) == 0 && len(removed) == 0 {
+		return
+	}
+
+	r.viewMu.Lock()
+	defer r.viewMu.Unlock()
+
+	current := *r.views.Load()
+	views := make(map[uint8][]*Player, len(current))
+	for id, visible := range current {
+		views[id] = visible
+	}
+	for _, id := range removed {
+		delete(views, id)
+	}
+
+	updated := make(map[uint8]bool)
+	for _, change := range changes {
+		if updated[change.Observer] {
+			continue
+		}
+		updated[change.Observer] = true
+
+		if _, member := r.players[change.Observer]; !member {
+			delete(views, change.Observer)
+			continue
+		}
+		ids := r.interest.Visible(change.Observer)
+		visible := make([]*Player, 0, len(ids))
+		for _, id := range ids {
+			if player, exists := r.players[id]; exists {
+				visible = append(visible, player)
+			}
+		}
+		views[change.Observer] = visible
+	}
+	r.views.Store(&views)
+}
+
+// Players returns all room members except the excluded one
+func (r *Room) Players(excludeUserID uint8) []*Player {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var players []*Player
+	for _, player := range r.players {
+		if player.ID != excludeUserID {
+			players = append(players, player)
+		}
+	}
+	return players
+}
+
+// Info returns a summary of the room
+func (r *Room) Info() RoomInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return RoomInfo{
+		ID:          r.ID,
+		Name:        r.Name,
+		PlayerCount: len(r.players),
+		Capacity:    r.Capacity,
+	}
+}
+
+// RoomManager owns all rooms and tracks which room each player is in
+type RoomManager struct {
+	rooms            map[uint8]*Room
+	playerRooms      map[uint8]uint8                 // UserID -> RoomID
+	members          atomic.Pointer[map[uint8]*Room] // copy of playerRooms for RoomOf, replaced on every change
+	nextRoomID       uint8
+	interestRadius   float32
+	interestCellSize float32
+	mu               sync.RWMutex
+}
+
+// NewRoomManager creates a room manager with the lobby room already open
+func NewRoomManager(interestRadius, interestCellSize float32) *RoomManager {
+	rm := &RoomManager{
+		rooms:            make(map[uint8]*Room),
+		playerRooms:      make(map[uint8]uint8),
+		nextRoomID:       LobbyRoomID + 1,
+		interestRadius:   interestRadius,
+		interestCellSize: interestCellSize,
+	}
+	rm.rooms[LobbyRoomID] = NewRoom(LobbyRoomID, "Lobby", 0, interestRadius, interestCellSize)
+	rm.publishMembers()
+	return rm
+}
+
+// Create opens a new room with the given name and capacity
+func (rm *RoomManager) Create(name string, capacity int) (*Room, error) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	// Room IDs wrap around, skipping the lobby and rooms still in use
+	for i := 0; i < 256; i++ {
+		id := rm.nextRoomID
+		rm.nextRoomID++
+		if id == LobbyRoomID {
+			continue
+		}
+		if _, used := rm.rooms[id]; used {
+			continue
+		}
+
+		room := NewRoom(id, name, capacity, rm.interestRadius, rm.interestCellSize)
+		rm.rooms[id] = room
+		return room, nil
+	}
+	return nil, ErrNoRoomIDs
+}
+
+// Get returns a room by its ID
+func (rm *RoomManager) Get(roomID uint8) (*Room, bool) {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+
+	room, exists := rm.rooms[roomID]
+	return room, exists
+}
+
+// RoomOf returns the room the given player is in. It takes no lock, as it runs for every packet.
+func (rm *RoomManager) RoomOf(userID uint8) (*Room, bool) {
+	room, exists := (*rm.members.Load())[userID]
+	return room, exists
+}
+
+// publishMembers replaces the room of every player RoomOf reads; the caller holds the write lock
+func (rm *RoomManager) publishMembers() {
+	members := make(map[uint8]*Room, len(rm.playerRooms))
+	for userID, roomID := range rm.playerRooms {
+		if room, exists := rm.rooms[roomID]; exists {
+			members[userID] = room
+		}
+	}
+	rm.members.Store(&members)
+}
+
+// Join moves a player into the given room, leaving their current room first.
+// The returned changes cover both the room left and the room joined.
+func (rm *RoomManager) Join(roomID uint8, player *Player) ([]InterestChange, error) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	target, exists := rm.rooms[roomID]
+	if !exists {
+		return nil, ErrRoomNotFound
+	}
+
+	current, inRoom := rm.playerRooms[player.ID]
+	if inRoom && current == roomID {
+		return nil, nil
+	}
+
+	joined, err := target.Join(player)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []InterestChange
+	if inRoom {
+		changes = rm.leaveLocked(player.ID)
+	}
+	rm.playerRooms[player.ID] = roomID
+	rm.publishMembers()
+	return append(changes, joined...), nil
+}
+
+// Leave removes a player from whichever room they are in
+func (rm *RoomManager) Leave(userID uint8) []InterestChange {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	changes := rm.leaveLocked(userID)
+	rm.publishMembers()
+	return changes
+}
+
+func (rm *RoomManager) leaveLocked(userID uint8) []InterestChange {
+	roomID, exists := rm.playerRooms[userID]
+	if !exists {
+		return nil
+	}
+	delete(rm.playerRooms, userID)
+
+	room, exists := rm.rooms[roomID]
+	if !exists {
+		return nil
+	}
+	changes := room.Leave(userID)
+
+	// Empty rooms other than the lobby are closed
+	if roomID != LobbyRoomID && room.Info().PlayerCount == 0 {
+		delete(rm.rooms, roomID)
+	}
+	return changes
+}
+
+// CloseEmpty closes a room nobody is in. The lobby and rooms with players stay open.
+func (rm *RoomManager) CloseEmpty(roomID uint8) bool {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	room, exists := rm.rooms[roomID]
+	if !exists || roomID == LobbyRoomID || room.Info().PlayerCount > 0 {
+		return false
+	}
+	delete(rm.rooms, roomID)
+	return true
+}
+
+// List returns a summary of all open rooms ordered by ID
+func (rm *RoomManager) List() []RoomInfo {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	infos := make([]RoomInfo, 0, len(rm.rooms))
+	for _, room := range rm.rooms {
+		infos = append(infos, room.Info())
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
+
+// RoomState is a room and its members, saved across restarts
+type RoomState struct {
+	ID       uint8   `json:"id"`
+	Name     string  `json:"name"`
+	Capacity int     `json:"capacity"`
+	Players  []uint8 `json:"players"`
+}
+
+// State returns every open room with its members ordered by ID, and the next room ID to hand out
+func (rm *RoomManager) State() ([]RoomState, uint8) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	states := make([]RoomState, 0, len(rm.rooms))
+	for _, room := range rm.rooms {
+		state := RoomState{ID: room.ID, Name: room.Name, Capacity: room.Capacity}
+		for _, player := range room.Players(0) {
+			state.Players = append(state.Players, player.ID)
+		}
+		sort.Slice(state.Players, func(i, j int) bool { return state.Players[i] < state.Players[j] })
+		states = append(states, state)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].ID < states[j].ID })
+	return states, rm.nextRoomID
+}
+
+// Restore reopens saved rooms and puts their members back, looked up in players.
+// Members missing from players are skipped; the lobby is always kept.
+func (rm *RoomManager) Restore(states []RoomState, nextRoomID uint8, players map[uint8]*Player) error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	defer rm.publishMembers()
+
+	for _, state := range states {
+		room, exists := rm.rooms[state.ID]
+		if !exists {
+			room = NewRoom(state.ID, state.Name, state.Capacity, rm.interestRadius, rm.interestCellSize)
+			rm.rooms[state.ID] = room
+		}
+		for _, userID := range state.Players {
+			player, exists := players[userID]
+			if !exists {
+				continue
+			}
+			if _, err := room.Join(player); err != nil {
+				return fmt.Errorf("failed to restore player %d in room %d: %w", userID, state.ID, err)
+			}
+			rm.playerRooms[userID] = state.ID
+		}
+	}
+	rm.nextRoomID = nextRoomID
+	return nil
+}
@@ -0,0 +1,60 @@
+package game
+
+import (
+	"testing"
+)
+
+func visibleIDs(room *Room, userID uint8) map[uint8]bool {
+	ids := make(map[uint8]bool)
+	for _, player := range room.Visible(userID) {
+		ids[player.ID] = true
+	}
+	return ids
+}
+
+func TestRoomViewsFollowInterest(t *testing.T) {
+	rm := NewRoomManager(10, 5)
+	a, b, c := NewPlayer(1, nil, 0), NewPlayer(2, nil, 0), NewPlayer(3, nil, 0)
+	for _, player := range []*Player{a, b, c} {
+		if _, err := rm.Join(LobbyRoomID, player); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lobby, _ := rm.Get(LobbyRoomID)
+
+	if got := visibleIDs(lobby, 1); !got[2] || !got[3] || len(got) != 2 {
+		t.Errorf("after join, 1 sees %v, want 2 and 3", got)
+	}
+
+	// Moving out of range drops the player from every view, and theirs
+	lobby.Move(3, 100, 100)
+	if got := visibleIDs(lobby, 1); got[3] || !got[2] {
+		t.Errorf("after move, 1 sees %v, want only 2", got)
+	}
+	if got := visibleIDs(lobby, 3); len(got) != 0 {
+		t.Errorf("after move, 3 sees %v, want nobody", got)
+	}
+
+	// A published view is not changed by later moves
+	before := lobby.Visible(1)
+	lobby.Move(2, 100, 100)
+	if len(before) != 1 || before[0].ID != 2 {
+		t.Errorf("earlier view changed to %v", before)
+	}
+
+	room, _ := rm.Create("arena", 0)
+	if _, err := rm.Join(room.ID, a); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := rm.RoomOf(1); got != room {
+		t.Errorf("RoomOf(1) = room %d, want %d", got.ID, room.ID)
+	}
+	if got := lobby.Visible(1); got != nil {
+		t.Errorf("left player still has a lobby view %v", got)
+	}
+
+	rm.Leave(1)
+	if _, inRoom := rm.RoomOf(1); inRoom {
+		t.Error("RoomOf(1) found a room after leaving")
+	}
+}
@@ -0,0 +1,148 @@
+package game
+
+import (
+	"server/internal/message"
+	"sync"
+)
+
+// snapshotHistorySize is how many unacknowledged snapshots are kept per client
+const snapshotHistorySize = 32
+
+// EntityState is the replicated state of one entity in a snapshot
+type EntityState struct {
+	Kind    EntityKind
+	X, Y, Z float32
+	RotY    float32
+}
+
+// Snapshot is the world state a client was sent under a sequence number
+type Snapshot struct {
+	Sequence uint32
+	States   map[EntityID]EntityState
+}
+
+// SnapshotHistory keeps the snapshots sent to one client so deltas can be built
+// against the most recent one the client acknowledged
+type SnapshotHistory struct {
+	sent     [snapshotHistorySize]Snapshot
+	nextSeq  uint32
+	ackedSeq uint32 // 0 means nothing acknowledged yet
+	mu       sync.Mutex
+}
+
+// NewSnapshotHistory creates an empty history; sequence numbers start at 1
+func NewSnapshotHistory() *SnapshotHistory {
+	return &SnapshotHistory{nextSeq: 1}
+}
+
+// Baseline returns the last acknowledged snapshot if it is still in the history
+func (h *SnapshotHistory) Baseline() (Snapshot, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.ackedSeq == 0 {
+		return Snapshot{}, false
+	}
+
+	snapshot := h.sent[h.ackedSeq%snapshotHistorySize]
+	if snapshot.Sequence != h.ackedSeq {
+		return Snapshot{}, false
+	}
+	return snapshot, true
+}
+
+// Record stores the states sent to the client and returns the sequence number assigned to them
+func (h *SnapshotHistory) Record(states map[EntityID]EntityState) uint32 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	seq := h.nextSeq
+	h.nextSeq++
+	if h.nextSeq == 0 {
+		h.nextSeq = 1
+	}
+
+	h.sent[seq%snapshotHistorySize] = Snapshot{Sequence: seq, States: states}
+	return seq
+}
+
+// Ack marks a snapshot as received by the client. Older acknowledgements are ignored.
+func (h *SnapshotHistory) Ack(seq uint32) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.sent[seq%snapshotHistorySize].Sequence != seq {
+		return
+	}
+	if h.ackedSeq == 0 || int32(seq-h.ackedSeq) > 0 {
+		h.ackedSeq = seq
+	}
+}
+
+// DiffSnapshot encodes the current states against a baseline.
+// Entities whose state did not change are skipped; entities missing from current are marked removed.
+// Entities new to the client also carry their kind.
+func DiffSnapshot(baseline, current map[EntityID]EntityState) []message.SnapshotEntry {
+	var entries []message.SnapshotEntry
+
+	for id, state := range current {
+		entry := message.SnapshotEntry{
+			EntityID: uint16(id),
+			Kind:     uint8(state.Kind),
+			X:        state.X,
+			Y:        state.Y,
+			Z:        state.Z,
+			RotY:     state.RotY,
+		}
+
+		old, known := baseline[id]
+		if !known || old.Kind != state.Kind {
+			entry.Mask = message.SNAPSHOT_ALL | message.SNAPSHOT_KIND
+		} else {
+			if old.X != state.X {
+				entry.Mask |= message.SNAPSHOT_X
+			}
+			if old.Y != state.Y {
+				entry.Mask |= message.SNAPSHOT_Y
+			}
+			if old.Z != state.Z {
+				entry.Mask |= message.SNAPSHOT_Z
+			}
+			if old.RotY != state.RotY {
+				entry.Mask |= message.SNAPSHOT_ROT_Y
+			}
+		}
+
+		if entry.Mask != 0 {
+			entries = append(entries, entry)
+		}
+	}
+
+	for id := range baseline {
+		if _, still := current[id]; !still {
+			entries = append(entries, message.SnapshotEntry{EntityID: uint16(id), Mask: message.SNAPSHOT_REMOVED})
+		}
+	}
+
+	return entries
+}
+
+// ApplySnapshot returns the states a client holds after applying the entries sent to it on top of
+// baseline. Entities whose entries were held back keep their baseline state, so the next diff
+// includes them again.
+func ApplySnapshot(baseline, current map[EntityID]EntityState, sent []message.SnapshotEntry) map[EntityID]EntityState {
+	states := make(map[EntityID]EntityState, len(baseline))
+	for id, state := range baseline {
+		states[id] = state
+	}
+
+	for _, entry := range sent {
+		id := EntityID(entry.EntityID)
+		if entry.Mask&message.SNAPSHOT_REMOVED != 0 {
+			delete(states, id)
+		} else {
+			states[id] = current[id]
+		}
+	}
+	return states
+}
@@ -0,0 +1,136 @@
+package game
+
+import (
+	"server/internal/message"
+	"sort"
+	"testing"
+)
+
+func TestDiffSnapshot(t *testing.T) {
+	player := EntityState{Kind: EntityPlayer, X: 1, Y: 2, Z: 3, RotY: 0.5}
+	moved := player
+	moved.X, moved.RotY = 4, 1
+
+	tests := []struct {
+		name     string
+		baseline map[EntityID]EntityState
+		current  map[EntityID]EntityState
+		want     map[uint16]message.SnapshotMask
+	}{
+		{
+			name:    "new entity is sent in full with its kind",
+			current: map[EntityID]EntityState{1: player},
+			want:    map[uint16]message.SnapshotMask{1: message.SNAPSHOT_ALL | message.SNAPSHOT_KIND},
+		},
+		{
+			name:     "unchanged entity is skipped",
+			baseline: map[EntityID]EntityState{1: player},
+			current:  map[EntityID]EntityState{1: player},
+			want:     map[uint16]message.SnapshotMask{},
+		},
+		{
+			name:     "only changed fields are sent",
+			baseline: map[EntityID]EntityState{1: player},
+			current:  map[EntityID]EntityState{1: moved},
+			want:     map[uint16]message.SnapshotMask{1: message.SNAPSHOT_X | message.SNAPSHOT_ROT_Y},
+		},
+		{
+			name:     "changed kind is sent in full",
+			baseline: map[EntityID]EntityState{1: player},
+			current:  map[EntityID]EntityState{1: {Kind: EntityNPC, X: 1, Y: 2, Z: 3, RotY: 0.5}},
+			want:     map[uint16]message.SnapshotMask{1: message.SNAPSHOT_ALL | message.SNAPSHOT_KIND},
+		},
+		{
+			name:     "missing entity is removed",
+			baseline: map[EntityID]EntityState{1: player, 2: player},
+			current:  map[EntityID]EntityState{1: player},
+			want:     map[uint16]message.SnapshotMask{2: message.SNAPSHOT_REMOVED},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries := DiffSnapshot(tt.baseline, tt.current)
+			if len(entries) != len(tt.want) {
+				t.Fatalf("got %d entries, want %d: %+v", len(entries), len(tt.want), entries)
+			}
+			for _, entry := range entries {
+				mask, ok := tt.want[entry.EntityID]
+				if !ok {
+					t.Fatalf("unexpected entry for entity %d", entry.EntityID)
+				}
+				if entry.Mask != mask {
+					t.Errorf("entity %d: mask %08b, want %08b", entry.EntityID, entry.Mask, mask)
+				}
+			}
+		})
+	}
+}
+
+func TestDiffSnapshotAppliesToCurrent(t *testing.T) {
+	baseline := map[EntityID]EntityState{
+		1: {Kind: EntityPlayer, X: 1},
+		2: {Kind: EntityNPC, Z: 2},
+	}
+	current := map[EntityID]EntityState{
+		1: {Kind: EntityPlayer, X: 5},
+		3: {Kind: EntityPickup, Y: 3},
+	}
+
+	states := ApplySnapshot(baseline, current, DiffSnapshot(baseline, current))
+	if len(states) != len(current) {
+		t.Fatalf("got %d states, want %d", len(states), len(current))
+	}
+	for id, want := range current {
+		if states[id] != want {
+			t.Errorf("entity %d: got %+v, want %+v", id, states[id], want)
+		}
+	}
+}
+
+func TestApplySnapshotKeepsHeldBackEntries(t *testing.T) {
+	baseline := map[EntityID]EntityState{1: {X: 1}, 2: {X: 2}}
+	current := map[EntityID]EntityState{1: {X: 10}, 2: {X: 20}}
+
+	entries := DiffSnapshot(baseline, current)
+	sort.Slice(entries, func(i, j int) bool { return entries[i].EntityID < entries[j].EntityID })
+
+	// Only entity 1 fit the budget
+	states := ApplySnapshot(baseline, current, entries[:1])
+	if states[1] != current[1] {
+		t.Errorf("sent entity: got %+v, want %+v", states[1], current[1])
+	}
+	if states[2] != baseline[2] {
+		t.Errorf("held back entity: got %+v, want baseline %+v", states[2], baseline[2])
+	}
+}
+
+func TestSnapshotHistoryBaseline(t *testing.T) {
+	h := NewSnapshotHistory()
+	if _, ok := h.Baseline(); ok {
+		t.Fatal("empty history has a baseline")
+	}
+
+	first := h.Record(map[EntityID]EntityState{1: {X: 1}})
+	second := h.Record(map[EntityID]EntityState{1: {X: 2}})
+
+	h.Ack(second)
+	h.Ack(first) // late acknowledgements do not move the baseline back
+	baseline, ok := h.Baseline()
+	if !ok || baseline.Sequence != second {
+		t.Fatalf("baseline %d (%v), want %d", baseline.Sequence, ok, second)
+	}
+
+	// A baseline overwritten by newer snapshots is gone
+	for i := 0; i < snapshotHistorySize; i++ {
+		h.Record(nil)
+	}
+	if _, ok := h.Baseline(); ok {
+		t.Error("overwritten snapshot is still a baseline")
+	}
+
+	h.Ack(first) // no longer in the history
+	if _, ok := h.Baseline(); ok {
+		t.Error("acknowledging an overwritten snapshot made it a baseline")
+	}
+}
@@ -0,0 +1,99 @@
+package message
+
+import (
+	"bytes"
+	"server/internal/command"
+	"testing"
+)
+
+// testMessage returns a message of the given size starting with a command byte
+func testMessage(size int, fill byte) []byte {
+	msg := bytes.Repeat([]byte{fill}, size)
+	msg[0] = byte(command.POSITION)
+	return msg
+}
+
+func TestPackUnpackRoundTrip(t *testing.T) {
+	s := NewSerializer()
+
+	tests := []struct {
+		name      string
+		sizes     []int
+		mtu       int
+		datagrams int
+	}{
+		{name: "nothing", sizes: nil, mtu: 100, datagrams: 0},
+		{name: "single message is sent unframed", sizes: []int{18}, mtu: 100, datagrams: 1},
+		{name: "small messages share a datagram", sizes: []int{18, 18, 18}, mtu: 100, datagrams: 1},
+		{name: "messages split at the MTU", sizes: []int{40, 40, 40}, mtu: 100, datagrams: 2},
+		{name: "message filling the MTU", sizes: []int{100, 10}, mtu: 100, datagrams: 2},
+		{name: "at most 255 messages per batch", sizes: repeat(2, 300), mtu: 2000, datagrams: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var messages [][]byte
+			for i, size := range tt.sizes {
+				messages = append(messages, testMessage(size, byte(i)))
+			}
+
+			datagrams, err := s.Pack(messages, tt.mtu)
+			if err != nil {
+				t.Fatalf("Pack: %v", err)
+			}
+			if len(datagrams) != tt.datagrams {
+				t.Fatalf("got %d datagrams, want %d", len(datagrams), tt.datagrams)
+			}
+
+			var unpacked [][]byte
+			for _, datagram := range datagrams {
+				if len(datagram) > tt.mtu {
+					t.Errorf("datagram of %d bytes exceeds MTU of %d", len(datagram), tt.mtu)
+				}
+				msgs, err := s.Unpack(datagram)
+				if err != nil {
+					t.Fatalf("Unpack: %v", err)
+				}
+				unpacked = append(unpacked, msgs...)
+			}
+
+			if len(unpacked) != len(messages) {
+				t.Fatalf("unpacked %d messages, want %d", len(unpacked), len(messages))
+			}
+			for i := range messages {
+				if !bytes.Equal(unpacked[i], messages[i]) {
+					t.Errorf("message %d changed: got %v, want %v", i, unpacked[i], messages[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPackRejectsOversizedMessage(t *testing.T) {
+	if _, err := NewSerializer().Pack([][]byte{testMessage(101, 0)}, 100); err == nil {
+		t.Error("Pack accepted a message larger than the MTU")
+	}
+}
+
+func TestUnpackRejectsTruncatedBatch(t *testing.T) {
+	s := NewSerializer()
+	datagrams, err := s.Pack([][]byte{testMessage(10, 1), testMessage(10, 2)}, 100)
+	if err != nil || len(datagrams) != 1 {
+		t.Fatalf("Pack: %v, %d datagrams", err, len(datagrams))
+	}
+
+	batch := datagrams[0]
+	for _, size := range []int{0, 1, 3, len(batch) - 1} {
+		if _, err := s.Unpack(batch[:size]); err == nil {
+			t.Errorf("Unpack accepted a batch truncated to %d bytes", size)
+		}
+	}
+}
+
+func repeat(value, count int) []int {
+	values := make([]int, count)
+	for i := range values {
+		values[i] = value
+	}
+	return values
+}
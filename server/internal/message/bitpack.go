@@ -0,0 +1,59 @@
+package message
+
+import "errors"
+
+// BitWriter packs values of arbitrary bit width into a byte slice, least significant bit first
+type BitWriter struct {
+	buf    []byte
+	bitPos uint
+}
+
+// NewBitWriter creates an empty bit writer
+func NewBitWriter() *BitWriter {
+	return &BitWriter{}
+}
+
+// WriteBits appends the lowest n bits of value (n <= 64)
+func (w *BitWriter) WriteBits(value uint64, n uint) {
+	for i := uint(0); i < n; i++ {
+		if w.bitPos%8 == 0 {
+			w.buf = append(w.buf, 0)
+		}
+		if value&(1<<i) != 0 {
+			w.buf[len(w.buf)-1] |= 1 << (w.bitPos % 8)
+		}
+		w.bitPos++
+	}
+}
+
+// Bytes returns the packed data, padded with zero bits to a whole byte
+func (w *BitWriter) Bytes() []byte {
+	return w.buf
+}
+
+// BitReader reads values written by a BitWriter
+type BitReader struct {
+	buf    []byte
+	bitPos uint
+}
+
+// NewBitReader creates a reader over packed data
+func NewBitReader(data []byte) *BitReader {
+	return &BitReader{buf: data}
+}
+
+// ReadBits reads the next n bits (n <= 64)
+func (r *BitReader) ReadBits(n uint) (uint64, error) {
+	if r.bitPos+n > uint(len(r.buf))*8 {
+		return 0, errors.New("insufficient data for bit read")
+	}
+
+	var value uint64
+	for i := uint(0); i < n; i++ {
+		if r.buf[r.bitPos/8]&(1<<(r.bitPos%8)) != 0 {
+			value |= 1 << i
+		}
+		r.bitPos++
+	}
+	return value, nil
+}
@@ -0,0 +1,116 @@
+package message
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+// QuantizationConfig describes the world bounds and precision of compact positions
+type QuantizationConfig struct {
+	Min, Max  [3]float32 // World bounds for X, Y, Z
+	Precision float32    // Quantization step for coordinates, in world units
+	RotBits   uint       // Bits used for RotY over a full turn
+}
+
+// Quantizer converts positions to and from fixed-point, bit-packed form
+type Quantizer struct {
+	config  QuantizationConfig
+	axisBit [3]uint
+}
+
+// NewQuantizer validates the configuration and computes the bit width of each axis
+func NewQuantizer(config QuantizationConfig) (*Quantizer, error) {
+	if config.Precision <= 0 {
+		return nil, errors.New("precision must be positive")
+	}
+	if config.RotBits == 0 || config.RotBits > 32 {
+		return nil, fmt.Errorf("invalid rotation bits: %d", config.RotBits)
+	}
+
+	q := &Quantizer{config: config}
+	for axis := 0; axis < 3; axis++ {
+		span := config.Max[axis] - config.Min[axis]
+		if span <= 0 {
+			return nil, fmt.Errorf("invalid bounds on axis %d", axis)
+		}
+		steps := uint64(math.Ceil(float64(span / config.Precision)))
+		q.axisBit[axis] = uint(bits.Len64(steps))
+	}
+	return q, nil
+}
+
+// PayloadBits returns the number of bits used for one position and rotation
+func (q *Quantizer) PayloadBits() uint {
+	return q.axisBit[0] + q.axisBit[1] + q.axisBit[2] + q.config.RotBits
+}
+
+// MessageSize returns the size in bytes of a POSITION_COMPACT message
+func (q *Quantizer) MessageSize() int {
+	return 2 + int(q.PayloadBits()+7)/8 // 1+1+packed payload
+}
+
+// MaxPositionError returns the largest coordinate error for values inside the bounds
+func (q *Quantizer) MaxPositionError() float32 {
+	return q.config.Precision / 2
+}
+
+// MaxRotationError returns the largest RotY error in radians
+func (q *Quantizer) MaxRotationError() float32 {
+	return float32(math.Pi / float64(uint64(1)<<q.config.RotBits))
+}
+
+// Encode writes quantized X, Y, Z and RotY into the bit writer
+func (q *Quantizer) Encode(w *BitWriter, x, y, z, rotY float32) {
+	for axis, value := range [3]float32{x, y, z} {
+		w.WriteBits(q.quantizeAxis(axis, value), q.axisBit[axis])
+	}
+	w.WriteBits(q.quantizeRotation(rotY), q.config.RotBits)
+}
+
+// Decode reads values written by Encode
+func (q *Quantizer) Decode(r *BitReader) (x, y, z, rotY float32, err error) {
+	var coords [3]float32
+	for axis := range coords {
+		raw, err := r.ReadBits(q.axisBit[axis])
+		if err != nil {
+			return 0, 0, 0, 0, err
+		}
+		coords[axis] = q.config.Min[axis] + float32(raw)*q.config.Precision
+	}
+
+	raw, err := r.ReadBits(q.config.RotBits)
+	if err != nil {
+		return 0, 0, 0, 0, err
+	}
+	turns := float64(raw) / float64(uint64(1)<<q.config.RotBits)
+	rotY = float32(turns*2*math.Pi - math.Pi)
+
+	return coords[0], coords[1], coords[2], rotY, nil
+}
+
+func (q *Quantizer) quantizeAxis(axis int, value float32) uint64 {
+	min, max := float64(q.config.Min[axis]), float64(q.config.Max[axis])
+	v := float64(value)
+	if math.IsNaN(v) {
+		v = min
+	}
+	v = math.Max(min, math.Min(max, v))
+	return uint64(math.Round((v - min) / float64(q.config.Precision)))
+}
+
+// quantizeRotation maps RotY onto [-Pi, Pi) and then onto RotBits steps
+func (q *Quantizer) quantizeRotation(rotY float32) uint64 {
+	angle := float64(rotY)
+	if math.IsNaN(angle) || math.IsInf(angle, 0) {
+		angle = 0
+	}
+	angle = math.Mod(angle+math.Pi, 2*math.Pi)
+	if angle < 0 {
+		angle += 2 * math.Pi
+	}
+
+	steps := uint64(1) << q.config.RotBits
+	return uint64(math.Round(angle/(2*math.Pi)*float64(steps))) % steps
+}
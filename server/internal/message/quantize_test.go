@@ -0,0 +1,205 @@
+package message
+
+import (
+	"math"
+	"math/rand"
+	"server/internal/command"
+	"testing"
+)
+
+var testQuantization = QuantizationConfig{
+	Min:       [3]float32{-500, -50, -500},
+	Max:       [3]float32{500, 200, 500},
+	Precision: 0.01,
+	RotBits:   10,
+}
+
+func newTestQuantizer(t testing.TB) *Quantizer {
+	q, err := NewQuantizer(testQuantization)
+	if err != nil {
+		t.Fatalf("NewQuantizer: %v", err)
+	}
+	return q
+}
+
+// angleError returns the distance between two angles on the circle
+func angleError(a, b float32) float64 {
+	diff := math.Mod(math.Abs(float64(a-b)), 2*math.Pi)
+	return math.Min(diff, 2*math.Pi-diff)
+}
+
+func TestBitPackRoundTrip(t *testing.T) {
+	widths := []uint{1, 3, 7, 8, 9, 17, 31, 32, 33, 64}
+	values := make([]uint64, len(widths))
+
+	w := NewBitWriter()
+	for i, n := range widths {
+		values[i] = rand.Uint64()
+		if n < 64 {
+			values[i] &= 1<<n - 1
+		}
+		w.WriteBits(values[i], n)
+	}
+
+	var total uint
+	for _, n := range widths {
+		total += n
+	}
+	if got, want := len(w.Bytes()), int(total+7)/8; got != want {
+		t.Fatalf("packed into %d bytes, want %d", got, want)
+	}
+
+	r := NewBitReader(w.Bytes())
+	for i, n := range widths {
+		got, err := r.ReadBits(n)
+		if err != nil {
+			t.Fatalf("ReadBits(%d): %v", n, err)
+		}
+		if got != values[i] {
+			t.Errorf("value %d of %d bits: got %x, want %x", i, n, got, values[i])
+		}
+	}
+
+	// Only padding is left
+	if _, err := r.ReadBits(8); err == nil {
+		t.Error("read past the end of the data")
+	}
+}
+
+func TestQuantizerErrorBound(t *testing.T) {
+	q := newTestQuantizer(t)
+	cfg := testQuantization
+	maxPos, maxRot := float64(q.MaxPositionError()), float64(q.MaxRotationError())
+	// float32 rounding of the decoded values, a few ulps at the largest coordinate
+	const epsilon = 1e-4
+
+	rng := rand.New(rand.NewSource(1))
+	samples := [][4]float32{
+		{cfg.Min[0], cfg.Min[1], cfg.Min[2], -math.Pi},
+		{cfg.Max[0], cfg.Max[1], cfg.Max[2], math.Pi},
+		{0, 0, 0, 0},
+	}
+	for i := 0; i < 10000; i++ {
+		var sample [4]float32
+		for axis := 0; axis < 3; axis++ {
+			sample[axis] = cfg.Min[axis] + rng.Float32()*(cfg.Max[axis]-cfg.Min[axis])
+		}
+		sample[3] = (rng.Float32()*2 - 1) * 4 * math.Pi // several turns either way
+		samples = append(samples, sample)
+	}
+
+	for _, sample := range samples {
+		w := NewBitWriter()
+		q.Encode(w, sample[0], sample[1], sample[2], sample[3])
+		x, y, z, rotY, err := q.Decode(NewBitReader(w.Bytes()))
+		if err != nil {
+			t.Fatalf("Decode: %v", err)
+		}
+
+		for axis, got := range [3]float32{x, y, z} {
+			if diff := math.Abs(float64(got - sample[axis])); diff > maxPos+epsilon {
+				t.Fatalf("axis %d of %v: error %g exceeds %g", axis, sample, diff, maxPos)
+			}
+		}
+		if diff := angleError(rotY, sample[3]); diff > maxRot+epsilon {
+			t.Fatalf("rotation of %v: error %g exceeds %g", sample, diff, maxRot)
+		}
+		if rotY < -math.Pi || rotY >= math.Pi {
+			t.Fatalf("rotation %g outside [-Pi, Pi)", rotY)
+		}
+	}
+}
+
+func TestQuantizerClampsOutOfBounds(t *testing.T) {
+	q := newTestQuantizer(t)
+
+	tests := []struct {
+		name    string
+		in      [3]float32
+		x, y, z float32
+	}{
+		{name: "below", in: [3]float32{-1000, -100, -1000}, x: -500, y: -50, z: -500},
+		{name: "above", in: [3]float32{1000, 300, 1000}, x: 500, y: 200, z: 500},
+		{name: "not a number", in: [3]float32{float32(math.NaN()), 0, 0}, x: -500, y: 0, z: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewBitWriter()
+			q.Encode(w, tt.in[0], tt.in[1], tt.in[2], 0)
+			x, y, z, _, err := q.Decode(NewBitReader(w.Bytes()))
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			for axis, got := range [3]float32{x, y, z} {
+				want := [3]float32{tt.x, tt.y, tt.z}[axis]
+				if math.Abs(float64(got-want)) > float64(q.MaxPositionError())+1e-4 {
+					t.Errorf("axis %d: got %g, want %g", axis, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCompactPositionRoundTrip(t *testing.T) {
+	s := NewSerializer()
+	q := newTestQuantizer(t)
+	s.SetQuantizer(q)
+
+	pos := PositionData{CommandID: command.POSITION_COMPACT, UserID: 7, X: 12.345, Y: 1.5, Z: -250.01, RotY: 1}
+	data, err := s.SerializeCompactPosition(pos)
+	if err != nil {
+		t.Fatalf("SerializeCompactPosition: %v", err)
+	}
+	if len(data) != q.MessageSize() {
+		t.Errorf("message is %d bytes, MessageSize says %d", len(data), q.MessageSize())
+	}
+
+	decoded, cmd, err := s.Deserialize(data)
+	if err != nil {
+		t.Fatalf("Deserialize: %v", err)
+	}
+	got := decoded.(PositionData)
+	if cmd != command.POSITION_COMPACT || got.UserID != pos.UserID {
+		t.Fatalf("got %v for UserID=%d, want POSITION_COMPACT for UserID=%d", cmd, got.UserID, pos.UserID)
+	}
+	if math.Abs(float64(got.X-pos.X)) > float64(q.MaxPositionError())+1e-4 {
+		t.Errorf("X: got %g, want %g", got.X, pos.X)
+	}
+
+	if _, _, err := s.Deserialize(data[:len(data)-1]); err == nil {
+		t.Error("Deserialize accepted a truncated message")
+	}
+}
+
+// BenchmarkPositionSize compares the size and cost of the full and compact position encodings
+func BenchmarkPositionSize(b *testing.B) {
+	pos := PositionData{CommandID: command.POSITION, UserID: 7, X: 12.345, Y: 1.5, Z: -250.01, RotY: 1}
+
+	b.Run("Full", func(b *testing.B) {
+		s := NewSerializer()
+		var size int
+		for i := 0; i < b.N; i++ {
+			data, err := s.SerializePositionData(pos)
+			if err != nil {
+				b.Fatal(err)
+			}
+			size = len(data)
+		}
+		b.ReportMetric(float64(size), "bytes/msg")
+	})
+
+	b.Run("Compact", func(b *testing.B) {
+		s := NewSerializer()
+		s.SetQuantizer(newTestQuantizer(b))
+		var size int
+		for i := 0; i < b.N; i++ {
+			data, err := s.SerializeCompactPosition(pos)
+			if err != nil {
+				b.Fatal(err)
+			}
+			size = len(data)
+		}
+		b.ReportMetric(float64(size), "bytes/msg")
+	})
+}
@@ -5,17 +5,30 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
 	"server/internal/command"
 	"server/pkg/direction"
 )
 
 // Serializer handles all message serialization/deserialization
-type Serializer struct{}
+type Serializer struct {
+	quantizer *Quantizer // nil when compact positions are disabled
+}
 
 func NewSerializer() *Serializer {
 	return &Serializer{}
 }
 
+// SetQuantizer enables the compact POSITION_COMPACT encoding
+func (s *Serializer) SetQuantizer(q *Quantizer) {
+	s.quantizer = q
+}
+
+// CompactPositionsEnabled reports whether a quantizer is configured
+func (s *Serializer) CompactPositionsEnabled() bool {
+	return s.quantizer != nil
+}
+
 // Deserialize parses incoming byte data into appropriate message types
 func (s *Serializer) Deserialize(data []byte) (interface{}, command.Command, error) {
 	if len(data) == 0 {
@@ -36,8 +49,60 @@ func (s *Serializer) Deserialize(data []byte) (interface{}, command.Command, err
 		return s.deserializeMoveDataRTT(reader)
 	case command.USER_ASSIGNMENT:
 		return s.deserializeUserAssignment(reader)
+	case command.PORT_REQUEST:
+		return s.deserializePortRequest(reader)
 	case command.PORT_ASSIGNMENT:
 		return s.deserializePortAssignment(reader)
+	case command.INTEREST_ENTER, command.INTEREST_LEAVE:
+		return s.deserializeInterestEvent(reader)
+	case command.ROOM_CREATE:
+		return s.deserializeRoomCreate(reader)
+	case command.ROOM_JOIN:
+		return s.deserializeRoomJoin(reader)
+	case command.ROOM_LEAVE, command.ROOM_LIST_REQUEST:
+		return s.deserializeRoomRequest(reader)
+	case command.MATCH_REQUEST:
+		return s.deserializeMatchRequest(reader)
+	case command.MATCH_CANCEL:
+		return s.deserializeMatchCancel(reader)
+	case command.SNAPSHOT:
+		return s.deserializeSnapshotData(reader)
+	case command.SNAPSHOT_ACK:
+		return s.deserializeSnapshotAck(reader)
+	case command.POSITION_COMPACT:
+		return s.deserializeCompactPosition(data)
+	case command.FRAGMENT:
+		return s.deserializeFragment(data)
+	case command.KEY_EXCHANGE:
+		return s.deserializeKeyExchange(reader)
+	case command.INPUT:
+		return s.deserializeInputData(reader)
+	case command.AUTH_STATE:
+		return s.deserializeAuthState(reader)
+	case command.ENTITY_SPAWN:
+		return s.deserializeEntitySpawn(reader)
+	case command.ENTITY_DESPAWN:
+		return s.deserializeEntityDespawn(reader)
+	case command.ENTITY_PROPERTIES:
+		return s.deserializePropertyUpdate(reader)
+	case command.RELIABLE:
+		return s.deserializeReliable(data)
+	case command.RELIABLE_ACK:
+		return s.deserializeReliableAck(reader)
+	case command.CHAT:
+		return s.deserializeChatMessage(reader)
+	case command.PLAYER_INFO:
+		return s.deserializePlayerInfo(reader)
+	case command.PROFILE_REJECTED:
+		return s.deserializeProfileRejected(reader)
+	case command.JOIN_SNAPSHOT:
+		return s.deserializeJoinSnapshot(reader)
+	case command.PLAYER_JOINED, command.PLAYER_LEFT:
+		return s.deserializePlayerEvent(reader)
+	case command.PING, command.PONG:
+		return s.deserializePing(reader)
+	case command.ENTITY_TRANSFORM:
+		return s.deserializeEntityTransforms(reader)
 	default:
 		return nil, cmd, fmt.Errorf("unknown command: %d", cmd)
 	}
@@ -151,6 +216,35 @@ func (s *Serializer) SerializeDefaultRTT(rtt DefaultRTT) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// Ping serialization
+func (s *Serializer) SerializePing(ping Ping) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	fields := []interface{}{ping.CommandID, ping.Sequence}
+
+	for _, field := range fields {
+		if err := binary.Write(buf, binary.LittleEndian, field); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *Serializer) deserializePing(reader *bytes.Reader) (Ping, command.Command, error) {
+	if reader.Len() < 5 { // 1+4
+		return Ping{}, 0, errors.New("insufficient data for Ping")
+	}
+
+	var ping Ping
+	fields := []interface{}{&ping.CommandID, &ping.Sequence}
+
+	for _, field := range fields {
+		if err := binary.Read(reader, binary.LittleEndian, field); err != nil {
+			return Ping{}, 0, err
+		}
+	}
+	return ping, ping.CommandID, nil
+}
+
 // UserAssignment serialization
 func (s *Serializer) SerializeUserAssignment(ua UserAssignment) ([]byte, error) {
 	buf := new(bytes.Buffer)
@@ -180,6 +274,79 @@ func (s *Serializer) deserializeUserAssignment(reader *bytes.Reader) (UserAssign
 	return ua, ua.CommandID, nil
 }
 
+// PortRequest serialization
+func (s *Serializer) SerializePortRequest(pr PortRequest) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	fields := []interface{}{pr.CommandID, pr.Cookie, pr.Color, pr.Skin}
+
+	for _, field := range fields {
+		if err := binary.Write(buf, binary.LittleEndian, field); err != nil {
+			return nil, err
+		}
+	}
+	if err := writeString(buf, pr.Name); err != nil {
+		return nil, err
+	}
+	if pr.Secret != ([16]byte{}) {
+		buf.Write(pr.Secret[:])
+	}
+	return buf.Bytes(), nil
+}
+
+// deserializePortRequest also accepts the legacy single-byte request, which carries a zero cookie,
+// and requests without a profile or secret
+func (s *Serializer) deserializePortRequest(reader *bytes.Reader) (PortRequest, command.Command, error) {
+	var pr PortRequest
+	if err := binary.Read(reader, binary.LittleEndian, &pr.CommandID); err != nil {
+		return PortRequest{}, 0, err
+	}
+	if reader.Len() < len(pr.Cookie) {
+		return pr, pr.CommandID, nil
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &pr.Cookie); err != nil {
+		return PortRequest{}, 0, err
+	}
+	if reader.Len() == 0 {
+		return pr, pr.CommandID, nil
+	}
+
+	if reader.Len() < 6 { // 4+1+1
+		return PortRequest{}, 0, errors.New("insufficient data for PortRequest profile")
+	}
+	for _, field := range []interface{}{&pr.Color, &pr.Skin} {
+		if err := binary.Read(reader, binary.LittleEndian, field); err != nil {
+			return PortRequest{}, 0, err
+		}
+	}
+	name, err := readString(reader)
+	if err != nil {
+		return PortRequest{}, 0, err
+	}
+	pr.Name = name
+	if reader.Len() == 0 {
+		return pr, pr.CommandID, nil
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &pr.Secret); err != nil {
+		return PortRequest{}, 0, errors.New("insufficient data for PortRequest secret")
+	}
+	return pr, pr.CommandID, nil
+}
+
+// ConnectChallenge serialization
+func (s *Serializer) SerializeConnectChallenge(cc ConnectChallenge) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	fields := []interface{}{cc.CommandID, cc.Cookie}
+
+	for _, field := range fields {
+		if err := binary.Write(buf, binary.LittleEndian, field); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
 // PortAssignment serialization
 func (s *Serializer) SerializePortAssignment(pa PortAssignment) ([]byte, error) {
 	buf := new(bytes.Buffer)
@@ -208,3 +375,1172 @@ func (s *Serializer) deserializePortAssignment(reader *bytes.Reader) (PortAssign
 	}
 	return pa, pa.CommandID, nil
 }
+
+// InterestEvent serialization
+func (s *Serializer) SerializeInterestEvent(ev InterestEvent) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	fields := []interface{}{ev.CommandID, ev.UserID}
+
+	for _, field := range fields {
+		if err := binary.Write(buf, binary.LittleEndian, field); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *Serializer) deserializeInterestEvent(reader *bytes.Reader) (InterestEvent, command.Command, error) {
+	if reader.Len() < 2 {
+		return InterestEvent{}, 0, errors.New("insufficient data for InterestEvent")
+	}
+
+	var ev InterestEvent
+	fields := []interface{}{&ev.CommandID, &ev.UserID}
+
+	for _, field := range fields {
+		if err := binary.Read(reader, binary.LittleEndian, field); err != nil {
+			return InterestEvent{}, 0, err
+		}
+	}
+	return ev, ev.CommandID, nil
+}
+
+// writeString writes a length-prefixed string (at most 255 bytes)
+func writeString(buf *bytes.Buffer, str string) error {
+	if len(str) > 255 {
+		return fmt.Errorf("string too long: %d bytes", len(str))
+	}
+	buf.WriteByte(uint8(len(str)))
+	buf.WriteString(str)
+	return nil
+}
+
+// readString reads a length-prefixed string
+func readString(reader *bytes.Reader) (string, error) {
+	length, err := reader.ReadByte()
+	if err != nil {
+		return "", err
+	}
+	if reader.Len() < int(length) {
+		return "", errors.New("insufficient data for string")
+	}
+
+	str := make([]byte, length)
+	if _, err := reader.Read(str); err != nil {
+		return "", err
+	}
+	return string(str), nil
+}
+
+// RoomCreate serialization
+func (s *Serializer) SerializeRoomCreate(rc RoomCreate) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	fields := []interface{}{rc.CommandID, rc.UserID, rc.Capacity}
+
+	for _, field := range fields {
+		if err := binary.Write(buf, binary.LittleEndian, field); err != nil {
+			return nil, err
+		}
+	}
+	if err := writeString(buf, rc.Name); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *Serializer) deserializeRoomCreate(reader *bytes.Reader) (RoomCreate, command.Command, error) {
+	if reader.Len() < 4 { // 1+1+1+1
+		return RoomCreate{}, 0, errors.New("insufficient data for RoomCreate")
+	}
+
+	var rc RoomCreate
+	fields := []interface{}{&rc.CommandID, &rc.UserID, &rc.Capacity}
+
+	for _, field := range fields {
+		if err := binary.Read(reader, binary.LittleEndian, field); err != nil {
+			return RoomCreate{}, 0, err
+		}
+	}
+
+	name, err := readString(reader)
+	if err != nil {
+		return RoomCreate{}, 0, err
+	}
+	rc.Name = name
+	return rc, rc.CommandID, nil
+}
+
+// RoomJoin serialization
+func (s *Serializer) SerializeRoomJoin(rj RoomJoin) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	fields := []interface{}{rj.CommandID, rj.UserID, rj.RoomID}
+
+	for _, field := range fields {
+		if err := binary.Write(buf, binary.LittleEndian, field); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *Serializer) deserializeRoomJoin(reader *bytes.Reader) (RoomJoin, command.Command, error) {
+	if reader.Len() < 3 { // 1+1+1
+		return RoomJoin{}, 0, errors.New("insufficient data for RoomJoin")
+	}
+
+	var rj RoomJoin
+	fields := []interface{}{&rj.CommandID, &rj.UserID, &rj.RoomID}
+
+	for _, field := range fields {
+		if err := binary.Read(reader, binary.LittleEndian, field); err != nil {
+			return RoomJoin{}, 0, err
+		}
+	}
+	return rj, rj.CommandID, nil
+}
+
+// RoomRequest serialization
+func (s *Serializer) SerializeRoomRequest(rr RoomRequest) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	fields := []interface{}{rr.CommandID, rr.UserID}
+
+	for _, field := range fields {
+		if err := binary.Write(buf, binary.LittleEndian, field); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *Serializer) deserializeRoomRequest(reader *bytes.Reader) (RoomRequest, command.Command, error) {
+	if reader.Len() < 2 { // 1+1
+		return RoomRequest{}, 0, errors.New("insufficient data for RoomRequest")
+	}
+
+	var rr RoomRequest
+	fields := []interface{}{&rr.CommandID, &rr.UserID}
+
+	for _, field := range fields {
+		if err := binary.Read(reader, binary.LittleEndian, field); err != nil {
+			return RoomRequest{}, 0, err
+		}
+	}
+	return rr, rr.CommandID, nil
+}
+
+// RoomList serialization
+func (s *Serializer) SerializeRoomList(rl RoomList) ([]byte, error) {
+	if len(rl.Rooms) > 255 {
+		return nil, fmt.Errorf("too many rooms: %d", len(rl.Rooms))
+	}
+
+	buf := new(bytes.Buffer)
+	fields := []interface{}{rl.CommandID, uint8(len(rl.Rooms))}
+
+	for _, field := range fields {
+		if err := binary.Write(buf, binary.LittleEndian, field); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, room := range rl.Rooms {
+		buf.Write([]byte{room.RoomID, room.PlayerCount, room.Capacity})
+		if err := writeString(buf, room.Name); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// RoomAssignment serialization
+func (s *Serializer) SerializeRoomAssignment(ra RoomAssignment) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	fields := []interface{}{ra.CommandID, ra.UserID, ra.RoomID, ra.Status}
+
+	for _, field := range fields {
+		if err := binary.Write(buf, binary.LittleEndian, field); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// MatchRequest serialization
+func (s *Serializer) SerializeMatchRequest(mr MatchRequest) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	fields := []interface{}{mr.CommandID, mr.UserID, mr.RTTMillis, mr.Skill, mr.HasSkill}
+
+	for _, field := range fields {
+		if err := binary.Write(buf, binary.LittleEndian, field); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *Serializer) deserializeMatchRequest(reader *bytes.Reader) (MatchRequest, command.Command, error) {
+	if reader.Len() < 6 { // 1+1+2+2
+		return MatchRequest{}, 0, errors.New("insufficient data for MatchRequest")
+	}
+
+	var mr MatchRequest
+	fields := []interface{}{&mr.CommandID, &mr.UserID, &mr.RTTMillis, &mr.Skill}
+
+	for _, field := range fields {
+		if err := binary.Read(reader, binary.LittleEndian, field); err != nil {
+			return MatchRequest{}, 0, err
+		}
+	}
+
+	// Older clients end the request here and send no skill as zero
+	if reader.Len() == 0 {
+		mr.HasSkill = mr.Skill != 0
+		return mr, mr.CommandID, nil
+	}
+	if err := binary.Read(reader, binary.LittleEndian, &mr.HasSkill); err != nil {
+		return MatchRequest{}, 0, err
+	}
+	return mr, mr.CommandID, nil
+}
+
+// MatchCancel serialization
+func (s *Serializer) SerializeMatchCancel(mc MatchCancel) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	fields := []interface{}{mc.CommandID, mc.UserID}
+
+	for _, field := range fields {
+		if err := binary.Write(buf, binary.LittleEndian, field); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *Serializer) deserializeMatchCancel(reader *bytes.Reader) (MatchCancel, command.Command, error) {
+	if reader.Len() < 2 { // 1+1
+		return MatchCancel{}, 0, errors.New("insufficient data for MatchCancel")
+	}
+
+	var mc MatchCancel
+	fields := []interface{}{&mc.CommandID, &mc.UserID}
+
+	for _, field := range fields {
+		if err := binary.Read(reader, binary.LittleEndian, field); err != nil {
+			return MatchCancel{}, 0, err
+		}
+	}
+	return mc, mc.CommandID, nil
+}
+
+// MatchAssignment serialization
+func (s *Serializer) SerializeMatchAssignment(ma MatchAssignment) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	fields := []interface{}{ma.CommandID, ma.UserID, ma.RoomID, ma.PlayerCount}
+
+	for _, field := range fields {
+		if err := binary.Write(buf, binary.LittleEndian, field); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// SnapshotData serialization
+func (s *Serializer) SerializeSnapshotData(snap SnapshotData) ([]byte, error) {
+	if len(snap.Entries) > 0xFFFF {
+		return nil, fmt.Errorf("too many snapshot entries: %d", len(snap.Entries))
+	}
+
+	buf := new(bytes.Buffer)
+	fields := []interface{}{snap.CommandID, snap.Sequence, snap.Baseline, uint16(len(snap.Entries))}
+
+	for _, field := range fields {
+		if err := binary.Write(buf, binary.LittleEndian, field); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, entry := range snap.Entries {
+		if err := binary.Write(buf, binary.LittleEndian, entry.EntityID); err != nil {
+			return nil, err
+		}
+		buf.WriteByte(uint8(entry.Mask))
+		if entry.Mask&SNAPSHOT_REMOVED != 0 {
+			continue
+		}
+		if entry.Mask&SNAPSHOT_KIND != 0 {
+			buf.WriteByte(entry.Kind)
+		}
+
+		for _, field := range snapshotFields(&entry) {
+			if entry.Mask&field.flag == 0 {
+				continue
+			}
+			if err := binary.Write(buf, binary.LittleEndian, *field.value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *Serializer) deserializeSnapshotData(reader *bytes.Reader) (SnapshotData, command.Command, error) {
+	if reader.Len() < 11 { // 1+4+4+2
+		return SnapshotData{}, 0, errors.New("insufficient data for SnapshotData")
+	}
+
+	var snap SnapshotData
+	var count uint16
+	fields := []interface{}{&snap.CommandID, &snap.Sequence, &snap.Baseline, &count}
+
+	for _, field := range fields {
+		if err := binary.Read(reader, binary.LittleEndian, field); err != nil {
+			return SnapshotData{}, 0, err
+		}
+	}
+
+	snap.Entries = make([]SnapshotEntry, count)
+	for i := range snap.Entries {
+		entry := &snap.Entries[i]
+		if err := binary.Read(reader, binary.LittleEndian, &entry.EntityID); err != nil {
+			return SnapshotData{}, 0, err
+		}
+		if err := binary.Read(reader, binary.LittleEndian, &entry.Mask); err != nil {
+			return SnapshotData{}, 0, err
+		}
+		if entry.Mask&SNAPSHOT_REMOVED != 0 {
+			continue
+		}
+		if entry.Mask&SNAPSHOT_KIND != 0 {
+			if err := binary.Read(reader, binary.LittleEndian, &entry.Kind); err != nil {
+				return SnapshotData{}, 0, err
+			}
+		}
+
+		for _, field := range snapshotFields(entry) {
+			if entry.Mask&field.flag == 0 {
+				continue
+			}
+			if err := binary.Read(reader, binary.LittleEndian, field.value); err != nil {
+				return SnapshotData{}, 0, err
+			}
+		}
+	}
+	return snap, snap.CommandID, nil
+}
+
+// snapshotFields lists the optional fields of a snapshot entry in wire order
+func snapshotFields(entry *SnapshotEntry) []struct {
+	flag  SnapshotMask
+	value *float32
+} {
+	return []struct {
+		flag  SnapshotMask
+		value *float32
+	}{
+		{SNAPSHOT_X, &entry.X},
+		{SNAPSHOT_Y, &entry.Y},
+		{SNAPSHOT_Z, &entry.Z},
+		{SNAPSHOT_ROT_Y, &entry.RotY},
+	}
+}
+
+// SnapshotAck serialization
+func (s *Serializer) SerializeSnapshotAck(ack SnapshotAck) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	fields := []interface{}{ack.CommandID, ack.UserID, ack.Sequence}
+
+	for _, field := range fields {
+		if err := binary.Write(buf, binary.LittleEndian, field); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *Serializer) deserializeSnapshotAck(reader *bytes.Reader) (SnapshotAck, command.Command, error) {
+	if reader.Len() < 6 { // 1+1+4
+		return SnapshotAck{}, 0, errors.New("insufficient data for SnapshotAck")
+	}
+
+	var ack SnapshotAck
+	fields := []interface{}{&ack.CommandID, &ack.UserID, &ack.Sequence}
+
+	for _, field := range fields {
+		if err := binary.Read(reader, binary.LittleEndian, field); err != nil {
+			return SnapshotAck{}, 0, err
+		}
+	}
+	return ack, ack.CommandID, nil
+}
+
+// CompactPosition serialization: command, user ID, then the bit-packed quantized position
+func (s *Serializer) SerializeCompactPosition(pos PositionData) ([]byte, error) {
+	if s.quantizer == nil {
+		return nil, errors.New("compact positions are not enabled")
+	}
+
+	w := NewBitWriter()
+	s.quantizer.Encode(w, pos.X, pos.Y, pos.Z, pos.RotY)
+
+	data := []byte{uint8(command.POSITION_COMPACT), pos.UserID}
+	return append(data, w.Bytes()...), nil
+}
+
+func (s *Serializer) deserializeCompactPosition(data []byte) (PositionData, command.Command, error) {
+	if s.quantizer == nil {
+		return PositionData{}, 0, errors.New("compact positions are not enabled")
+	}
+	if len(data) < 2 { // 1+1
+		return PositionData{}, 0, errors.New("insufficient data for CompactPosition")
+	}
+
+	pos := PositionData{
+		CommandID: command.Command(data[0]),
+		UserID:    data[1],
+	}
+
+	var err error
+	pos.X, pos.Y, pos.Z, pos.RotY, err = s.quantizer.Decode(NewBitReader(data[2:]))
+	if err != nil {
+		return PositionData{}, 0, err
+	}
+	return pos, pos.CommandID, nil
+}
+
+// batchHeaderSize is the BATCH command byte plus the message count
+const batchHeaderSize = 2
+
+// Pack frames messages into as few datagrams of at most mtu bytes as possible.
+// Each datagram is BATCH, a message count, then every message prefixed with its uint16 length.
+// A datagram that would carry a single message is sent unframed.
+func (s *Serializer) Pack(messages [][]byte, mtu int) ([][]byte, error) {
+	var datagrams [][]byte
+	var current [][]byte
+	size := batchHeaderSize
+
+	flush := func() {
+		switch len(current) {
+		case 0:
+		case 1:
+			datagrams = append(datagrams, current[0])
+		default:
+			buf := make([]byte, 0, size)
+			buf = append(buf, uint8(command.BATCH), uint8(len(current)))
+			for _, msg := range current {
+				buf = binary.LittleEndian.AppendUint16(buf, uint16(len(msg)))
+				buf = append(buf, msg...)
+			}
+			datagrams = append(datagrams, buf)
+		}
+		current = nil
+		size = batchHeaderSize
+	}
+
+	for _, msg := range messages {
+		if len(msg) > mtu {
+			return nil, fmt.Errorf("message of %d bytes exceeds MTU of %d", len(msg), mtu)
+		}
+
+		framed := 2 + len(msg)
+		if len(current) > 0 && (size+framed > mtu || len(current) == 255) {
+			flush()
+		}
+		current = append(current, msg)
+		size += framed
+	}
+	flush()
+
+	return datagrams, nil
+}
+
+// Unpack splits a datagram into its messages. Datagrams that are not a BATCH
+// are returned as a single message.
+func (s *Serializer) Unpack(data []byte) ([][]byte, error) {
+	if len(data) == 0 {
+		return nil, errors.New("empty data")
+	}
+	if command.Command(data[0]) != command.BATCH {
+		return [][]byte{data}, nil
+	}
+	if len(data) < batchHeaderSize {
+		return nil, errors.New("insufficient data for Batch")
+	}
+
+	count := int(data[1])
+	messages := make([][]byte, 0, count)
+	offset := batchHeaderSize
+
+	for i := 0; i < count; i++ {
+		if offset+2 > len(data) {
+			return nil, errors.New("insufficient data for Batch length")
+		}
+		length := int(binary.LittleEndian.Uint16(data[offset:]))
+		offset += 2
+
+		if offset+length > len(data) {
+			return nil, errors.New("insufficient data for Batch message")
+		}
+		messages = append(messages, data[offset:offset+length])
+		offset += length
+	}
+	return messages, nil
+}
+
+// fragmentHeaderSize is 1+2+1+1: command, message ID, index, count
+const fragmentHeaderSize = 5
+
+// Fragment splits a message into FRAGMENT datagrams of at most mtu bytes
+func (s *Serializer) Fragment(data []byte, messageID uint16, mtu int) ([][]byte, error) {
+	chunk := mtu - fragmentHeaderSize
+	if chunk <= 0 {
+		return nil, fmt.Errorf("MTU of %d is too small for fragments", mtu)
+	}
+
+	count := (len(data) + chunk - 1) / chunk
+	if count > 255 {
+		return nil, fmt.Errorf("message of %d bytes needs %d fragments, at most 255 allowed", len(data), count)
+	}
+
+	fragments := make([][]byte, 0, count)
+	for i := 0; i < count; i++ {
+		end := min((i+1)*chunk, len(data))
+
+		buf := make([]byte, 0, fragmentHeaderSize+end-i*chunk)
+		buf = append(buf, uint8(command.FRAGMENT))
+		buf = binary.LittleEndian.AppendUint16(buf, messageID)
+		buf = append(buf, uint8(i), uint8(count))
+		buf = append(buf, data[i*chunk:end]...)
+		fragments = append(fragments, buf)
+	}
+	return fragments, nil
+}
+
+func (s *Serializer) deserializeFragment(data []byte) (Fragment, command.Command, error) {
+	if len(data) < fragmentHeaderSize {
+		return Fragment{}, 0, errors.New("insufficient data for Fragment")
+	}
+
+	frag := Fragment{
+		CommandID: command.Command(data[0]),
+		MessageID: binary.LittleEndian.Uint16(data[1:3]),
+		Index:     data[3],
+		Count:     data[4],
+		Payload:   data[fragmentHeaderSize:],
+	}
+	if frag.Count == 0 || frag.Index >= frag.Count {
+		return Fragment{}, 0, fmt.Errorf("invalid fragment %d of %d", frag.Index, frag.Count)
+	}
+	return frag, frag.CommandID, nil
+}
+
+// KeyExchange serialization of the server's reply
+func (s *Serializer) SerializeKeyExchange(kx KeyExchange) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	fields := []interface{}{kx.CommandID, kx.PublicKey, kx.Signature}
+
+	for _, field := range fields {
+		if err := binary.Write(buf, binary.LittleEndian, field); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// deserializeKeyExchange reads a request: command, public key, cookie, proof flag and proof,
+// followed by padding up to KeyExchangeSize
+func (s *Serializer) deserializeKeyExchange(reader *bytes.Reader) (KeyExchange, command.Command, error) {
+	if reader.Len() < KeyExchangeSize {
+		return KeyExchange{}, 0, errors.New("insufficient data for KeyExchange")
+	}
+
+	var kx KeyExchange
+	fields := []interface{}{&kx.CommandID, &kx.PublicKey, &kx.Cookie, &kx.HasProof, &kx.Proof}
+
+	for _, field := range fields {
+		if err := binary.Read(reader, binary.LittleEndian, field); err != nil {
+			return KeyExchange{}, 0, err
+		}
+	}
+	return kx, kx.CommandID, nil
+}
+
+// InputData serialization: command, user ID, frame count, then 20 bytes per frame
+func (s *Serializer) SerializeInputData(in InputData) ([]byte, error) {
+	if len(in.Frames) > 255 {
+		return nil, fmt.Errorf("too many input frames: %d", len(in.Frames))
+	}
+
+	buf := new(bytes.Buffer)
+	fields := []interface{}{in.CommandID, in.UserID, uint8(len(in.Frames))}
+	for _, frame := range in.Frames {
+		fields = append(fields, frame.Sequence, frame.MoveX, frame.MoveZ, frame.RotY, frame.Delta)
+	}
+
+	for _, field := range fields {
+		if err := binary.Write(buf, binary.LittleEndian, field); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *Serializer) deserializeInputData(reader *bytes.Reader) (InputData, command.Command, error) {
+	if reader.Len() < 3 { // 1+1+1
+		return InputData{}, 0, errors.New("insufficient data for InputData")
+	}
+
+	var in InputData
+	var count uint8
+	fields := []interface{}{&in.CommandID, &in.UserID, &count}
+
+	for _, field := range fields {
+		if err := binary.Read(reader, binary.LittleEndian, field); err != nil {
+			return InputData{}, 0, err
+		}
+	}
+
+	if reader.Len() < int(count)*20 { // 4+4+4+4+4 per frame
+		return InputData{}, 0, errors.New("insufficient data for InputData frames")
+	}
+
+	in.Frames = make([]InputFrame, count)
+	for i := range in.Frames {
+		frame := &in.Frames[i]
+		fields := []interface{}{&frame.Sequence, &frame.MoveX, &frame.MoveZ, &frame.RotY, &frame.Delta}
+
+		for _, field := range fields {
+			if err := binary.Read(reader, binary.LittleEndian, field); err != nil {
+				return InputData{}, 0, err
+			}
+		}
+	}
+	return in, in.CommandID, nil
+}
+
+// AuthState serialization
+func (s *Serializer) SerializeAuthState(state AuthState) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	fields := []interface{}{state.CommandID, state.UserID, state.LastSequence, state.X, state.Y, state.Z, state.RotY}
+
+	for _, field := range fields {
+		if err := binary.Write(buf, binary.LittleEndian, field); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *Serializer) deserializeAuthState(reader *bytes.Reader) (AuthState, command.Command, error) {
+	if reader.Len() < 22 { // 1+1+4+4+4+4+4
+		return AuthState{}, 0, errors.New("insufficient data for AuthState")
+	}
+
+	var state AuthState
+	fields := []interface{}{&state.CommandID, &state.UserID, &state.LastSequence, &state.X, &state.Y, &state.Z, &state.RotY}
+
+	for _, field := range fields {
+		if err := binary.Read(reader, binary.LittleEndian, field); err != nil {
+			return AuthState{}, 0, err
+		}
+	}
+	return state, state.CommandID, nil
+}
+
+// EntitySpawn serialization
+func (s *Serializer) SerializeEntitySpawn(spawn EntitySpawn) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	fields := []interface{}{spawn.CommandID, spawn.EntityID, spawn.Kind, spawn.OwnerID,
+		spawn.X, spawn.Y, spawn.Z, spawn.RotY, spawn.VX, spawn.VY, spawn.VZ}
+
+	for _, field := range fields {
+		if err := binary.Write(buf, binary.LittleEndian, field); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *Serializer) deserializeEntitySpawn(reader *bytes.Reader) (EntitySpawn, command.Command, error) {
+	if reader.Len() < 33 { // 1+2+1+1+4*7
+		return EntitySpawn{}, 0, errors.New("insufficient data for EntitySpawn")
+	}
+
+	var spawn EntitySpawn
+	fields := []interface{}{&spawn.CommandID, &spawn.EntityID, &spawn.Kind, &spawn.OwnerID,
+		&spawn.X, &spawn.Y, &spawn.Z, &spawn.RotY, &spawn.VX, &spawn.VY, &spawn.VZ}
+
+	for _, field := range fields {
+		if err := binary.Read(reader, binary.LittleEndian, field); err != nil {
+			return EntitySpawn{}, 0, err
+		}
+	}
+	return spawn, spawn.CommandID, nil
+}
+
+// EntityDespawn serialization
+func (s *Serializer) SerializeEntityDespawn(despawn EntityDespawn) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	fields := []interface{}{despawn.CommandID, despawn.EntityID}
+
+	for _, field := range fields {
+		if err := binary.Write(buf, binary.LittleEndian, field); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *Serializer) deserializeEntityDespawn(reader *bytes.Reader) (EntityDespawn, command.Command, error) {
+	if reader.Len() < 3 { // 1+2
+		return EntityDespawn{}, 0, errors.New("insufficient data for EntityDespawn")
+	}
+
+	var despawn EntityDespawn
+	fields := []interface{}{&despawn.CommandID, &despawn.EntityID}
+
+	for _, field := range fields {
+		if err := binary.Read(reader, binary.LittleEndian, field); err != nil {
+			return EntityDespawn{}, 0, err
+		}
+	}
+	return despawn, despawn.CommandID, nil
+}
+
+// EntityTransforms serialization: entity count, then per entity its ID, transform and velocity
+func (s *Serializer) SerializeEntityTransforms(transforms EntityTransforms) ([]byte, error) {
+	if len(transforms.Entities) > 0xFFFF {
+		return nil, fmt.Errorf("too many entities in transform update: %d", len(transforms.Entities))
+	}
+
+	buf := new(bytes.Buffer)
+	fields := []interface{}{transforms.CommandID, uint16(len(transforms.Entities))}
+	for _, entity := range transforms.Entities {
+		fields = append(fields, entity.EntityID, entity.X, entity.Y, entity.Z, entity.RotY, entity.VX, entity.VY, entity.VZ)
+	}
+
+	for _, field := range fields {
+		if err := binary.Write(buf, binary.LittleEndian, field); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *Serializer) deserializeEntityTransforms(reader *bytes.Reader) (EntityTransforms, command.Command, error) {
+	if reader.Len() < 3 { // 1+2
+		return EntityTransforms{}, 0, errors.New("insufficient data for EntityTransforms")
+	}
+
+	var transforms EntityTransforms
+	var count uint16
+	fields := []interface{}{&transforms.CommandID, &count}
+
+	for _, field := range fields {
+		if err := binary.Read(reader, binary.LittleEndian, field); err != nil {
+			return EntityTransforms{}, 0, err
+		}
+	}
+	if reader.Len() < int(count)*30 { // 2+4*7 per entity
+		return EntityTransforms{}, 0, errors.New("insufficient data for EntityTransforms entities")
+	}
+
+	transforms.Entities = make([]EntityTransform, count)
+	for i := range transforms.Entities {
+		entity := &transforms.Entities[i]
+		fields := []interface{}{&entity.EntityID, &entity.X, &entity.Y, &entity.Z, &entity.RotY, &entity.VX, &entity.VY, &entity.VZ}
+
+		for _, field := range fields {
+			if err := binary.Read(reader, binary.LittleEndian, field); err != nil {
+				return EntityTransforms{}, 0, err
+			}
+		}
+	}
+	return transforms, transforms.CommandID, nil
+}
+
+// PropertyUpdate serialization: entity count, then per entity its ID, property count and
+// the properties as ID, type and value
+func (s *Serializer) SerializePropertyUpdate(update PropertyUpdate) ([]byte, error) {
+	if len(update.Entities) > 0xFFFF {
+		return nil, fmt.Errorf("too many entities in property update: %d", len(update.Entities))
+	}
+
+	buf := new(bytes.Buffer)
+	fields := []interface{}{update.CommandID, uint16(len(update.Entities))}
+
+	for _, field := range fields {
+		if err := binary.Write(buf, binary.LittleEndian, field); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, entity := range update.Entities {
+		if len(entity.Values) > 255 {
+			return nil, fmt.Errorf("too many properties for entity %d: %d", entity.EntityID, len(entity.Values))
+		}
+		if err := binary.Write(buf, binary.LittleEndian, entity.EntityID); err != nil {
+			return nil, err
+		}
+		buf.WriteByte(uint8(len(entity.Values)))
+
+		for _, property := range entity.Values {
+			propertyType, ok := PropertyTypeOf(property.Value)
+			if !ok {
+				return nil, fmt.Errorf("property %d of entity %d has unsupported value %T", property.ID, entity.EntityID, property.Value)
+			}
+			buf.Write([]byte{property.ID, uint8(propertyType)})
+
+			if text, isString := property.Value.(string); isString {
+				buf.WriteByte(uint8(len(text)))
+				buf.WriteString(text)
+				continue
+			}
+			if err := binary.Write(buf, binary.LittleEndian, property.Value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *Serializer) deserializePropertyUpdate(reader *bytes.Reader) (PropertyUpdate, command.Command, error) {
+	if reader.Len() < 3 { // 1+2
+		return PropertyUpdate{}, 0, errors.New("insufficient data for PropertyUpdate")
+	}
+
+	var update PropertyUpdate
+	var count uint16
+	fields := []interface{}{&update.CommandID, &count}
+
+	for _, field := range fields {
+		if err := binary.Read(reader, binary.LittleEndian, field); err != nil {
+			return PropertyUpdate{}, 0, err
+		}
+	}
+
+	update.Entities = make([]EntityProperties, count)
+	for i := range update.Entities {
+		entity := &update.Entities[i]
+		var values uint8
+		if err := binary.Read(reader, binary.LittleEndian, &entity.EntityID); err != nil {
+			return PropertyUpdate{}, 0, err
+		}
+		if err := binary.Read(reader, binary.LittleEndian, &values); err != nil {
+			return PropertyUpdate{}, 0, err
+		}
+
+		entity.Values = make([]PropertyValue, values)
+		for j := range entity.Values {
+			property, err := readPropertyValue(reader)
+			if err != nil {
+				return PropertyUpdate{}, 0, err
+			}
+			entity.Values[j] = property
+		}
+	}
+	return update, update.CommandID, nil
+}
+
+// readPropertyValue reads one property's ID, type and value
+func readPropertyValue(reader *bytes.Reader) (PropertyValue, error) {
+	var property PropertyValue
+	var propertyType PropertyType
+	for _, field := range []interface{}{&property.ID, &propertyType} {
+		if err := binary.Read(reader, binary.LittleEndian, field); err != nil {
+			return PropertyValue{}, err
+		}
+	}
+
+	var err error
+	switch propertyType {
+	case PROPERTY_BOOL:
+		property.Value, err = readFixed[bool](reader)
+	case PROPERTY_UINT8:
+		property.Value, err = readFixed[uint8](reader)
+	case PROPERTY_INT32:
+		property.Value, err = readFixed[int32](reader)
+	case PROPERTY_FLOAT32:
+		property.Value, err = readFixed[float32](reader)
+	case PROPERTY_VEC3:
+		property.Value, err = readFixed[[3]float32](reader)
+	case PROPERTY_STRING:
+		var length uint8
+		if err := binary.Read(reader, binary.LittleEndian, &length); err != nil {
+			return PropertyValue{}, err
+		}
+		text := make([]byte, length)
+		_, err = io.ReadFull(reader, text)
+		property.Value = string(text)
+	default:
+		return PropertyValue{}, fmt.Errorf("unknown property type %d", propertyType)
+	}
+	if err != nil {
+		return PropertyValue{}, err
+	}
+	return property, nil
+}
+
+// readFixed reads one fixed-size value
+func readFixed[T any](reader *bytes.Reader) (T, error) {
+	var value T
+	err := binary.Read(reader, binary.LittleEndian, &value)
+	return value, err
+}
+
+// Reliable serialization
+func (s *Serializer) SerializeReliable(reliable Reliable) ([]byte, error) {
+	buf := make([]byte, 0, 3+len(reliable.Payload))
+	buf = append(buf, uint8(reliable.CommandID))
+	buf = binary.LittleEndian.AppendUint16(buf, reliable.Sequence)
+	return append(buf, reliable.Payload...), nil
+}
+
+func (s *Serializer) deserializeReliable(data []byte) (Reliable, command.Command, error) {
+	if len(data) < 4 { // 1+2, and at least a command byte of payload
+		return Reliable{}, 0, errors.New("insufficient data for Reliable")
+	}
+
+	reliable := Reliable{
+		CommandID: command.Command(data[0]),
+		Sequence:  binary.LittleEndian.Uint16(data[1:3]),
+		Payload:   data[3:],
+	}
+	return reliable, reliable.CommandID, nil
+}
+
+// ReliableAck serialization
+func (s *Serializer) SerializeReliableAck(ack ReliableAck) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	fields := []interface{}{ack.CommandID, ack.Sequence}
+
+	for _, field := range fields {
+		if err := binary.Write(buf, binary.LittleEndian, field); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *Serializer) deserializeReliableAck(reader *bytes.Reader) (ReliableAck, command.Command, error) {
+	if reader.Len() < 3 { // 1+2
+		return ReliableAck{}, 0, errors.New("insufficient data for ReliableAck")
+	}
+
+	var ack ReliableAck
+	fields := []interface{}{&ack.CommandID, &ack.Sequence}
+
+	for _, field := range fields {
+		if err := binary.Read(reader, binary.LittleEndian, field); err != nil {
+			return ReliableAck{}, 0, err
+		}
+	}
+	return ack, ack.CommandID, nil
+}
+
+// ChatMessage serialization
+func (s *Serializer) SerializeChatMessage(chat ChatMessage) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	fields := []interface{}{chat.CommandID, chat.UserID, chat.Channel, chat.Target, chat.Timestamp}
+
+	for _, field := range fields {
+		if err := binary.Write(buf, binary.LittleEndian, field); err != nil {
+			return nil, err
+		}
+	}
+	if err := writeString(buf, chat.Text); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *Serializer) deserializeChatMessage(reader *bytes.Reader) (ChatMessage, command.Command, error) {
+	if reader.Len() < 9 { // 1+1+1+1+4+1
+		return ChatMessage{}, 0, errors.New("insufficient data for ChatMessage")
+	}
+
+	var chat ChatMessage
+	fields := []interface{}{&chat.CommandID, &chat.UserID, &chat.Channel, &chat.Target, &chat.Timestamp}
+
+	for _, field := range fields {
+		if err := binary.Read(reader, binary.LittleEndian, field); err != nil {
+			return ChatMessage{}, 0, err
+		}
+	}
+
+	text, err := readString(reader)
+	if err != nil {
+		return ChatMessage{}, 0, err
+	}
+	chat.Text = text
+	return chat, chat.CommandID, nil
+}
+
+// PlayerInfo serialization
+func (s *Serializer) SerializePlayerInfo(info PlayerInfo) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	fields := []interface{}{info.CommandID, info.UserID, info.Color, info.Skin}
+
+	for _, field := range fields {
+		if err := binary.Write(buf, binary.LittleEndian, field); err != nil {
+			return nil, err
+		}
+	}
+	if err := writeString(buf, info.Name); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *Serializer) deserializePlayerInfo(reader *bytes.Reader) (PlayerInfo, command.Command, error) {
+	if reader.Len() < 8 { // 1+1+4+1+1
+		return PlayerInfo{}, 0, errors.New("insufficient data for PlayerInfo")
+	}
+
+	var info PlayerInfo
+	fields := []interface{}{&info.CommandID, &info.UserID, &info.Color, &info.Skin}
+
+	for _, field := range fields {
+		if err := binary.Read(reader, binary.LittleEndian, field); err != nil {
+			return PlayerInfo{}, 0, err
+		}
+	}
+
+	name, err := readString(reader)
+	if err != nil {
+		return PlayerInfo{}, 0, err
+	}
+	info.Name = name
+	return info, info.CommandID, nil
+}
+
+// ProfileRejected serialization
+func (s *Serializer) SerializeProfileRejected(pr ProfileRejected) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	fields := []interface{}{pr.CommandID, pr.Status}
+
+	for _, field := range fields {
+		if err := binary.Write(buf, binary.LittleEndian, field); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *Serializer) deserializeProfileRejected(reader *bytes.Reader) (ProfileRejected, command.Command, error) {
+	if reader.Len() < 2 { // 1+1
+		return ProfileRejected{}, 0, errors.New("insufficient data for ProfileRejected")
+	}
+
+	var pr ProfileRejected
+	fields := []interface{}{&pr.CommandID, &pr.Status}
+
+	for _, field := range fields {
+		if err := binary.Read(reader, binary.LittleEndian, field); err != nil {
+			return ProfileRejected{}, 0, err
+		}
+	}
+	return pr, pr.CommandID, nil
+}
+
+// JoinSnapshot serialization: room, player count and players, then entity count and entities
+// as in EntitySpawn without the command byte
+func (s *Serializer) SerializeJoinSnapshot(snapshot JoinSnapshot) ([]byte, error) {
+	if len(snapshot.Players) > 0xFF || len(snapshot.Entities) > 0xFFFF {
+		return nil, fmt.Errorf("too many players (%d) or entities (%d) in join snapshot", len(snapshot.Players), len(snapshot.Entities))
+	}
+
+	buf := new(bytes.Buffer)
+	fields := []interface{}{snapshot.CommandID, snapshot.RoomID, uint8(len(snapshot.Players))}
+	for _, player := range snapshot.Players {
+		fields = append(fields, player.UserID, player.X, player.Y, player.Z, player.RotY)
+	}
+	fields = append(fields, uint16(len(snapshot.Entities)))
+	for _, entity := range snapshot.Entities {
+		fields = append(fields, entity.EntityID, entity.Kind, entity.OwnerID,
+			entity.X, entity.Y, entity.Z, entity.RotY, entity.VX, entity.VY, entity.VZ)
+	}
+
+	for _, field := range fields {
+		if err := binary.Write(buf, binary.LittleEndian, field); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *Serializer) deserializeJoinSnapshot(reader *bytes.Reader) (JoinSnapshot, command.Command, error) {
+	if reader.Len() < 5 { // 1+1+1+2
+		return JoinSnapshot{}, 0, errors.New("insufficient data for JoinSnapshot")
+	}
+
+	var snapshot JoinSnapshot
+	var playerCount uint8
+	fields := []interface{}{&snapshot.CommandID, &snapshot.RoomID, &playerCount}
+
+	for _, field := range fields {
+		if err := binary.Read(reader, binary.LittleEndian, field); err != nil {
+			return JoinSnapshot{}, 0, err
+		}
+	}
+
+	snapshot.Players = make([]PlayerState, playerCount)
+	for i := range snapshot.Players {
+		player := &snapshot.Players[i]
+		for _, field := range []interface{}{&player.UserID, &player.X, &player.Y, &player.Z, &player.RotY} {
+			if err := binary.Read(reader, binary.LittleEndian, field); err != nil {
+				return JoinSnapshot{}, 0, err
+			}
+		}
+	}
+
+	var entityCount uint16
+	if err := binary.Read(reader, binary.LittleEndian, &entityCount); err != nil {
+		return JoinSnapshot{}, 0, err
+	}
+	if reader.Len() < int(entityCount)*32 { // 2+1+1+4*7 per entity
+		return JoinSnapshot{}, 0, errors.New("insufficient data for JoinSnapshot entities")
+	}
+
+	snapshot.Entities = make([]EntitySpawn, entityCount)
+	for i := range snapshot.Entities {
+		entity := &snapshot.Entities[i]
+		entity.CommandID = command.ENTITY_SPAWN
+		for _, field := range []interface{}{&entity.EntityID, &entity.Kind, &entity.OwnerID,
+			&entity.X, &entity.Y, &entity.Z, &entity.RotY, &entity.VX, &entity.VY, &entity.VZ} {
+			if err := binary.Read(reader, binary.LittleEndian, field); err != nil {
+				return JoinSnapshot{}, 0, err
+			}
+		}
+	}
+	return snapshot, snapshot.CommandID, nil
+}
+
+// PlayerEvent serialization
+func (s *Serializer) SerializePlayerEvent(event PlayerEvent) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	fields := []interface{}{event.CommandID, event.UserID}
+
+	for _, field := range fields {
+		if err := binary.Write(buf, binary.LittleEndian, field); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *Serializer) deserializePlayerEvent(reader *bytes.Reader) (PlayerEvent, command.Command, error) {
+	if reader.Len() < 2 { // 1+1
+		return PlayerEvent{}, 0, errors.New("insufficient data for PlayerEvent")
+	}
+
+	var event PlayerEvent
+	fields := []interface{}{&event.CommandID, &event.UserID}
+
+	for _, field := range fields {
+		if err := binary.Read(reader, binary.LittleEndian, field); err != nil {
+			return PlayerEvent{}, 0, err
+		}
+	}
+	return event, event.CommandID, nil
+}
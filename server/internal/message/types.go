@@ -46,12 +46,39 @@ type DefaultRTT struct {
 	TimestampRTT uint32
 }
 
+// Ping is sent by the server to measure a client's round-trip time.
+// The client echoes it unchanged as a PONG.
+type Ping struct {
+	CommandID command.Command
+	Sequence  uint32
+}
+
 // UserAssignment tells a client their assigned user ID
 type UserAssignment struct {
 	CommandID command.Command
 	UserID    uint8
 }
 
+// PortRequest asks the server to register the sender. Cookie echoes the last
+// CONNECT_CHALLENGE; a zero cookie asks for a challenge. The profile is optional,
+// requests without one register under a default name. Secret is a random value the
+// client keeps between sessions so its progress can be restored; zero plays anonymously.
+type PortRequest struct {
+	CommandID command.Command
+	Cookie    [16]byte
+	Color     uint32 // RGBA8, 0 for the client's default
+	Skin      uint8
+	Name      string // empty for a default name
+	Secret    [16]byte
+}
+
+// ConnectChallenge carries a connect cookie the client must echo in its PortRequest.
+// It is never larger than the PortRequest that triggered it.
+type ConnectChallenge struct {
+	CommandID command.Command
+	Cookie    [16]byte
+}
+
 // PortAssignment tells a client their assigned port for receiving updates
 type PortAssignment struct {
 	CommandID command.Command
@@ -59,6 +86,381 @@ type PortAssignment struct {
 	Port      uint16
 }
 
+// InterestEvent tells a client that another player entered or left its area of interest
+type InterestEvent struct {
+	CommandID command.Command
+	UserID    uint8
+}
+
+// RoomStatus is the result code of a room request
+type RoomStatus uint8
+
+const (
+	ROOM_OK RoomStatus = iota
+	ROOM_NOT_FOUND
+	ROOM_FULL
+	ROOM_ERROR
+)
+
+// RoomCreate asks the server to open a new room and move the sender into it
+type RoomCreate struct {
+	CommandID command.Command
+	UserID    uint8
+	Capacity  uint8 // 0 means unlimited
+	Name      string
+}
+
+// RoomJoin asks the server to move the sender into an existing room
+type RoomJoin struct {
+	CommandID command.Command
+	UserID    uint8
+	RoomID    uint8
+}
+
+// RoomRequest is a room command without payload (ROOM_LEAVE, ROOM_LIST_REQUEST)
+type RoomRequest struct {
+	CommandID command.Command
+	UserID    uint8
+}
+
+// RoomEntry describes one room in a RoomList
+type RoomEntry struct {
+	RoomID      uint8
+	PlayerCount uint8
+	Capacity    uint8
+	Name        string
+}
+
+// RoomList is sent to a client in response to ROOM_LIST_REQUEST
+type RoomList struct {
+	CommandID command.Command
+	Rooms     []RoomEntry
+}
+
+// RoomAssignment tells a client which room they are in after a room request
+type RoomAssignment struct {
+	CommandID command.Command
+	UserID    uint8
+	RoomID    uint8
+	Status    RoomStatus
+}
+
+// MatchRequest puts the sender into the matchmaking queue
+type MatchRequest struct {
+	CommandID command.Command
+	UserID    uint8
+	RTTMillis uint16 // Average RTT measured by the client, ignored by the server which measures its own
+	Skill     uint16
+	HasSkill  bool // whether Skill is set; requests without it treat a zero Skill as unset
+}
+
+// MatchCancel removes the sender from the matchmaking queue
+type MatchCancel struct {
+	CommandID command.Command
+	UserID    uint8
+}
+
+// MatchAssignment tells a client which room their match was placed in
+type MatchAssignment struct {
+	CommandID   command.Command
+	UserID      uint8
+	RoomID      uint8
+	PlayerCount uint8
+}
+
+// SnapshotMask flags which fields of a SnapshotEntry are present
+type SnapshotMask uint8
+
+const (
+	SNAPSHOT_X       SnapshotMask = 1 << iota // 0x01
+	SNAPSHOT_Y                                // 0x02
+	SNAPSHOT_Z                                // 0x04
+	SNAPSHOT_ROT_Y                            // 0x08
+	SNAPSHOT_KIND                             // 0x10, the entity kind precedes the fields
+	SNAPSHOT_REMOVED SnapshotMask = 0x80      // Entity left the snapshot, no fields follow
+
+	SNAPSHOT_ALL = SNAPSHOT_X | SNAPSHOT_Y | SNAPSHOT_Z | SNAPSHOT_ROT_Y
+)
+
+// SnapshotEntry is the delta of one entity against the baseline snapshot.
+// EntityIDs 1-255 are players keyed by user ID. Only the fields flagged in Mask are sent on the wire.
+type SnapshotEntry struct {
+	EntityID uint16
+	Mask     SnapshotMask
+	Kind     uint8
+	X, Y, Z  float32
+	RotY     float32
+}
+
+// Size is the number of bytes the entry takes on the wire
+func (e SnapshotEntry) Size() int {
+	size := 3 // entity ID and mask
+	if e.Mask&SNAPSHOT_REMOVED != 0 {
+		return size
+	}
+	if e.Mask&SNAPSHOT_KIND != 0 {
+		size++
+	}
+	for _, flag := range []SnapshotMask{SNAPSHOT_X, SNAPSHOT_Y, SNAPSHOT_Z, SNAPSHOT_ROT_Y} {
+		if e.Mask&flag != 0 {
+			size += 4
+		}
+	}
+	return size
+}
+
+// SnapshotHeaderSize is the size of a SnapshotData without entries, 1+4+4+2
+const SnapshotHeaderSize = 11
+
+// SnapshotData is a delta-compressed world snapshot sent to a client.
+// Baseline is the acknowledged snapshot it was encoded against, 0 for a full snapshot.
+type SnapshotData struct {
+	CommandID command.Command
+	Sequence  uint32
+	Baseline  uint32
+	Entries   []SnapshotEntry
+}
+
+// SnapshotAck tells the server which snapshot a client received
+type SnapshotAck struct {
+	CommandID command.Command
+	UserID    uint8
+	Sequence  uint32
+}
+
+// Fragment is one piece of a message too large for a single datagram
+type Fragment struct {
+	CommandID command.Command
+	MessageID uint16
+	Index     uint8
+	Count     uint8
+	Payload   []byte
+}
+
+// KeyExchange carries an X25519 public key: the client's in the request, the server's in the reply.
+// A request echoes the connect cookie of the last challenge, and one replacing an established
+// session carries the proof of that session's rekey key. Requests are padded to the size of the
+// reply, which carries the server's Ed25519 signature of both public keys.
+type KeyExchange struct {
+	CommandID command.Command
+	PublicKey [32]byte
+	Cookie    [16]byte // request only, all zeros to ask for a challenge
+	HasProof  bool     // request only
+	Proof     [32]byte // request only, when HasProof
+	Signature [64]byte // reply only
+}
+
+// KeyExchangeSize is the size of a key exchange reply, 1+32+64, and the least a request is padded to
+const KeyExchangeSize = 97
+
+// InputFrame is one tick of movement input sampled by the client
+type InputFrame struct {
+	Sequence     uint32
+	MoveX, MoveZ float32 // movement direction, the server clamps its length to 1
+	RotY         float32
+	Delta        float32 // seconds the input was held
+}
+
+// InputData carries a client's most recent input frames, oldest first.
+// Frames are resent until acknowledged so a lost datagram loses no input.
+type InputData struct {
+	CommandID command.Command
+	UserID    uint8
+	Frames    []InputFrame
+}
+
+// AuthState is a player's authoritative state after the server processed its input up to LastSequence
+type AuthState struct {
+	CommandID    command.Command
+	UserID       uint8
+	LastSequence uint32
+	X, Y, Z      float32
+	RotY         float32
+}
+
+// EntitySpawn introduces an entity to a client: its kind, owner, transform and velocity.
+// Clients move it by Velocity until a snapshot or ENTITY_TRANSFORM says otherwise.
+type EntitySpawn struct {
+	CommandID  command.Command
+	EntityID   uint16
+	Kind       uint8
+	OwnerID    uint8 // 0 for entities spawned by the server
+	X, Y, Z    float32
+	RotY       float32
+	VX, VY, VZ float32
+}
+
+// EntityDespawn tells a client an entity was destroyed or left its view
+type EntityDespawn struct {
+	CommandID command.Command
+	EntityID  uint16
+}
+
+// EntityTransform is where an entity is and how fast it moves
+type EntityTransform struct {
+	EntityID   uint16
+	X, Y, Z    float32
+	RotY       float32
+	VX, VY, VZ float32
+}
+
+// EntityTransforms corrects the entities clients move by their velocity when snapshots are off.
+// It is sent unreliably; clients ignore entities they were not spawned.
+type EntityTransforms struct {
+	CommandID command.Command
+	Entities  []EntityTransform
+}
+
+// PropertyType tags the encoding of a replicated property value on the wire
+type PropertyType uint8
+
+const (
+	PROPERTY_BOOL    PropertyType = iota // 1 byte
+	PROPERTY_UINT8                       // 1 byte
+	PROPERTY_INT32                       // 4 bytes
+	PROPERTY_FLOAT32                     // 4 bytes
+	PROPERTY_VEC3                        // 3 x 4 bytes
+	PROPERTY_STRING                      // 1 length byte, then up to 255 bytes
+)
+
+// PropertyValue is one replicated property. Value is a bool, uint8, int32, float32, [3]float32 or string.
+type PropertyValue struct {
+	ID    uint8
+	Value interface{}
+}
+
+// PropertyTypeOf returns the wire type of a property value and whether it can be replicated
+func PropertyTypeOf(value interface{}) (PropertyType, bool) {
+	switch v := value.(type) {
+	case bool:
+		return PROPERTY_BOOL, true
+	case uint8:
+		return PROPERTY_UINT8, true
+	case int32:
+		return PROPERTY_INT32, true
+	case float32:
+		return PROPERTY_FLOAT32, true
+	case [3]float32:
+		return PROPERTY_VEC3, true
+	case string:
+		return PROPERTY_STRING, len(v) <= 255
+	default:
+		return 0, false
+	}
+}
+
+// Size is the number of bytes the property takes on the wire, including its ID and type
+func (p PropertyValue) Size() int {
+	switch v := p.Value.(type) {
+	case bool, uint8:
+		return 3
+	case int32, float32:
+		return 6
+	case [3]float32:
+		return 14
+	case string:
+		return 3 + len(v)
+	default:
+		return 0
+	}
+}
+
+// EntityProperties are the changed properties of one entity
+type EntityProperties struct {
+	EntityID uint16
+	Values   []PropertyValue
+}
+
+// PropertyUpdate carries property changes for any number of entities
+type PropertyUpdate struct {
+	CommandID command.Command
+	Entities  []EntityProperties
+}
+
+// PlayerInfo tells clients how to present a player
+type PlayerInfo struct {
+	CommandID command.Command
+	UserID    uint8
+	Color     uint32 // RGBA8, 0 for the client's default
+	Skin      uint8
+	Name      string
+}
+
+// ProfileStatus is the reason a PortRequest's profile was rejected
+type ProfileStatus uint8
+
+const (
+	PROFILE_OK ProfileStatus = iota
+	PROFILE_NAME_INVALID
+	PROFILE_NAME_TAKEN
+	PROFILE_BANNED
+)
+
+// ProfileRejected answers a PortRequest whose profile was not accepted; the client was not registered
+type ProfileRejected struct {
+	CommandID command.Command
+	Status    ProfileStatus
+}
+
+// PlayerState is where a player is in a JoinSnapshot
+type PlayerState struct {
+	UserID uint8
+	X      float32
+	Y      float32
+	Z      float32
+	RotY   float32
+}
+
+// JoinSnapshot is everything a newly registered client can see: the players and entities
+// in its area of interest. Entity properties follow in ENTITY_PROPERTIES.
+type JoinSnapshot struct {
+	CommandID command.Command
+	RoomID    uint8
+	Players   []PlayerState
+	Entities  []EntitySpawn // CommandID is not sent
+}
+
+// PlayerEvent tells clients a player connected to or left the server (PLAYER_JOINED, PLAYER_LEFT)
+type PlayerEvent struct {
+	CommandID command.Command
+	UserID    uint8
+}
+
+// Reliable wraps a message that must arrive, in order and once. Payload is a complete message
+// and runs to the end of the datagram.
+type Reliable struct {
+	CommandID command.Command
+	Sequence  uint16
+	Payload   []byte
+}
+
+// ReliableAck confirms a Reliable message arrived
+type ReliableAck struct {
+	CommandID command.Command
+	Sequence  uint16
+}
+
+// ChatChannel selects who receives a chat message
+type ChatChannel uint8
+
+const (
+	CHAT_GLOBAL  ChatChannel = iota // every connected player
+	CHAT_ROOM                       // players in the sender's room
+	CHAT_WHISPER                    // one player, given by Target
+	CHAT_SYSTEM                     // server notices, never sent by clients
+)
+
+// ChatMessage is a line of chat. Clients send it with their own UserID; the server relays it
+// with the sender's ID and its own timestamp.
+type ChatMessage struct {
+	CommandID command.Command
+	UserID    uint8
+	Channel   ChatChannel
+	Target    uint8  // recipient of a whisper, otherwise 0
+	Timestamp uint32 // Unix seconds, set by the server
+	Text      string // at most 255 bytes
+}
+
 // Print methods for debugging
 func (p PositionData) String() string {
 	return fmt.Sprintf("PositionData{UserID: %d, X: %.2f, Y: %.2f, Z: %.2f, RotY: %.2f}",
@@ -0,0 +1,428 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BanKind tells whether a ban applies to an IP address or to a session identity
+type BanKind string
+
+const (
+	BanAddress  BanKind = "address"
+	BanIdentity BanKind = "identity"
+)
+
+// Ban is one entry of the ban list. A zero Expires means the ban never expires.
+type Ban struct {
+	Kind    BanKind   `json:"kind"`
+	Key     string    `json:"key"`
+	Reason  string    `json:"reason,omitempty"`
+	Expires time.Time `json:"expires,omitempty"`
+}
+
+// Active reports whether the ban still applies at the given time
+func (b Ban) Active(now time.Time) bool {
+	return b.Expires.IsZero() || now.Before(b.Expires)
+}
+
+// AccessListData is the on-disk and admin interface format of the access list
+type AccessListData struct {
+	Allow []string `json:"allow"`
+	Deny  []string `json:"deny"`
+	Bans  []Ban    `json:"bans"`
+}
+
+// AccessList filters packets by IP/CIDR allow and deny lists and a ban list with expiry.
+// Every change is written to disk so bans survive restarts. Writes happen outside mu so packet
+// filtering never waits for the disk, and a change whose write fails is taken back.
+type AccessList struct {
+	allow   []*net.IPNet
+	deny    []*net.IPNet
+	bans    map[string]Ban // banKey(kind, key) -> ban
+	path    string
+	version uint64 // bumped by every change, guarded by mu
+	mu      sync.RWMutex
+
+	saveMu sync.Mutex // serializes writes to path
+	saved  uint64     // version last written, guarded by saveMu
+}
+
+// NewAccessList creates an access list persisted at path; an empty path keeps it in memory only
+func NewAccessList(path string) *AccessList {
+	return &AccessList{
+		bans: make(map[string]Ban),
+		path: path,
+	}
+}
+
+// Load reads the access list from disk. A missing file is not an error.
+func (al *AccessList) Load() error {
+	if al.path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(al.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var file AccessListData
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", al.path, err)
+	}
+
+	allow, err := parseCIDRs(file.Allow)
+	if err != nil {
+		return err
+	}
+	deny, err := parseCIDRs(file.Deny)
+	if err != nil {
+		return err
+	}
+
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	al.allow, al.deny = allow, deny
+	al.bans = make(map[string]Ban)
+	for _, ban := range file.Bans {
+		al.bans[banKey(ban.Kind, ban.Key)] = ban
+	}
+	return nil
+}
+
+// AllowedAddress reports whether packets from ip may be processed at all.
+// Denied and banned addresses are rejected; a non-empty allow list rejects everything not on it.
+func (al *AccessList) AllowedAddress(ip net.IP, now time.Time) bool {
+	al.mu.RLock()
+	defer al.mu.RUnlock()
+
+	if len(al.allow) > 0 && !containsIP(al.allow, ip) {
+		return false
+	}
+	if containsIP(al.deny, ip) {
+		return false
+	}
+	ban, banned := al.bans[banKey(BanAddress, ip.String())]
+	return !banned || !ban.Active(now)
+}
+
+// IdentityBanned reports whether a session identity is banned
+func (al *AccessList) IdentityBanned(identity string, now time.Time) bool {
+	al.mu.RLock()
+	defer al.mu.RUnlock()
+
+	ban, banned := al.bans[banKey(BanIdentity, identity)]
+	return banned && ban.Active(now)
+}
+
+// Admits reports whether a connected player may stay, by address and by identity
+func (al *AccessList) Admits(ip net.IP, identity string, now time.Time) bool {
+	if !al.AllowedAddress(ip, now) {
+		return false
+	}
+	return identity == "" || !al.IdentityBanned(identity, now)
+}
+
+// AddBan adds or replaces a ban. The ban applies at once and is written to disk in the background,
+// so callers on the packet path never wait for the disk. The returned channel receives the result
+// of the write; if it failed, the ban has been taken back.
+func (al *AccessList) AddBan(ban Ban) (<-chan error, error) {
+	if ban.Kind != BanAddress && ban.Kind != BanIdentity {
+		return nil, fmt.Errorf("unknown ban kind %q", ban.Kind)
+	}
+	if ban.Kind == BanAddress {
+		ip := net.ParseIP(ban.Key)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address %q", ban.Key)
+		}
+		ban.Key = ip.String()
+	}
+
+	key := banKey(ban.Kind, ban.Key)
+	al.mu.Lock()
+	previous, replaced := al.bans[key]
+	al.bans[key] = ban
+	al.version++
+	al.mu.Unlock()
+
+	saved := make(chan error, 1)
+	go func() {
+		err := al.persist()
+		if err != nil {
+			al.mu.Lock()
+			// A newer change to the same ban wins over the rollback
+			if al.bans[key] == ban {
+				if replaced {
+					al.bans[key] = previous
+				} else {
+					delete(al.bans, key)
+				}
+				al.version++
+			}
+			al.mu.Unlock()
+		}
+		saved <- err
+	}()
+	return saved, nil
+}
+
+// RemoveBan lifts a ban and reports whether one existed. The ban is restored if it cannot be written.
+func (al *AccessList) RemoveBan(kind BanKind, key string) (bool, error) {
+	if kind == BanAddress {
+		if ip := net.ParseIP(key); ip != nil {
+			key = ip.String()
+		}
+	}
+
+	al.mu.Lock()
+	ban, exists := al.bans[banKey(kind, key)]
+	if exists {
+		delete(al.bans, banKey(kind, key))
+		al.version++
+	}
+	al.mu.Unlock()
+	if !exists {
+		return false, nil
+	}
+
+	if err := al.persist(); err != nil {
+		al.mu.Lock()
+		if _, readded := al.bans[banKey(kind, key)]; !readded {
+			al.bans[banKey(kind, key)] = ban
+			al.version++
+		}
+		al.mu.Unlock()
+		return false, err
+	}
+	return true, nil
+}
+
+// AddAllow adds a CIDR (or single IP) to the allow list
+func (al *AccessList) AddAllow(cidr string) error {
+	return al.addNetwork(&al.allow, cidr)
+}
+
+// RemoveAllow removes a CIDR from the allow list
+func (al *AccessList) RemoveAllow(cidr string) (bool, error) {
+	return al.removeNetwork(&al.allow, cidr)
+}
+
+// AddDeny adds a CIDR (or single IP) to the deny list
+func (al *AccessList) AddDeny(cidr string) error {
+	return al.addNetwork(&al.deny, cidr)
+}
+
+// RemoveDeny removes a CIDR from the deny list
+func (al *AccessList) RemoveDeny(cidr string) (bool, error) {
+	return al.removeNetwork(&al.deny, cidr)
+}
+
+// PruneExpired drops bans that are no longer active. Expired bans no longer apply, so they are
+// not restored if the write fails; the next successful write drops them from disk.
+func (al *AccessList) PruneExpired(now time.Time) error {
+	al.mu.Lock()
+	pruned := false
+	for key, ban := range al.bans {
+		if !ban.Active(now) {
+			delete(al.bans, key)
+			pruned = true
+		}
+	}
+	if pruned {
+		al.version++
+	}
+	al.mu.Unlock()
+
+	if !pruned {
+		return nil
+	}
+	return al.persist()
+}
+
+// Snapshot returns the current lists in their on-disk form
+func (al *AccessList) Snapshot() AccessListData {
+	al.mu.RLock()
+	defer al.mu.RUnlock()
+
+	return al.fileLocked()
+}
+
+func (al *AccessList) addNetwork(list *[]*net.IPNet, cidr string) error {
+	network, err := parseCIDR(cidr)
+	if err != nil {
+		return err
+	}
+
+	al.mu.Lock()
+	for _, existing := range *list {
+		if existing.String() == network.String() {
+			al.mu.Unlock()
+			return nil
+		}
+	}
+	*list = append(*list, network)
+	al.version++
+	al.mu.Unlock()
+
+	if err := al.persist(); err != nil {
+		al.mu.Lock()
+		*list = removeIPNet(*list, network)
+		al.version++
+		al.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (al *AccessList) removeNetwork(list *[]*net.IPNet, cidr string) (bool, error) {
+	network, err := parseCIDR(cidr)
+	if err != nil {
+		return false, err
+	}
+
+	al.mu.Lock()
+	remaining := removeIPNet(*list, network)
+	removed := len(remaining) < len(*list)
+	if removed {
+		*list = remaining
+		al.version++
+	}
+	al.mu.Unlock()
+	if !removed {
+		return false, nil
+	}
+
+	if err := al.persist(); err != nil {
+		al.mu.Lock()
+		*list = append(*list, network)
+		al.version++
+		al.mu.Unlock()
+		return false, err
+	}
+	return true, nil
+}
+
+// removeIPNet returns a copy of list without network, leaving list itself untouched
+// so lookups that raced with the change never see a half-shifted slice
+func removeIPNet(list []*net.IPNet, network *net.IPNet) []*net.IPNet {
+	remaining := make([]*net.IPNet, 0, len(list))
+	for _, existing := range list {
+		if existing.String() != network.String() {
+			remaining = append(remaining, existing)
+		}
+	}
+	return remaining
+}
+
+func (al *AccessList) fileLocked() AccessListData {
+	file := AccessListData{
+		Allow: make([]string, 0, len(al.allow)),
+		Deny:  make([]string, 0, len(al.deny)),
+		Bans:  make([]Ban, 0, len(al.bans)),
+	}
+	for _, network := range al.allow {
+		file.Allow = append(file.Allow, network.String())
+	}
+	for _, network := range al.deny {
+		file.Deny = append(file.Deny, network.String())
+	}
+	for _, ban := range al.bans {
+		file.Bans = append(file.Bans, ban)
+	}
+	sort.Slice(file.Bans, func(i, j int) bool {
+		return banKey(file.Bans[i].Kind, file.Bans[i].Key) < banKey(file.Bans[j].Kind, file.Bans[j].Key)
+	})
+	return file
+}
+
+// persist writes the current lists atomically via a temporary file without holding mu.
+// Concurrent changes are coalesced: a write that finds a newer version already on disk is skipped.
+func (al *AccessList) persist() error {
+	if al.path == "" {
+		return nil
+	}
+
+	al.saveMu.Lock()
+	defer al.saveMu.Unlock()
+
+	al.mu.RLock()
+	file, version := al.fileLocked(), al.version
+	al.mu.RUnlock()
+	if version <= al.saved {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := al.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, al.path); err != nil {
+		return err
+	}
+	al.saved = version
+	return nil
+}
+
+func banKey(kind BanKind, key string) string {
+	return string(kind) + ":" + key
+}
+
+// parseCIDR accepts either CIDR notation or a single IP address
+func parseCIDR(cidr string) (*net.IPNet, error) {
+	if !strings.Contains(cidr, "/") {
+		ip := net.ParseIP(cidr)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address %q", cidr)
+		}
+		bits := 128
+		if ip.To4() != nil {
+			ip = ip.To4()
+			bits = 32
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CIDR %q: %w", cidr, err)
+	}
+	return network, nil
+}
+
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		network, err := parseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
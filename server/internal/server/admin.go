@@ -0,0 +1,289 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"server/internal/game"
+	"strconv"
+	"time"
+)
+
+// banRequest is the body of POST /bans. An empty Duration bans permanently.
+type banRequest struct {
+	Kind     BanKind `json:"kind"`
+	Key      string  `json:"key"`
+	Reason   string  `json:"reason"`
+	Duration string  `json:"duration"`
+}
+
+// networkRequest is the body of POST /allow and POST /deny
+type networkRequest struct {
+	CIDR string `json:"cidr"`
+}
+
+// entityRequest is the body of POST /entities. An empty Lifetime keeps the entity until it is deleted.
+type entityRequest struct {
+	Kind       game.EntityKind            `json:"kind"`
+	RoomID     uint8                      `json:"roomId"`
+	Position   game.Vec3                  `json:"position"`
+	RotY       float32                    `json:"rotY"`
+	Velocity   *game.Vec3                 `json:"velocity"`
+	Lifetime   string                     `json:"lifetime"`
+	Properties map[string]json.RawMessage `json:"properties"`
+}
+
+// adminHandler exposes the access list, the movement audit log, shard load, entities and
+// lag-compensated views over HTTP:
+//
+//	GET    /access                  current allow, deny and ban lists
+//	POST   /bans                    {"kind":"address","key":"1.2.3.4","reason":"...","duration":"1h"}
+//	DELETE /bans/{kind}/{key}       lift a ban
+//	POST   /allow, /deny            {"cidr":"10.0.0.0/8"}
+//	DELETE /allow, /deny?cidr=...   remove a network
+//	GET    /audit                   recent movement violations
+//	GET    /shards                  queue length and dropped jobs of every shard
+//	GET    /players/{id}/rewound    where the player saw the others in its room, for hit disputes
+//	GET    /entities                non-player entities
+//	POST   /entities                {"kind":"pickup","roomId":0,"position":[1,1,2],"velocity":[0,0,1],"lifetime":"10s"}
+//	PATCH  /entities/{id}           {"health":50} set replicated properties
+//	DELETE /entities/{id}           despawn an entity
+func (s *Server) adminHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /access", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.access.Snapshot())
+	})
+
+	mux.HandleFunc("GET /audit", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.audit.Recent())
+	})
+
+	mux.HandleFunc("GET /shards", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.shards.Stats())
+	})
+
+	mux.HandleFunc("GET /players/{id}/rewound", func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseUint(r.PathValue("id"), 10, 8)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid player ID %q", r.PathValue("id")), http.StatusBadRequest)
+			return
+		}
+		rewound, exists := s.clientManager.Rewound(uint8(id), time.Now())
+		if !exists {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, rewound)
+	})
+
+	mux.HandleFunc("POST /bans", func(w http.ResponseWriter, r *http.Request) {
+		var req banRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ban := Ban{Kind: req.Kind, Key: req.Key, Reason: req.Reason}
+		if req.Duration != "" {
+			duration, err := time.ParseDuration(req.Duration)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			ban.Expires = time.Now().Add(duration)
+		}
+
+		saved, err := s.access.AddBan(ban)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := <-saved; err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.disconnectDisallowed()
+
+		log.Printf("Admin: banned %s %s (%s)", ban.Kind, ban.Key, ban.Reason)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	mux.HandleFunc("DELETE /bans/{kind}/{key}", func(w http.ResponseWriter, r *http.Request) {
+		removed, err := s.access.RemoveBan(BanKind(r.PathValue("kind")), r.PathValue("key"))
+		writeRemoved(w, removed, err)
+	})
+
+	mux.HandleFunc("GET /entities", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.clientManager.GetEntities())
+	})
+
+	mux.HandleFunc("POST /entities", func(w http.ResponseWriter, r *http.Request) {
+		var req entityRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Kind == game.EntityPlayer {
+			http.Error(w, "players cannot be spawned as entities", http.StatusBadRequest)
+			return
+		}
+
+		entity := game.Entity{
+			Kind:      req.Kind,
+			RoomID:    req.RoomID,
+			Transform: game.Transform{Position: req.Position, RotY: req.RotY},
+		}
+		if req.Velocity != nil {
+			entity.Motion = &game.Motion{Velocity: *req.Velocity}
+		}
+		if req.Lifetime != "" {
+			lifetime, err := time.ParseDuration(req.Lifetime)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			entity.Lifetime = &game.Lifetime{Expires: time.Now().Add(lifetime)}
+		}
+
+		spawned, err := s.clientManager.SpawnEntity(entity)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := setProperties(spawned.Properties, req.Properties); err != nil {
+			s.clientManager.DespawnEntity(spawned.ID)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		log.Printf("Admin: spawned %s %d in room %d", spawned.Kind, spawned.ID, spawned.RoomID)
+		writeJSON(w, http.StatusCreated, spawned)
+	})
+
+	mux.HandleFunc("PATCH /entities/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseEntityID(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		entity, exists := s.clientManager.GetEntity(id)
+		if !exists {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+
+		var properties map[string]json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&properties); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := setProperties(entity.Properties, properties); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, http.StatusOK, entity)
+	})
+
+	mux.HandleFunc("DELETE /entities/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseEntityID(r.PathValue("id"))
+		if err != nil {
+			writeRemoved(w, false, err)
+			return
+		}
+		_, removed := s.clientManager.DespawnEntity(id)
+		writeRemoved(w, removed, nil)
+	})
+
+	networkRoutes := []struct {
+		path   string
+		add    func(string) error
+		remove func(string) (bool, error)
+	}{
+		{"/allow", s.access.AddAllow, s.access.RemoveAllow},
+		{"/deny", s.access.AddDeny, s.access.RemoveDeny},
+	}
+	for _, route := range networkRoutes {
+		mux.HandleFunc("POST "+route.path, func(w http.ResponseWriter, r *http.Request) {
+			var req networkRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := route.add(req.CIDR); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			s.disconnectDisallowed()
+
+			log.Printf("Admin: added %s to %s", req.CIDR, route.path)
+			w.WriteHeader(http.StatusNoContent)
+		})
+
+		mux.HandleFunc("DELETE "+route.path, func(w http.ResponseWriter, r *http.Request) {
+			removed, err := route.remove(r.URL.Query().Get("cidr"))
+			writeRemoved(w, removed, err)
+		})
+	}
+
+	return mux
+}
+
+// disconnectAddress removes every player connected from ip
+func (s *Server) disconnectAddress(ip net.IP, reason string) {
+	for _, player := range s.clientManager.Players() {
+		if player.Address.IP.Equal(ip) {
+			s.disconnectPlayer(player.ID, reason)
+		}
+	}
+}
+
+// disconnectDisallowed removes every player the access list no longer admits, by address or identity
+func (s *Server) disconnectDisallowed() {
+	now := time.Now()
+	for _, player := range s.clientManager.Players() {
+		if !s.access.Admits(player.Address.IP, player.Identity, now) {
+			s.disconnectPlayer(player.ID, "denied")
+		}
+	}
+}
+
+// parseEntityID parses the ID of a non-player entity
+func parseEntityID(text string) (game.EntityID, error) {
+	id, err := strconv.ParseUint(text, 10, 16)
+	if err != nil || game.EntityID(id).IsPlayer() {
+		return 0, fmt.Errorf("invalid entity ID %q", text)
+	}
+	return game.EntityID(id), nil
+}
+
+// setProperties sets JSON-encoded values on an entity's properties
+func setProperties(set *game.PropertySet, values map[string]json.RawMessage) error {
+	for name, value := range values {
+		if err := set.SetJSON(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Admin: failed to write response: %v", err)
+	}
+}
+
+func writeRemoved(w http.ResponseWriter, removed bool, err error) {
+	switch {
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case !removed:
+		http.Error(w, "not found", http.StatusNotFound)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,192 @@
+package server
+
+import (
+	"errors"
+	"regexp"
+	"server/internal/message"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// ChatConfig limits what players may say and how much of it is kept for late joiners
+type ChatConfig struct {
+	MaxLength   int          // longest accepted message in bytes, at most 255
+	Rate        BucketConfig // messages per second and player
+	HistorySize int          // messages kept globally and per room, zero keeps none
+	Filter      ChatFilter   // nil relays every message unchanged
+}
+
+// ChatFilter inspects a message before it is relayed. It returns the text to relay, which may
+// be censored, or false to reject the message.
+type ChatFilter func(sender uint8, text string) (string, bool)
+
+var (
+	ErrChatEmpty       = errors.New("message is empty")
+	ErrChatTooLong     = errors.New("message is too long")
+	ErrChatInvalid     = errors.New("message is not valid UTF-8")
+	ErrChatRateLimited = errors.New("sending messages too fast")
+	ErrChatFiltered    = errors.New("message was blocked")
+)
+
+// WordFilter returns a ChatFilter that masks the given words, ignoring case, with asterisks
+func WordFilter(words []string) ChatFilter {
+	if len(words) == 0 {
+		return nil
+	}
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		quoted[i] = regexp.QuoteMeta(word)
+	}
+	pattern := regexp.MustCompile(`(?i)\b(` + strings.Join(quoted, "|") + `)\b`)
+
+	return func(_ uint8, text string) (string, bool) {
+		return pattern.ReplaceAllStringFunc(text, func(word string) string {
+			return strings.Repeat("*", utf8.RuneCountInString(word))
+		}), true
+	}
+}
+
+// Chat validates chat messages and keeps the recent history of the global and room channels
+type Chat struct {
+	config  ChatConfig
+	buckets map[uint8]*TokenBucket
+	global  []message.ChatMessage
+	rooms   map[uint8][]message.ChatMessage
+	mu      sync.Mutex
+}
+
+// NewChat creates a chat with empty history
+func NewChat(config ChatConfig) *Chat {
+	return &Chat{
+		config:  config,
+		buckets: make(map[uint8]*TokenBucket),
+		rooms:   make(map[uint8][]message.ChatMessage),
+	}
+}
+
+// Accept checks a player's message against the length and rate limits and the filter,
+// and returns the text to relay
+func (c *Chat) Accept(sender uint8, text string, now time.Time) (string, error) {
+	text = strings.TrimSpace(text)
+	switch {
+	case text == "":
+		return "", ErrChatEmpty
+	case len(text) > c.config.MaxLength:
+		return "", ErrChatTooLong
+	case !utf8.ValidString(text):
+		return "", ErrChatInvalid
+	}
+
+	c.mu.Lock()
+	bucket, exists := c.buckets[sender]
+	if !exists {
+		bucket = NewTokenBucket(c.config.Rate, now)
+		c.buckets[sender] = bucket
+	}
+	allowed := bucket.Allow(now)
+	c.mu.Unlock()
+	if !allowed {
+		return "", ErrChatRateLimited
+	}
+
+	if c.config.Filter == nil {
+		return text, nil
+	}
+	filtered, ok := c.config.Filter(sender, text)
+	if !ok || strings.TrimSpace(filtered) == "" {
+		return "", ErrChatFiltered
+	}
+	return filtered, nil
+}
+
+// Record adds a relayed message to the history of its channel. Whispers and system notices are not kept.
+func (c *Chat) Record(msg message.ChatMessage, roomID uint8) {
+	if c.config.HistorySize <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch msg.Channel {
+	case message.CHAT_GLOBAL:
+		c.global = c.trim(append(c.global, msg))
+	case message.CHAT_ROOM:
+		c.rooms[roomID] = c.trim(append(c.rooms[roomID], msg))
+	}
+}
+
+// GlobalHistory returns the last messages of the global channel, oldest first
+func (c *Chat) GlobalHistory() []message.ChatMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]message.ChatMessage(nil), c.global...)
+}
+
+// RoomHistory returns the last messages of a room's channel, oldest first
+func (c *Chat) RoomHistory(roomID uint8) []message.ChatMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]message.ChatMessage(nil), c.rooms[roomID]...)
+}
+
+// ClearRoom drops a room's history, so a new room reusing its ID starts empty
+func (c *Chat) ClearRoom(roomID uint8) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.rooms, roomID)
+}
+
+// Forget drops the rate limit state of a disconnected player
+func (c *Chat) Forget(userID uint8) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.buckets, userID)
+}
+
+// ChatState is the kept history of the global and room channels, saved across restarts
+type ChatState struct {
+	Global []message.ChatMessage           `json:"global,omitempty"`
+	Rooms  map[uint8][]message.ChatMessage `json:"rooms,omitempty"`
+}
+
+// State returns a copy of the kept history
+func (c *Chat) State() ChatState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state := ChatState{
+		Global: append([]message.ChatMessage(nil), c.global...),
+		Rooms:  make(map[uint8][]message.ChatMessage, len(c.rooms)),
+	}
+	for roomID, history := range c.rooms {
+		state.Rooms[roomID] = append([]message.ChatMessage(nil), history...)
+	}
+	return state
+}
+
+// Restore replaces the history with a saved one
+func (c *Chat) Restore(state ChatState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.global = c.trim(state.Global)
+	c.rooms = make(map[uint8][]message.ChatMessage, len(state.Rooms))
+	for roomID, history := range state.Rooms {
+		c.rooms[roomID] = c.trim(history)
+	}
+}
+
+// trim keeps the newest HistorySize messages
+func (c *Chat) trim(history []message.ChatMessage) []message.ChatMessage {
+	if over := len(history) - c.config.HistorySize; over > 0 {
+		return append(history[:0:0], history[over:]...)
+	}
+	return history
+}
@@ -1,133 +1,816 @@
 package server
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
+	"server/internal/command"
 	"server/internal/game"
 	"server/internal/message"
+	"strings"
 	"sync"
 	"time"
 )
 
-// ClientManager handles all connected clients and their state
+var (
+	// ErrMatchAbandoned is returned for a match whose players all disconnected before it started
+	ErrMatchAbandoned = errors.New("every matched player disconnected")
+	// ErrNoUserIDs is returned when all 255 user IDs belong to connected players
+	ErrNoUserIDs = errors.New("no user IDs available")
+)
+
+// ClientManager handles all connected clients and their state.
+//
+// Players are moved by the shard owning their room while holding the read lock and that shard's
+// moving lock, so shards never wait for each other. Reading a position from another goroutine
+// takes the owning shard's moving lock through PlayerPosition, and anything that reads every
+// player, or moves a player to another room and so to another shard, takes the write lock.
+// Round-trip times are guarded by each player's LatencyProbe and need neither.
+// Lookups that only need to find a player read the registry's snapshot and take no lock at all.
 type ClientManager struct {
 	players     map[uint8]*game.Player
 	clientAddrs map[string]uint8 // IP:Port -> UserID mapping
 	portManager *PortManager
+	rooms       *game.RoomManager
+	entities    *game.EntityManager
+	mover       *game.Mover
+	lag         *game.LagCompensator
+	priorities  game.PriorityConfig
+	budgeted    bool
+	maxPending  int // unacknowledged reliable messages allowed per player
+	profiles    game.ProfileConfig
+	shards      int
+	moving      []sync.RWMutex  // per shard, held while the shard moves its players
+	registry    *playerRegistry // lock-free view of players and clientAddrs
 	serializer  *message.Serializer
-	nextUserID  uint8
 	mu          sync.RWMutex
 }
 
 // NewClientManager creates a new client manager
-func NewClientManager(minPort, maxPort int) *ClientManager {
+func NewClientManager(cfg Config) *ClientManager {
 	return &ClientManager{
 		players:     make(map[uint8]*game.Player),
 		clientAddrs: make(map[string]uint8),
-		portManager: NewPortManager(minPort, maxPort),
+		portManager: NewPortManager(cfg.MinPort, cfg.MaxPort),
+		rooms:       game.NewRoomManager(cfg.InterestRadius, cfg.InterestCellSize),
+		entities:    game.NewEntityManager(cfg.InterestRadius, cfg.Movement.Bounds, cfg.EntityProperties),
+		mover:       game.NewMover(cfg.Movement),
+		lag:         game.NewLagCompensator(cfg.LagCompensation),
+		priorities:  cfg.SnapshotPriority,
+		budgeted:    cfg.SnapshotBudget > 0,
+		maxPending:  cfg.ReliableMaxPending,
+		profiles:    cfg.Profiles,
+		shards:      cfg.shardCount(),
+		moving:      make([]sync.RWMutex, cfg.shardCount()),
+		registry:    newPlayerRegistry(),
 		serializer:  message.NewSerializer(),
-		nextUserID:  1,
 	}
 }
 
-// RegisterClient registers a new client and assigns them a user ID and port
-func (cm *ClientManager) RegisterClient(addr *net.UDPAddr) (*game.Player, error) {
+// RegisterClient registers a new client under the given profile, assigns them a user ID and port
+// and places them in the lobby. An empty name is replaced by the saved name if it is still free,
+// else by the player's default name; chosen names must be valid and not used by another player,
+// ignoring case. A saved position puts the player back where they left. A client that is already
+// registered gets its existing player back, reported as not created.
+func (cm *ClientManager) RegisterClient(addr *net.UDPAddr, profile game.Profile, identity string, saved *PlayerRecord) (*game.Player, bool, error) {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
 	key := addr.String()
 	if userID, exists := cm.clientAddrs[key]; exists {
 		// Client already registered
-		return cm.players[userID], nil
+		return cm.players[userID], false, nil
+	}
+
+	if profile.Name != "" {
+		if err := cm.profiles.ValidateName(profile.Name); err != nil {
+			return nil, false, err
+		}
+		if cm.nameTakenLocked(profile.Name) {
+			return nil, false, game.ErrNameTaken
+		}
+	} else if saved != nil && cm.profiles.ValidateName(saved.Name) == nil && !cm.nameTakenLocked(saved.Name) {
+		profile.Name = saved.Name
+	}
+
+	userID, err := cm.freeUserIDLocked()
+	if err != nil {
+		return nil, false, err
 	}
 
 	port, err := cm.portManager.AllocatePort()
 	if err != nil {
-		return nil, fmt.Errorf("failed to allocate port: %w", err)
+		return nil, false, fmt.Errorf("failed to allocate port: %w", err)
 	}
 
-	userID := cm.nextUserID
-	cm.nextUserID++
+	if profile.Name == "" {
+		profile.Name = game.DefaultName(userID)
+	}
 
 	player := game.NewPlayer(userID, addr, port)
+	player.Identity = identity
+	player.Profile = profile
+	if saved != nil && saved.Position != nil {
+		player.Position.X, player.Position.Y, player.Position.Z = saved.Position.X, saved.Position.Y, saved.Position.Z
+		player.Position.RotY = saved.RotY
+		player.HasPosition = true
+	}
+	player.History = game.NewPositionHistory(cm.lag.HistorySize())
+	player.Reliable = game.NewReliableChannel(cm.maxPending)
+	if cm.budgeted {
+		player.Priorities = game.NewPriorityAccumulator(cm.priorities)
+	}
+
+	// The player joins the lobby before being published, so a failed join only has the port to give back
+	if _, err := cm.rooms.Join(game.LobbyRoomID, player); err != nil {
+		cm.portManager.ReleasePort(port)
+		return nil, false, fmt.Errorf("failed to join lobby: %w", err)
+	}
+
 	cm.players[userID] = player
 	cm.clientAddrs[key] = userID
+	cm.registry.publish(cm.players)
+	return player, true, nil
+}
+
+// freeUserIDLocked returns the lowest user ID no connected player has. IDs are 1-255, as 0 stands
+// for no player and the player IDs share the entity ID space below game.FirstEntityID.
+func (cm *ClientManager) freeUserIDLocked() (uint8, error) {
+	for id := 1; id < int(game.FirstEntityID); id++ {
+		if _, used := cm.players[uint8(id)]; !used {
+			return uint8(id), nil
+		}
+	}
+	return 0, ErrNoUserIDs
+}
 
-	return player, nil
+// nameTakenLocked reports whether a connected player uses the name, ignoring case
+func (cm *ClientManager) nameTakenLocked(name string) bool {
+	for _, other := range cm.players {
+		if strings.EqualFold(other.Profile.Name, name) {
+			return true
+		}
+	}
+	return false
 }
 
-// GetPlayerByAddress returns a player by their network address
-func (cm *ClientManager) GetPlayerByAddress(addr *net.UDPAddr) (*game.Player, bool) {
-	cm.mu.RLock()
-	defer cm.mu.RUnlock()
+// owns reports whether a player's room belongs to the given shard. It reads the room memberships
+// the room manager publishes, so it needs no lock.
+func (cm *ClientManager) owns(shard int, userID uint8) bool {
+	room, exists := cm.rooms.RoomOf(userID)
+	return exists && shardOf(room.ID, cm.shards) == shard
+}
 
-	key := addr.String()
-	if userID, exists := cm.clientAddrs[key]; exists {
-		player, ok := cm.players[userID]
-		return player, ok
+// ShardOf returns the shard owning the room of the player at the given address
+func (cm *ClientManager) ShardOf(addr *net.UDPAddr) (int, bool) {
+	player, exists := cm.registry.load().byAddr[addr.String()]
+	if !exists {
+		return noShard, false
+	}
+	room, exists := cm.rooms.RoomOf(player.ID)
+	if !exists {
+		return noShard, false
+	}
+	return shardOf(room.ID, cm.shards), true
+}
+
+// ShardPlayers returns the players in rooms owned by the given shard. It works from the published
+// player and room snapshots without taking cm.mu, so a player moving rooms meanwhile may be missed
+// or still listed under their old shard.
+func (cm *ClientManager) ShardPlayers(shard int) []*game.Player {
+	var players []*game.Player
+	for _, player := range cm.registry.load().list {
+		if cm.owns(shard, player.ID) {
+			players = append(players, player)
+		}
+	}
+	return players
+}
+
+// GetPlayerByIdentity returns the connected player with the given identity
+func (cm *ClientManager) GetPlayerByIdentity(identity string) (*game.Player, bool) {
+	if identity == "" {
+		return nil, false
+	}
+
+	for _, player := range cm.registry.load().list {
+		if player.Identity == identity {
+			return player, true
+		}
 	}
 	return nil, false
 }
 
+// Records captures the progress of every connected player with an identity
+func (cm *ClientManager) Records(now time.Time) []PlayerRecord {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	var records []PlayerRecord
+	for _, player := range cm.players {
+		if player.Identity != "" {
+			records = append(records, recordOf(player, now))
+		}
+	}
+	return records
+}
+
+// Record captures the progress of a player, connected or just removed
+func (cm *ClientManager) Record(player *game.Player, now time.Time) PlayerRecord {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	return recordOf(player, now)
+}
+
+// GetPlayerByAddress returns a player by their network address
+func (cm *ClientManager) GetPlayerByAddress(addr *net.UDPAddr) (*game.Player, bool) {
+	player, exists := cm.registry.load().byAddr[addr.String()]
+	return player, exists
+}
+
 // GetPlayer returns a player by their user ID
 func (cm *ClientManager) GetPlayer(userID uint8) (*game.Player, bool) {
+	player, exists := cm.registry.load().byID[userID]
+	return player, exists
+}
+
+// Players returns all active players ordered by ID. The slice is shared by every caller
+// and must not be modified. Shards move the players meanwhile, so positions are read
+// through PlayerPosition and records through Record.
+func (cm *ClientManager) Players() []*game.Player {
+	return cm.registry.load().list
+}
+
+// PlayerPosition returns a player's current position. It may be called from any goroutine,
+// unlike reading Position, which only the shard moving the player may do.
+func (cm *ClientManager) PlayerPosition(userID uint8) (message.PositionDataRTT, bool) {
 	cm.mu.RLock()
 	defer cm.mu.RUnlock()
 
 	player, exists := cm.players[userID]
-	return player, exists
+	if !exists {
+		return message.PositionDataRTT{}, false
+	}
+	if room, inRoom := cm.rooms.RoomOf(userID); inRoom {
+		moving := &cm.moving[shardOf(room.ID, cm.shards)]
+		moving.RLock()
+		defer moving.RUnlock()
+	}
+	return player.Position, true
+}
+
+// GetVisiblePlayers returns the room members inside the given player's area of interest.
+// It takes no lock and the slice is shared, see Room.Visible.
+func (cm *ClientManager) GetVisiblePlayers(userID uint8) []*game.Player {
+	room, exists := cm.rooms.RoomOf(userID)
+	if !exists {
+		return nil
+	}
+	return room.Visible(userID)
 }
 
-// GetAllPlayers returns all active players (except the excluded one)
-func (cm *ClientManager) GetAllPlayers(excludeUserID uint8) []*game.Player {
+// GetVisibleStates returns the current state of every player and entity in the given player's area of interest
+func (cm *ClientManager) GetVisibleStates(userID uint8) map[game.EntityID]game.EntityState {
 	cm.mu.RLock()
 	defer cm.mu.RUnlock()
 
-	var players []*game.Player
-	for _, player := range cm.players {
-		if player.ID != excludeUserID {
-			players = append(players, player)
+	room, exists := cm.rooms.RoomOf(userID)
+	if !exists {
+		return nil
+	}
+	visible := room.Visible(userID)
+
+	self, exists := cm.players[userID]
+	if !exists {
+		return nil
+	}
+
+	states := cm.entities.Visible(room.ID, self.Position.X, self.Position.Z)
+	for _, player := range visible {
+		states[game.PlayerEntityID(player.ID)] = game.EntityState{
+			Kind: game.EntityPlayer,
+			X:    player.Position.X,
+			Y:    player.Position.Y,
+			Z:    player.Position.Z,
+			RotY: player.Position.RotY,
 		}
 	}
-	return players
+	return states
+}
+
+// GetRoomPlayers returns the members of the given player's room, excluding that player
+func (cm *ClientManager) GetRoomPlayers(userID uint8) []*game.Player {
+	room, exists := cm.rooms.RoomOf(userID)
+	if !exists {
+		return nil
+	}
+	return room.Players(userID)
+}
+
+// GetRoomID returns the ID of the room the given player is in
+func (cm *ClientManager) GetRoomID(userID uint8) (uint8, bool) {
+	room, exists := cm.rooms.RoomOf(userID)
+	if !exists {
+		return 0, false
+	}
+	return room.ID, true
+}
+
+// JoinSnapshot describes everything in the given player's area of interest: the other players
+// and the entities, which are from now on known to the player, with their properties
+func (cm *ClientManager) JoinSnapshot(userID uint8) (message.JoinSnapshot, []message.EntityProperties, error) {
+	room, exists := cm.rooms.RoomOf(userID)
+	if !exists {
+		return message.JoinSnapshot{}, nil, fmt.Errorf("player %d is not in a room", userID)
+	}
+	visible := room.Visible(userID)
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	player, exists := cm.players[userID]
+	if !exists {
+		return message.JoinSnapshot{}, nil, fmt.Errorf("unknown player %d", userID)
+	}
+
+	snapshot := message.JoinSnapshot{CommandID: command.JOIN_SNAPSHOT, RoomID: room.ID}
+	for _, other := range visible {
+		snapshot.Players = append(snapshot.Players, message.PlayerState{
+			UserID: other.ID,
+			X:      other.Position.X,
+			Y:      other.Position.Y,
+			Z:      other.Position.Z,
+			RotY:   other.Position.RotY,
+		})
+	}
+
+	var properties []message.EntityProperties
+	for _, change := range cm.entities.UpdateView(player, room.ID) {
+		if !change.Spawned {
+			continue
+		}
+		snapshot.Entities = append(snapshot.Entities, entitySpawn(change.Entity))
+		if change.Entity.Properties != nil {
+			properties = append(properties, message.EntityProperties{
+				EntityID: uint16(change.Entity.ID),
+				Values:   change.Entity.Properties.Values(),
+			})
+		}
+	}
+	return snapshot, properties, nil
+}
+
+// CreateRoom opens a new room and moves the given player into it
+func (cm *ClientManager) CreateRoom(userID uint8, name string, capacity int) (*game.Room, []game.InterestChange, error) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	player, exists := cm.players[userID]
+	if !exists {
+		return nil, nil, fmt.Errorf("unknown player %d", userID)
+	}
+
+	room, err := cm.rooms.Create(name, capacity)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	changes, err := cm.rooms.Join(room.ID, player)
+	if err != nil {
+		return nil, nil, err
+	}
+	return room, changes, nil
+}
+
+// CreateMatchRoom opens a room sized for a match and moves all of its players into it.
+// Players that disconnected in the meantime are skipped, and the room is closed again if none are left.
+func (cm *ClientManager) CreateMatchRoom(name string, userIDs []uint8) (*game.Room, []game.InterestChange, error) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	room, err := cm.rooms.Create(name, len(userIDs))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var changes []game.InterestChange
+	for _, userID := range userIDs {
+		player, exists := cm.players[userID]
+		if !exists {
+			continue
+		}
+
+		joined, err := cm.rooms.Join(room.ID, player)
+		if err != nil {
+			cm.rooms.CloseEmpty(room.ID)
+			return nil, nil, err
+		}
+		changes = append(changes, joined...)
+	}
+
+	// Every matched player disconnected while queued
+	if cm.rooms.CloseEmpty(room.ID) {
+		return nil, nil, ErrMatchAbandoned
+	}
+	return room, changes, nil
+}
+
+// Ping starts a round-trip measurement of a player and returns the sequence to send it
+func (cm *ClientManager) Ping(userID uint8, now time.Time) (uint32, bool) {
+	player, exists := cm.GetPlayer(userID)
+	if !exists {
+		return 0, false
+	}
+	return player.Latency.Ping(now), true
+}
+
+// Pong completes a player's round-trip measurement and returns its smoothed RTT
+func (cm *ClientManager) Pong(userID uint8, sequence uint32, now time.Time) (time.Duration, bool) {
+	player, exists := cm.GetPlayer(userID)
+	if !exists {
+		return 0, false
+	}
+	return player.Latency.Pong(sequence, now)
+}
+
+// PlayerRTT returns the round-trip time the server measured for a player
+func (cm *ClientManager) PlayerRTT(userID uint8) time.Duration {
+	if player, exists := cm.GetPlayer(userID); exists {
+		return player.Latency.RTT()
+	}
+	return 0
+}
+
+// JoinRoom moves the given player into an existing room
+func (cm *ClientManager) JoinRoom(userID, roomID uint8) ([]game.InterestChange, error) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	player, exists := cm.players[userID]
+	if !exists {
+		return nil, fmt.Errorf("unknown player %d", userID)
+	}
+	return cm.rooms.Join(roomID, player)
+}
+
+// ListRooms returns a summary of all open rooms
+func (cm *ClientManager) ListRooms() []game.RoomInfo {
+	return cm.rooms.List()
+}
+
+// UpdatePlayerPosition updates a player's position and returns the resulting interest changes.
+// It must run on the given shard; players whose room moved to another shard are left alone.
+func (cm *ClientManager) UpdatePlayerPosition(shard int, userID uint8, pos message.PositionDataRTT) []game.InterestChange {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	player, exists := cm.players[userID]
+	if !exists || !cm.owns(shard, userID) {
+		return nil
+	}
+
+	cm.moving[shard].Lock()
+	player.UpdatePosition(pos)
+	cm.moving[shard].Unlock()
+
+	room, exists := cm.rooms.RoomOf(userID)
+	if !exists {
+		return nil
+	}
+	return room.Move(userID, pos.X, pos.Z)
 }
 
-// UpdatePlayerPosition updates a player's position
-func (cm *ClientManager) UpdatePlayerPosition(userID uint8, pos message.PositionDataRTT) {
+// SetLevel makes authoritative movement collide with the level geometry
+func (cm *ClientManager) SetLevel(level *game.Level) {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
-	if player, exists := cm.players[userID]; exists {
+	cm.mover.SetLevel(level)
+}
+
+// ApplyInput runs a player's new input frames through the authoritative movement step.
+// It returns the resulting position, the last processed sequence, interest changes, and
+// whether the player exists.
+func (cm *ClientManager) ApplyInput(shard int, userID uint8, frames []message.InputFrame, now time.Time) (message.PositionDataRTT, uint32, []game.InterestChange, bool) {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	player, exists := cm.players[userID]
+	if !exists || !cm.owns(shard, userID) {
+		return message.PositionDataRTT{}, 0, nil, false
+	}
+
+	cm.moving[shard].Lock()
+	pos, applied := cm.mover.Apply(player, frames, now)
+	if applied {
 		player.UpdatePosition(pos)
 	}
+	cm.moving[shard].Unlock()
+	if !applied {
+		return player.Position, player.Input.LastSequence, nil, true
+	}
+
+	var changes []game.InterestChange
+	if room, exists := cm.rooms.RoomOf(userID); exists {
+		changes = room.Move(userID, pos.X, pos.Z)
+	}
+	return pos, player.Input.LastSequence, changes, true
 }
 
-// CleanupInactivePlayers removes players that haven't been seen recently
-func (cm *ClientManager) CleanupInactivePlayers(timeout time.Duration) {
+// RecordHistory stores every positioned player's current position for lag compensation. Each
+// shard's players are read under its moving lock, so only that shard waits, and only for its turn.
+func (cm *ClientManager) RecordHistory(now time.Time) {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	for shard := range cm.moving {
+		cm.moving[shard].RLock()
+		for _, player := range cm.players {
+			if !player.HasPosition || player.History == nil || !cm.owns(shard, player.ID) {
+				continue
+			}
+			player.History.Record(game.PositionSample{
+				Time: now,
+				X:    player.Position.X,
+				Y:    player.Position.Y,
+				Z:    player.Position.Z,
+				RotY: player.Position.RotY,
+			})
+		}
+		cm.moving[shard].RUnlock()
+	}
+}
+
+// Rewound returns where the requester saw the other players in its room, for hit and interaction
+// checks. The write lock keeps shards from moving players while their history is read.
+func (cm *ClientManager) Rewound(userID uint8, now time.Time) ([]game.RewoundPlayer, bool) {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
+	requester, exists := cm.players[userID]
+	if !exists {
+		return nil, false
+	}
+	return cm.lag.Rewind(cm.GetRoomPlayers(userID), requester, now), true
+}
+
+// SpawnEntity adds an entity to its room; players see it once their views are next updated
+func (cm *ClientManager) SpawnEntity(entity game.Entity) (game.Entity, error) {
+	if _, exists := cm.rooms.Get(entity.RoomID); !exists {
+		return game.Entity{}, game.ErrRoomNotFound
+	}
+	return cm.entities.Spawn(entity)
+}
+
+// DespawnEntity removes an entity from the world
+func (cm *ClientManager) DespawnEntity(id game.EntityID) (game.Entity, bool) {
+	return cm.entities.Despawn(id)
+}
+
+// GetEntity returns an entity by its ID
+func (cm *ClientManager) GetEntity(id game.EntityID) (game.Entity, bool) {
+	return cm.entities.Get(id)
+}
+
+// GetEntities returns every entity in the world
+func (cm *ClientManager) GetEntities() []game.Entity {
+	return cm.entities.All()
+}
+
+// TickEntities advances the entity simulation by dt, despawns entities whose room closed and
+// returns the entities that entered or left each player's view
+func (cm *ClientManager) TickEntities(shard int, now time.Time, dt time.Duration) []game.EntityChange {
+	owns := func(roomID uint8) bool { return shardOf(roomID, cm.shards) == shard }
+	cm.entities.Tick(now, dt, owns)
+	cm.entities.DespawnRooms(func(roomID uint8) bool {
+		if !owns(roomID) {
+			return true
+		}
+		_, exists := cm.rooms.Get(roomID)
+		return exists
+	})
+
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	var changes []game.EntityChange
+	for _, player := range cm.players {
+		room, exists := cm.rooms.RoomOf(player.ID)
+		if !exists || !owns(room.ID) {
+			continue
+		}
+		changes = append(changes, cm.entities.UpdateView(player, room.ID)...)
+	}
+	return changes
+}
+
+// EntityTransforms returns per user the transforms of the moving entities it knows in the shard's rooms
+func (cm *ClientManager) EntityTransforms(shard int) map[uint8][]message.EntityTransform {
+	owns := func(roomID uint8) bool { return shardOf(roomID, cm.shards) == shard }
+	moving := cm.entities.Moving(owns)
+	if len(moving) == 0 {
+		return nil
+	}
+
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	transforms := make(map[uint8][]message.EntityTransform)
 	for userID, player := range cm.players {
-		if !player.IsActive(timeout) {
-			// Release the player's port
-			cm.portManager.ReleasePort(player.ListenPort)
+		room, exists := cm.rooms.RoomOf(userID)
+		if !exists || !owns(room.ID) {
+			continue
+		}
+		for id := range player.KnownEntities {
+			if transform, ok := moving[id]; ok {
+				transforms[userID] = append(transforms[userID], transform)
+			}
+		}
+	}
+	return transforms
+}
 
-			// Remove from address mapping
-			key := player.Address.String()
-			delete(cm.clientAddrs, key)
+// CollectPropertyUpdates gathers the properties of the shard's entities that changed since the
+// last call and returns them per user of the shard, for the entities each user knows about
+func (cm *ClientManager) CollectPropertyUpdates(shard int, now time.Time, budget int) map[uint8][]message.EntityProperties {
+	changed := cm.entities.CollectProperties(now, budget, func(roomID uint8) bool {
+		return shardOf(roomID, cm.shards) == shard
+	})
+	if len(changed) == 0 {
+		return nil
+	}
 
-			// Remove from players
-			delete(cm.players, userID)
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
 
+	updates := make(map[uint8][]message.EntityProperties)
+	for userID, player := range cm.players {
+		if !cm.owns(shard, userID) {
+			continue
+		}
+		for id := range player.KnownEntities {
+			if values, ok := changed[id]; ok {
+				updates[userID] = append(updates[userID], message.EntityProperties{EntityID: uint16(id), Values: values})
+			}
+		}
+	}
+	return updates
+}
+
+// CleanupInactivePlayers removes players that haven't been seen recently.
+// It returns leave events for the players that could still see them, and the removed players.
+func (cm *ClientManager) CleanupInactivePlayers(timeout time.Duration) ([]game.InterestChange, []*game.Player) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	var changes []game.InterestChange
+	var removed []*game.Player
+	for userID, player := range cm.players {
+		if !player.IsActive(timeout) {
+			changes = append(changes, cm.removePlayerLocked(player)...)
+			removed = append(removed, player)
 			fmt.Printf("Cleaned up inactive player %d\n", userID)
 		}
 	}
+	return changes, removed
+}
+
+// RemovePlayer disconnects a player right away and returns leave events for their observers
+func (cm *ClientManager) RemovePlayer(userID uint8) []game.InterestChange {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	player, exists := cm.players[userID]
+	if !exists {
+		return nil
+	}
+	return cm.removePlayerLocked(player)
+}
+
+func (cm *ClientManager) removePlayerLocked(player *game.Player) []game.InterestChange {
+	// Release the player's port
+	cm.portManager.ReleasePort(player.ListenPort)
+
+	// Remove from address mapping
+	key := player.Address.String()
+	delete(cm.clientAddrs, key)
+
+	// Remove from players and their room
+	delete(cm.players, player.ID)
+	cm.registry.publish(cm.players)
+	return cm.rooms.Leave(player.ID)
 }
 
 // GetStats returns current statistics about connected clients
 func (cm *ClientManager) GetStats() (playerCount, availablePorts int) {
-	cm.mu.RLock()
-	defer cm.mu.RUnlock()
+	return len(cm.registry.load().list), cm.portManager.AvailablePorts()
+}
+
+// State captures the players, rooms and entities so a restarted server can restore them
+func (cm *ClientManager) State(now time.Time) (ServerState, error) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	state := ServerState{SavedAt: now}
+	for _, player := range cm.players {
+		saved := SavedPlayer{
+			ID:          player.ID,
+			Identity:    player.Identity,
+			Profile:     player.Profile,
+			Address:     player.Address.String(),
+			ListenPort:  player.ListenPort,
+			Position:    player.Position,
+			HasPosition: player.HasPosition,
+			RTT:         player.Latency.RTT(),
+			LastInput:   player.Input.LastSequence,
+			Reliable:    player.Reliable.State(),
+		}
+		for id := range player.KnownEntities {
+			saved.KnownEntities = append(saved.KnownEntities, id)
+		}
+		state.Players = append(state.Players, saved)
+	}
+
+	state.Rooms, state.NextRoomID = cm.rooms.State()
+
+	entities, nextID := cm.entities.State()
+	state.NextEntityID = nextID
+	for _, entity := range entities {
+		saved, err := savedEntity(entity)
+		if err != nil {
+			return ServerState{}, fmt.Errorf("failed to save entity %d: %w", entity.ID, err)
+		}
+		state.Entities = append(state.Entities, saved)
+	}
+	return state, nil
+}
+
+// Restore puts back the players, rooms and entities of a saved state. Players whose ID, address or
+// port cannot be restored are skipped and reported in the returned error; everything else is restored.
+func (cm *ClientManager) Restore(state *ServerState) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
 
-	return len(cm.players), cm.portManager.AvailablePorts()
+	var errs []error
+	for _, saved := range state.Players {
+		if _, used := cm.players[saved.ID]; used || saved.ID == 0 {
+			errs = append(errs, fmt.Errorf("player %d: invalid or duplicate user ID", saved.ID))
+			continue
+		}
+		addr, err := net.ResolveUDPAddr("udp", saved.Address)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("player %d: %w", saved.ID, err))
+			continue
+		}
+		if err := cm.portManager.ClaimPort(saved.ListenPort); err != nil {
+			errs = append(errs, fmt.Errorf("player %d: %w", saved.ID, err))
+			continue
+		}
+
+		player := game.NewPlayer(saved.ID, addr, saved.ListenPort)
+		player.Identity = saved.Identity
+		player.Profile = saved.Profile
+		player.Position = saved.Position
+		player.HasPosition = saved.HasPosition
+		player.Latency.SetRTT(saved.RTT)
+		player.Input.LastSequence = saved.LastInput
+		player.History = game.NewPositionHistory(cm.lag.HistorySize())
+		player.Reliable = game.NewReliableChannel(cm.maxPending)
+		player.Reliable.Restore(saved.Reliable)
+		if cm.budgeted {
+			player.Priorities = game.NewPriorityAccumulator(cm.priorities)
+		}
+		for _, id := range saved.KnownEntities {
+			player.KnownEntities[id] = struct{}{}
+		}
+		cm.players[player.ID] = player
+		cm.clientAddrs[addr.String()] = player.ID
+	}
+	cm.registry.publish(cm.players)
+
+	if err := cm.rooms.Restore(state.Rooms, state.NextRoomID, cm.players); err != nil {
+		errs = append(errs, err)
+	}
+	for _, player := range cm.players {
+		if _, inRoom := cm.rooms.RoomOf(player.ID); !inRoom {
+			if _, err := cm.rooms.Join(game.LobbyRoomID, player); err != nil {
+				errs = append(errs, fmt.Errorf("player %d: %w", player.ID, err))
+			}
+		}
+	}
+
+	entities := make([]game.Entity, len(state.Entities))
+	properties := make(map[game.EntityID]map[string]json.RawMessage, len(state.Entities))
+	for i, saved := range state.Entities {
+		entities[i] = saved.Entity
+		properties[saved.ID] = saved.Properties
+	}
+	for _, entity := range cm.entities.Restore(entities, state.NextEntityID) {
+		if err := setProperties(entity.Properties, properties[entity.ID]); err != nil {
+			errs = append(errs, fmt.Errorf("entity %d: %w", entity.ID, err))
+		}
+	}
+	return errors.Join(errs...)
 }
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"server/internal/game"
+	"testing"
+)
+
+func TestRegisterClientUserIDs(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxPort = cfg.MinPort + 300
+	cm := NewClientManager(cfg)
+
+	register := func(port int) (*game.Player, error) {
+		addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}
+		player, _, err := cm.RegisterClient(addr, game.Profile{}, "", nil)
+		return player, err
+	}
+
+	for want := 1; want <= 255; want++ {
+		player, err := register(40000 + want)
+		if err != nil {
+			t.Fatalf("registration %d: %v", want, err)
+		}
+		if int(player.ID) != want {
+			t.Fatalf("registration %d got ID %d", want, player.ID)
+		}
+	}
+
+	// Every ID is taken, so the next registration fails instead of wrapping onto a connected player
+	_, available := cm.GetStats()
+	if _, err := register(41000); !errors.Is(err, ErrNoUserIDs) {
+		t.Fatalf("256th registration: %v, want ErrNoUserIDs", err)
+	}
+	if _, after := cm.GetStats(); after != available {
+		t.Errorf("failed registration took a port: %d available, want %d", after, available)
+	}
+	if player, _ := cm.GetPlayer(1); player.Address.Port != 40001 {
+		t.Errorf("player 1 was replaced by %s", player.Address)
+	}
+
+	// Freed IDs are handed out again, lowest first
+	cm.RemovePlayer(200)
+	cm.RemovePlayer(7)
+	for _, want := range []uint8{7, 200} {
+		player, err := register(42000 + int(want))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if player.ID != want {
+			t.Errorf("got ID %d, want %d", player.ID, want)
+		}
+	}
+}
+
+func TestCreateMatchRoom(t *testing.T) {
+	cm := NewClientManager(DefaultConfig())
+
+	var ids []uint8
+	for i := 0; i < 4; i++ {
+		addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 40000 + i}
+		player, _, err := cm.RegisterClient(addr, game.Profile{}, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, player.ID)
+	}
+	cm.RemovePlayer(ids[3])
+
+	// A player that left while queued is skipped, the room is still sized for the whole match
+	room, _, err := cm.CreateMatchRoom("Match", ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if room.Capacity != len(ids) {
+		t.Errorf("room capacity %d, want %d", room.Capacity, len(ids))
+	}
+	for _, id := range ids[:3] {
+		if got, _ := cm.rooms.RoomOf(id); got != room {
+			t.Errorf("player %d is not in the match room", id)
+		}
+	}
+	if _, inRoom := cm.rooms.RoomOf(ids[3]); inRoom {
+		t.Error("departed player was put in the match room")
+	}
+
+	// A match whose players all left opens no room
+	rooms := len(cm.ListRooms())
+	if _, _, err := cm.CreateMatchRoom("Match", []uint8{ids[3], 200}); !errors.Is(err, ErrMatchAbandoned) {
+		t.Errorf("abandoned match: %v, want ErrMatchAbandoned", err)
+	}
+	if len(cm.ListRooms()) != rooms {
+		t.Error("abandoned match left its room open")
+	}
+}
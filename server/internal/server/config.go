@@ -0,0 +1,308 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"server/internal/command"
+	"server/internal/game"
+	"server/internal/message"
+	"time"
+)
+
+// Config holds the tunable settings of the game server
+type Config struct {
+	Address string
+	MinPort int
+	MaxPort int
+
+	// Outgoing messages are coalesced into datagrams of at most MTU bytes and flushed
+	// every FlushInterval. Zero sends every message as its own datagram right away.
+	MTU           int
+	FlushInterval time.Duration
+
+	// The world is split into Shards by room. Each shard handles its rooms' packets and simulation on
+	// its own goroutine from a queue of up to ShardQueueSize jobs. Zero uses one shard per CPU.
+	Shards         int
+	ShardQueueSize int
+
+	// Messages larger than the MTU are fragmented. Incomplete messages are dropped after
+	// FragmentTimeout, and no sender may have more than FragmentMemoryLimit bytes pending, nor all
+	// senders together more than FragmentTotalLimit. Only registered players may send fragments.
+	ReceiveBufferSize   int
+	FragmentTimeout     time.Duration
+	FragmentMemoryLimit int
+	FragmentTotalLimit  int
+
+	// SecureTransport requires every client to complete a KEY_EXCHANGE first, after echoing a
+	// connect cookie whether or not ConnectCookies is set. All other traffic is then wrapped in authenticated, encrypted SECURE envelopes.
+	// The server signs its key share with the Ed25519 key at SecureIdentityPath, created on first
+	// start; clients pin the public key logged at startup. At most SecureMaxSessions addresses
+	// may hold a session.
+	SecureTransport    bool
+	SecureIdentityPath string
+	SecureMaxSessions  int
+
+	// ConnectCookies makes clients echo a stateless cookie before they are registered,
+	// so spoofed PORT_REQUESTs allocate nothing and are answered with at most their own size
+	ConnectCookies bool
+	CookieLifetime time.Duration
+
+	// RateLimits bound how fast clients may send, see RateLimitConfig
+	RateLimits RateLimitConfig
+
+	// AccessListPath is where the allow, deny and ban lists are persisted; empty keeps them in memory.
+	// AdminAddress serves the HTTP interface for editing them; empty disables it.
+	// The admin interface is unauthenticated, so bind it to a loopback or private address.
+	AccessListPath string
+	AdminAddress   string
+
+	// PlayerStorePath is where the progress of returning players is kept, keyed by the identity
+	// derived from their PortRequest secret; empty keeps it in memory only.
+	// PlayerStore replaces the file with another backend. The file drops players not seen for
+	// PlayerRecordMaxAge and keeps at most PlayerRecordLimit of the most recently seen.
+	PlayerStorePath    string
+	PlayerStore        PlayerStore
+	PlayerRecordMaxAge time.Duration
+	PlayerRecordLimit  int
+
+	// StatePath is where the full runtime state is written on shutdown and restored from on startup,
+	// so clients resume after a quick restart; empty disables it. State older than StateMaxAge is
+	// discarded, as its clients have given up by then. See ServerState for what is not saved.
+	StatePath   string
+	StateMaxAge time.Duration
+
+	// Area of interest: players only receive updates for others within InterestRadius
+	InterestRadius   float32
+	InterestCellSize float32
+
+	// Delta snapshots are sent every SnapshotInterval instead of broadcasting each
+	// position update as it arrives. Zero disables snapshots.
+	SnapshotInterval time.Duration
+
+	// SnapshotBudget caps the snapshot bytes per second sent to each client; zero sends every
+	// change. Changes that do not fit are ranked by SnapshotPriority and sent in later snapshots.
+	// Only snapshots are budgeted, so a budget requires a SnapshotInterval; the per-update
+	// position broadcast used without snapshots is limited by the senders' rate limits instead.
+	SnapshotBudget   int
+	SnapshotPriority game.PriorityConfig
+
+	// CompactPositions switches position broadcasts to the quantized POSITION_COMPACT
+	// encoding. Nil keeps the full 18-byte PositionData. Clients decode it with the same
+	// configuration, which for the Godot client is DefaultCompactPositions.
+	CompactPositions *message.QuantizationConfig
+
+	// Movement limits position updates are validated against. Violations are written
+	// to MovementAuditPath, or to the standard log when it is empty.
+	Movement          game.MovementConfig
+	MovementAuditPath string
+
+	// LevelPath is the world geometry authoritative movement collides with.
+	// A missing file leaves movement unconstrained apart from Movement.Bounds.
+	LevelPath string
+
+	// AuthoritativeMovement moves players only by running their INPUT frames on the server.
+	// POSITION_RTT is then only used for round-trip times.
+	AuthoritativeMovement bool
+
+	// LagCompensation keeps a history of player positions so queries can see the world
+	// as a lagged client saw it
+	LagCompensation game.LagCompensationConfig
+
+	// EntityTickInterval is how often non-player entities are simulated and players are told
+	// about entities entering or leaving their view. Zero disables entities.
+	EntityTickInterval time.Duration
+
+	// EntityTransformInterval is how often clients are sent the transforms of the moving entities
+	// they know, correcting what they extrapolated from the spawn. Snapshots replicate entities
+	// themselves, so this only applies while SnapshotInterval is zero. Zero never corrects them.
+	EntityTransformInterval time.Duration
+
+	// EntityProperties declares the replicated properties of each entity kind. Changed properties
+	// are sent every entity tick, at most PropertyBudget bytes of them per entity.
+	EntityProperties map[game.EntityKind]game.PropertySchema
+	PropertyBudget   int
+
+	// Reliable messages are resent every ReliableResendInterval until acknowledged. A client with
+	// ReliableMaxPending unacknowledged messages is disconnected when another one is sent. Property
+	// updates only use half of them, and are combined while they wait.
+	ReliableResendInterval time.Duration
+	ReliableMaxPending     int
+
+	// PingInterval is how often each player is pinged to measure its round-trip time, which
+	// matchmaking, movement validation and lag compensation use. Zero disables pings.
+	PingInterval time.Duration
+
+	// Chat limits and history, see ChatConfig
+	Chat ChatConfig
+
+	// Profiles limits the display names players choose in their PORT_REQUEST
+	Profiles game.ProfileConfig
+
+	// Matchmaking groups queued players into rooms of MatchSize every MatchmakingInterval
+	Matchmaking         game.MatchmakerConfig
+	MatchmakingInterval time.Duration
+}
+
+// Validate reports settings that contradict each other
+func (c Config) Validate() error {
+	if c.SnapshotBudget < 0 {
+		return fmt.Errorf("snapshot budget must not be negative, got %d", c.SnapshotBudget)
+	}
+	if c.SnapshotBudget > 0 && c.SnapshotInterval <= 0 {
+		return errors.New("a snapshot budget requires a snapshot interval, position broadcasts are not budgeted")
+	}
+	if c.SnapshotBudget > 0 && c.snapshotBudgetBytes() <= 0 {
+		return fmt.Errorf("snapshot budget of %d bytes/s does not cover the %d-byte snapshot header every %v",
+			c.SnapshotBudget, message.SnapshotHeaderSize, c.SnapshotInterval)
+	}
+	if (c.ConnectCookies || c.SecureTransport) && c.CookieLifetime <= 0 {
+		return fmt.Errorf("cookie lifetime must be positive, got %v", c.CookieLifetime)
+	}
+	return nil
+}
+
+// snapshotBudgetBytes returns the entry bytes each snapshot may carry per client, 0 for unlimited
+func (c Config) snapshotBudgetBytes() int {
+	if c.SnapshotBudget == 0 {
+		return 0
+	}
+	return int(float64(c.SnapshotBudget)*c.SnapshotInterval.Seconds()) - message.SnapshotHeaderSize
+}
+
+// shardCount returns the number of shards to run
+func (c Config) shardCount() int {
+	if c.Shards > 0 {
+		return c.Shards
+	}
+	return runtime.NumCPU()
+}
+
+// DefaultCompactPositions returns the quantization the client expects: the default movement
+// bounds at 1cm precision and 10 bits of rotation, for 10-byte POSITION_COMPACT messages
+func DefaultCompactPositions() *message.QuantizationConfig {
+	return &message.QuantizationConfig{
+		Min:       [3]float32{-500, -50, -500},
+		Max:       [3]float32{500, 200, 500},
+		Precision: 0.01,
+		RotBits:   10,
+	}
+}
+
+// DefaultConfig returns the default server configuration
+func DefaultConfig() Config {
+	return Config{
+		Address:       ":8080",
+		MinPort:       22222,
+		MaxPort:       22321,
+		MTU:           1200,
+		FlushInterval: 0,
+
+		Shards:         0,
+		ShardQueueSize: 1024,
+
+		ReceiveBufferSize:   64 * 1024,
+		FragmentTimeout:     2 * time.Second,
+		FragmentMemoryLimit: 256 * 1024,
+		FragmentTotalLimit:  16 * 1024 * 1024,
+
+		SecureTransport:    false,
+		SecureIdentityPath: "identity.key",
+		SecureMaxSessions:  4096,
+		ConnectCookies:     true,
+		CookieLifetime:     10 * time.Second,
+		RateLimits: RateLimitConfig{
+			Global:     BucketConfig{Rate: 20000, Burst: 5000},
+			PerAddress: BucketConfig{Rate: 250, Burst: 100},
+			PerPlayer:  BucketConfig{Rate: 250, Burst: 100},
+			PerCommand: map[command.Command]BucketConfig{
+				command.PORT_REQUEST:  {Rate: 10, Burst: 20},
+				command.POSITION_RTT:  {Rate: 120, Burst: 60},
+				command.MOVE_RTT:      {Rate: 120, Burst: 60},
+				command.INPUT:         {Rate: 120, Burst: 60},
+				command.ROOM_CREATE:   {Rate: 1, Burst: 3},
+				command.MATCH_REQUEST: {Rate: 1, Burst: 3},
+				command.PONG:          {Rate: 2, Burst: 4},
+			},
+			MaxAddresses:     10000,
+			ViolationWindow:  10 * time.Second,
+			ThrottleAfter:    200,
+			KickAfter:        1000,
+			BanAfter:         2000,
+			ThrottleDuration: 2 * time.Second,
+			BanDuration:      10 * time.Minute,
+		},
+		AccessListPath:     "access_list.json",
+		AdminAddress:       "",
+		PlayerStorePath:    "players.json",
+		PlayerStore:        nil,
+		PlayerRecordMaxAge: 90 * 24 * time.Hour,
+		PlayerRecordLimit:  100000,
+		StatePath:          "state.json",
+		StateMaxAge:        time.Minute,
+		InterestRadius:     20,
+		InterestCellSize:   10,
+		SnapshotInterval:   0,
+		SnapshotBudget:     0,
+		SnapshotPriority: game.PriorityConfig{
+			Importance: map[game.EntityKind]float32{
+				game.EntityPlayer:     1,
+				game.EntityNPC:        1,
+				game.EntityProjectile: 2,
+				game.EntityPickup:     0.25,
+			},
+			DistanceScale: 10,
+		},
+		Movement: game.MovementConfig{
+			MaxSpeed:         10,
+			MaxVerticalSpeed: 20,
+			Tolerance:        1.25,
+			MaxRTTAllowance:  250 * time.Millisecond,
+			MaxInputBudget:   250 * time.Millisecond,
+			Spawn:            game.Vec3{X: 0, Y: 1, Z: 0},
+			Capsule:          game.Capsule{Radius: 0.5, Height: 1},
+			StepHeight:       0.3,
+			Bounds: game.Bounds{
+				MinX: -500, MinY: -50, MinZ: -500,
+				MaxX: 500, MaxY: 200, MaxZ: 500,
+			},
+			Actions: map[game.Violation]game.ValidationAction{
+				game.ViolationInvalid: game.ActionIgnore,
+				game.ViolationBounds:  game.ActionCorrect,
+				game.ViolationSpeed:   game.ActionCorrect,
+			},
+		},
+		MovementAuditPath:     "",
+		LevelPath:             "levels/main.json",
+		AuthoritativeMovement: false,
+		LagCompensation: game.LagCompensationConfig{
+			HistoryInterval:    15 * time.Millisecond,
+			InterpolationDelay: 100 * time.Millisecond,
+			MaxRewind:          time.Second,
+		},
+		EntityTickInterval:      50 * time.Millisecond,
+		EntityTransformInterval: 200 * time.Millisecond,
+		EntityProperties:        game.DefaultPropertySchemas(),
+		PropertyBudget:          128,
+		Chat: ChatConfig{
+			MaxLength:   200,
+			Rate:        BucketConfig{Rate: 1, Burst: 5},
+			HistorySize: 50,
+			Filter:      nil,
+		},
+		ReliableResendInterval: 200 * time.Millisecond,
+		ReliableMaxPending:     256,
+		PingInterval:           time.Second,
+		Profiles: game.ProfileConfig{
+			MinNameLength: 3,
+			MaxNameLength: 16,
+		},
+		Matchmaking: game.MatchmakerConfig{
+			MatchSize:      4,
+			MaxRTTSpread:   50 * time.Millisecond,
+			MaxSkillSpread: 100,
+			RelaxInterval:  10 * time.Second,
+		},
+		MatchmakingInterval: time.Second,
+	}
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval time.Duration
+		budget   int
+		wantErr  bool
+	}{
+		{"default", 0, 0, false},
+		{"snapshots without budget", 50 * time.Millisecond, 0, false},
+		{"snapshots with budget", 50 * time.Millisecond, 16 * 1024, false},
+		{"budget without snapshots", 0, 16 * 1024, true},
+		{"budget smaller than the header", 50 * time.Millisecond, 100, true},
+		{"negative budget", 50 * time.Millisecond, -1, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.SnapshotInterval, cfg.SnapshotBudget = test.interval, test.budget
+			if err := cfg.Validate(); (err != nil) != test.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, test.wantErr)
+			}
+		})
+	}
+
+	cfg := DefaultConfig()
+	cfg.SnapshotInterval, cfg.SnapshotBudget = 50*time.Millisecond, 16*1024
+	if got := cfg.snapshotBudgetBytes(); got <= 0 {
+		t.Errorf("snapshotBudgetBytes() = %d, want positive", got)
+	}
+}
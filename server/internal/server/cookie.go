@@ -0,0 +1,65 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+)
+
+// CookieSize is the length of a connect cookie in bytes
+const CookieSize = 16
+
+// CookieIssuer hands out stateless connect cookies bound to a client address.
+// A cookie is an HMAC over the address and a time bucket, so verifying it needs no stored state.
+type CookieIssuer struct {
+	secret   [32]byte
+	lifetime time.Duration
+}
+
+// NewCookieIssuer creates an issuer with a random secret. Cookies stay valid
+// for at least lifetime and at most twice as long.
+func NewCookieIssuer(lifetime time.Duration) (*CookieIssuer, error) {
+	if lifetime <= 0 {
+		return nil, fmt.Errorf("cookie lifetime must be positive, got %v", lifetime)
+	}
+	ci := &CookieIssuer{lifetime: lifetime}
+	if _, err := rand.Read(ci.secret[:]); err != nil {
+		return nil, err
+	}
+	return ci, nil
+}
+
+// Issue returns the cookie for an address at the given time
+func (ci *CookieIssuer) Issue(addr *net.UDPAddr, now time.Time) [CookieSize]byte {
+	return ci.cookieFor(addr, ci.bucket(now))
+}
+
+// Verify checks a cookie against the current and the previous time bucket
+func (ci *CookieIssuer) Verify(addr *net.UDPAddr, cookie [CookieSize]byte, now time.Time) bool {
+	bucket := ci.bucket(now)
+	for _, b := range []uint64{bucket, bucket - 1} {
+		expected := ci.cookieFor(addr, b)
+		if hmac.Equal(expected[:], cookie[:]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (ci *CookieIssuer) bucket(now time.Time) uint64 {
+	return uint64(now.UnixNano() / int64(ci.lifetime))
+}
+
+func (ci *CookieIssuer) cookieFor(addr *net.UDPAddr, bucket uint64) [CookieSize]byte {
+	mac := hmac.New(sha256.New, ci.secret[:])
+	mac.Write([]byte(addr.String()))
+	binary.Write(mac, binary.LittleEndian, bucket)
+
+	var cookie [CookieSize]byte
+	copy(cookie[:], mac.Sum(nil))
+	return cookie
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"net"
+	"server/internal/command"
+	"testing"
+	"time"
+)
+
+func TestCookieVerify(t *testing.T) {
+	lifetime := 10 * time.Second
+	ci, err := NewCookieIssuer(lifetime)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 1000}
+	issued := time.Unix(1000, 0) // the start of a bucket
+	cookie := ci.Issue(addr, issued)
+
+	tests := []struct {
+		name string
+		addr *net.UDPAddr
+		at   time.Time
+		want bool
+	}{
+		{"same bucket", addr, issued.Add(lifetime - time.Nanosecond), true},
+		{"previous bucket", addr, issued.Add(lifetime + time.Second), true},
+		{"after two lifetimes", addr, issued.Add(2 * lifetime), false},
+		{"other address", &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 1000}, issued, false},
+		{"other port", &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 1001}, issued, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := ci.Verify(test.addr, cookie, test.at); got != test.want {
+				t.Errorf("Verify = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCookieLifetimeMustBePositive(t *testing.T) {
+	if _, err := NewCookieIssuer(0); err == nil {
+		t.Error("NewCookieIssuer(0) succeeded")
+	}
+
+	cfg := DefaultConfig()
+	cfg.CookieLifetime = 0
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate accepted a zero cookie lifetime with cookies on")
+	}
+	cfg.ConnectCookies = false
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate with cookies off: %v", err)
+	}
+}
+
+func TestLegacyPortRequestRefused(t *testing.T) {
+	s := NewServerWithConfig(DefaultConfig())
+	var err error
+	if s.cookies, err = NewCookieIssuer(time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	loopback := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}
+	if s.conn, err = net.ListenUDP("udp", loopback); err != nil {
+		t.Fatal(err)
+	}
+	defer s.conn.Close()
+	client, err := net.ListenUDP("udp", loopback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	clientAddr := client.LocalAddr().(*net.UDPAddr)
+
+	s.handlePortRequest(clientAddr, []byte{byte(command.PORT_REQUEST)})
+
+	if _, exists := s.clientManager.GetPlayerByAddress(clientAddr); exists {
+		t.Error("1-byte PORT_REQUEST registered a player")
+	}
+	client.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if n, err := client.Read(make([]byte, 1500)); err == nil {
+		t.Errorf("1-byte PORT_REQUEST answered with %d bytes", n)
+	}
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"log"
+	"net"
+	"server/internal/message"
+	"sync"
+)
+
+// outboxQueue holds the messages waiting for one destination
+type outboxQueue struct {
+	addr     *net.UDPAddr
+	messages [][]byte
+}
+
+// Outbox coalesces outgoing messages per destination into BATCH datagrams up to the MTU
+type Outbox struct {
+	write      func(data []byte, addr *net.UDPAddr)
+	serializer *message.Serializer
+	mtu        int
+	pending    map[string]*outboxQueue
+	mu         sync.Mutex
+}
+
+// NewOutbox creates an outbox that hands finished datagrams to write
+func NewOutbox(serializer *message.Serializer, mtu int, write func(data []byte, addr *net.UDPAddr)) *Outbox {
+	return &Outbox{
+		write:      write,
+		serializer: serializer,
+		mtu:        mtu,
+		pending:    make(map[string]*outboxQueue),
+	}
+}
+
+// Queue adds a message for the given destination; it is sent on the next Flush
+func (o *Outbox) Queue(addr *net.UDPAddr, data []byte) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	key := addr.String()
+	queue, exists := o.pending[key]
+	if !exists {
+		queue = &outboxQueue{addr: addr}
+		o.pending[key] = queue
+	}
+	queue.messages = append(queue.messages, data)
+}
+
+// Flush packs and sends everything queued so far
+func (o *Outbox) Flush() {
+	o.mu.Lock()
+	pending := o.pending
+	o.pending = make(map[string]*outboxQueue)
+	o.mu.Unlock()
+
+	for _, queue := range pending {
+		datagrams, err := o.serializer.Pack(queue.messages, o.mtu)
+		if err != nil {
+			log.Printf("Failed to pack messages for %s: %v", queue.addr, err)
+			continue
+		}
+		for _, datagram := range datagrams {
+			o.write(datagram, queue.addr)
+		}
+	}
+}
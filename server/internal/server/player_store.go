@@ -0,0 +1,191 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"server/internal/game"
+	"sort"
+	"sync"
+	"time"
+)
+
+// PlayerRecord is the progress kept for a player between sessions
+type PlayerRecord struct {
+	Identity string     `json:"identity"`
+	Name     string     `json:"name"`
+	Position *game.Vec3 `json:"position,omitempty"` // nil if the player never moved
+	RotY     float32    `json:"rotY"`
+	LastSeen time.Time  `json:"lastSeen"`
+}
+
+// PlayerStore keeps player records keyed by a stable identity
+type PlayerStore interface {
+	// Load returns the record of an identity, or false if it was never saved
+	Load(identity string) (PlayerRecord, bool, error)
+	// Save adds or replaces records
+	Save(records ...PlayerRecord) error
+}
+
+// Identity derives the stable identity of a client from the secret in its PortRequest.
+// Only the hash is stored and shown to admins, so it cannot be used to impersonate the client.
+// A zero secret is anonymous and has no identity.
+func Identity(secret [16]byte) string {
+	if secret == ([16]byte{}) {
+		return ""
+	}
+	sum := sha256.Sum256(secret[:])
+	return hex.EncodeToString(sum[:16])
+}
+
+// recordOf captures the progress of a player
+func recordOf(player *game.Player, now time.Time) PlayerRecord {
+	record := PlayerRecord{
+		Identity: player.Identity,
+		Name:     player.Profile.Name,
+		LastSeen: now,
+	}
+	if player.HasPosition {
+		record.Position = &game.Vec3{X: player.Position.X, Y: player.Position.Y, Z: player.Position.Z}
+		record.RotY = player.Position.RotY
+	}
+	return record
+}
+
+// FilePlayerStore keeps player records in memory and writes all of them to a JSON file on every save.
+// Anyone can make up a new identity, so records not seen for maxAge are dropped and only the
+// maxRecords most recently seen are kept.
+type FilePlayerStore struct {
+	records    map[string]PlayerRecord // identity -> record
+	path       string
+	maxAge     time.Duration // zero keeps records forever
+	maxRecords int           // zero keeps any number of records
+	mu         sync.RWMutex
+}
+
+// NewFilePlayerStore creates a store persisted at path; an empty path keeps records in memory only
+func NewFilePlayerStore(path string, maxAge time.Duration, maxRecords int) *FilePlayerStore {
+	return &FilePlayerStore{
+		records:    make(map[string]PlayerRecord),
+		path:       path,
+		maxAge:     maxAge,
+		maxRecords: maxRecords,
+	}
+}
+
+// Open reads the records from disk. A missing file is not an error.
+func (fs *FilePlayerStore) Open() error {
+	if fs.path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(fs.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var records []PlayerRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", fs.path, err)
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.records = make(map[string]PlayerRecord, len(records))
+	for _, record := range records {
+		fs.records[record.Identity] = record
+	}
+	fs.pruneLocked(time.Now())
+	return nil
+}
+
+// Load returns the record of an identity
+func (fs *FilePlayerStore) Load(identity string) (PlayerRecord, bool, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	record, exists := fs.records[identity]
+	return record, exists, nil
+}
+
+// Save adds or replaces records, drops expired ones and writes the store to disk if anything changed
+func (fs *FilePlayerStore) Save(records ...PlayerRecord) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	changed := false
+	for _, record := range records {
+		if record.Identity == "" {
+			continue
+		}
+		fs.records[record.Identity] = record
+		changed = true
+	}
+	if fs.pruneLocked(time.Now()) > 0 {
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return fs.saveLocked()
+}
+
+// pruneLocked drops records last seen more than maxAge ago, then the least recently seen ones
+// over maxRecords, and returns how many were dropped
+func (fs *FilePlayerStore) pruneLocked(now time.Time) int {
+	before := len(fs.records)
+	if fs.maxAge > 0 {
+		for identity, record := range fs.records {
+			if now.Sub(record.LastSeen) > fs.maxAge {
+				delete(fs.records, identity)
+			}
+		}
+	}
+
+	if fs.maxRecords > 0 && len(fs.records) > fs.maxRecords {
+		records := make([]PlayerRecord, 0, len(fs.records))
+		for _, record := range fs.records {
+			records = append(records, record)
+		}
+		sort.Slice(records, func(i, j int) bool {
+			return records[i].LastSeen.Before(records[j].LastSeen)
+		})
+		for _, record := range records[:len(records)-fs.maxRecords] {
+			delete(fs.records, record.Identity)
+		}
+	}
+	return before - len(fs.records)
+}
+
+// saveLocked writes the records atomically via a temporary file
+func (fs *FilePlayerStore) saveLocked() error {
+	if fs.path == "" {
+		return nil
+	}
+
+	records := make([]PlayerRecord, 0, len(fs.records))
+	for _, record := range fs.records {
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Identity < records[j].Identity
+	})
+
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := fs.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fs.path)
+}
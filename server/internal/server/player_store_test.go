@@ -0,0 +1,48 @@
+package server
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFilePlayerStorePrunes(t *testing.T) {
+	now := time.Now()
+	path := filepath.Join(t.TempDir(), "players.json")
+	store := NewFilePlayerStore(path, time.Hour, 2)
+
+	err := store.Save(
+		PlayerRecord{Identity: "expired", LastSeen: now.Add(-2 * time.Hour)},
+		PlayerRecord{Identity: "oldest", LastSeen: now.Add(-30 * time.Minute)},
+		PlayerRecord{Identity: "older", LastSeen: now.Add(-20 * time.Minute)},
+		PlayerRecord{Identity: "newest", LastSeen: now},
+		PlayerRecord{Identity: "", LastSeen: now},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := NewFilePlayerStore(path, time.Hour, 2)
+	if err := reopened.Open(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		identity string
+		want     bool
+	}{
+		{"expired", false},
+		{"oldest", false},
+		{"older", true},
+		{"newest", true},
+	}
+	for _, test := range tests {
+		t.Run(test.identity, func(t *testing.T) {
+			for name, s := range map[string]*FilePlayerStore{"in memory": store, "on disk": reopened} {
+				if _, found, _ := s.Load(test.identity); found != test.want {
+					t.Errorf("%s: found = %v, want %v", name, found, test.want)
+				}
+			}
+		})
+	}
+}
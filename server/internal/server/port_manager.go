@@ -2,6 +2,7 @@ package server
 
 import (
 	"errors"
+	"fmt"
 )
 
 // PortManager manages the allocation and release of UDP ports
@@ -37,6 +38,23 @@ func (pm *PortManager) AllocatePort() (int, error) {
 	}
 }
 
+// ClaimPort takes a specific port out of the pool, so a restored player keeps the port it had
+func (pm *PortManager) ClaimPort(port int) error {
+	found := false
+	for i, n := 0, len(pm.portPool); i < n; i++ {
+		available := <-pm.portPool
+		if available == port && !found {
+			found = true
+			continue
+		}
+		pm.portPool <- available
+	}
+	if !found {
+		return fmt.Errorf("port %d is not available", port)
+	}
+	return nil
+}
+
 // ReleasePort returns a port to the pool for reuse
 func (pm *PortManager) ReleasePort(port int) {
 	if port >= pm.minPort && port <= pm.maxPort {
@@ -0,0 +1,270 @@
+package server
+
+import (
+	"net"
+	"server/internal/command"
+	"sync"
+	"time"
+)
+
+// BucketConfig describes a token bucket: Rate tokens per second, up to Burst saved up
+type BucketConfig struct {
+	Rate  float64
+	Burst float64
+}
+
+// TokenBucket is a classic token bucket rate limiter
+type TokenBucket struct {
+	config BucketConfig
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucket creates a full bucket
+func NewTokenBucket(config BucketConfig, now time.Time) *TokenBucket {
+	return &TokenBucket{config: config, tokens: config.Burst, last: now}
+}
+
+// Allow takes a token if one is available
+func (b *TokenBucket) Allow(now time.Time) bool {
+	elapsed := now.Sub(b.last).Seconds()
+	b.last = now
+
+	b.tokens = min(b.config.Burst, b.tokens+elapsed*b.config.Rate)
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// RateLimitConfig holds all limits and the escalation policy for repeat offenders
+type RateLimitConfig struct {
+	Global     BucketConfig
+	PerAddress BucketConfig
+	PerPlayer  BucketConfig
+	PerCommand map[command.Command]BucketConfig // Applied per address and command
+
+	// MaxAddresses bounds how many addresses are tracked. Once reached, addresses without an
+	// entry share a single one, so spoofed sources cannot grow the table.
+	MaxAddresses int
+
+	// Violations of the per-address, per-player and per-command limits are counted per address
+	// within ViolationWindow. Reaching a threshold throttles (drops everything for ThrottleDuration),
+	// then kicks, then bans the IP. Only registered players, whose address was proven by a connect
+	// cookie, are kicked or banned: unverified source addresses may be spoofed, and banning them
+	// would lock out the real owner. An empty global bucket only drops packets, as it is
+	// drained by everyone together. Bans are recorded in the server's AccessList.
+	ViolationWindow  time.Duration
+	ThrottleAfter    int
+	KickAfter        int
+	BanAfter         int
+	ThrottleDuration time.Duration
+	BanDuration      time.Duration
+}
+
+// RateLimitVerdict is the outcome of a rate limit check
+type RateLimitVerdict int
+
+const (
+	VerdictAllow     RateLimitVerdict = iota
+	VerdictDrop                       // Over a limit, this packet is dropped
+	VerdictThrottled                  // Sender is throttled, everything is dropped
+	VerdictKick                       // Sender should be disconnected
+	VerdictBanned                     // Sender's IP should be banned for BanDuration
+)
+
+// RateLimitStats counts what the rate limiter did
+type RateLimitStats struct {
+	Allowed   uint64
+	Dropped   uint64
+	Throttled uint64
+	Kicks     uint64
+	Bans      uint64
+}
+
+// clientLimits holds the buckets and violation history of one address
+type clientLimits struct {
+	address        *TokenBucket
+	commands       map[command.Command]*TokenBucket
+	violations     int
+	windowStart    time.Time
+	throttledUntil time.Time
+	kicked         bool
+	lastSeen       time.Time
+}
+
+// RateLimiter applies global, per-address, per-player and per-command token buckets
+type RateLimiter struct {
+	config   RateLimitConfig
+	global   *TokenBucket
+	clients  map[string]*clientLimits
+	overflow *clientLimits // shared by unverified addresses once clients is full
+	players  map[uint8]*TokenBucket
+	stats    RateLimitStats
+	mu       sync.Mutex
+}
+
+// NewRateLimiter creates a rate limiter with the given configuration
+func NewRateLimiter(config RateLimitConfig) *RateLimiter {
+	now := time.Now()
+	return &RateLimiter{
+		config:   config,
+		global:   NewTokenBucket(config.Global, now),
+		clients:  make(map[string]*clientLimits),
+		overflow: newClientLimits(config, now),
+		players:  make(map[uint8]*TokenBucket),
+	}
+}
+
+// AllowPacket checks the per-address and global limits before a datagram is processed.
+// verified is set for registered senders, whose address may be kicked or banned.
+func (rl *RateLimiter) AllowPacket(addr *net.UDPAddr, verified bool, now time.Time) RateLimitVerdict {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	// Packets sent while throttled still count, so persistent flooders escalate to kick and ban
+	client, tracked := rl.client(addr, verified, now)
+	if now.Before(client.throttledUntil) {
+		return rl.violation(addr, client, verified && tracked, now)
+	}
+
+	if !client.address.Allow(now) {
+		return rl.violation(addr, client, verified && tracked, now)
+	}
+
+	// The global bucket is drained by everyone, so running out is no sender's fault
+	if !rl.global.Allow(now) {
+		rl.stats.Dropped++
+		return VerdictDrop
+	}
+
+	rl.stats.Allowed++
+	return VerdictAllow
+}
+
+// AllowCommand checks the per-command and per-player limits for a single message.
+// userID is 0 when the sender is not registered yet.
+func (rl *RateLimiter) AllowCommand(addr *net.UDPAddr, userID uint8, cmd command.Command, now time.Time) RateLimitVerdict {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	verified := userID != 0
+	client, tracked := rl.client(addr, verified, now)
+
+	if config, limited := rl.config.PerCommand[cmd]; limited {
+		bucket, exists := client.commands[cmd]
+		if !exists {
+			bucket = NewTokenBucket(config, now)
+			client.commands[cmd] = bucket
+		}
+		if !bucket.Allow(now) {
+			return rl.violation(addr, client, verified && tracked, now)
+		}
+	}
+
+	if verified {
+		bucket, exists := rl.players[userID]
+		if !exists {
+			bucket = NewTokenBucket(rl.config.PerPlayer, now)
+			rl.players[userID] = bucket
+		}
+		if !bucket.Allow(now) {
+			return rl.violation(addr, client, tracked, now)
+		}
+	}
+
+	return VerdictAllow
+}
+
+// ForgetPlayer drops the per-player bucket of a disconnected player
+func (rl *RateLimiter) ForgetPlayer(userID uint8) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	delete(rl.players, userID)
+}
+
+// Cleanup drops state of addresses not seen within idle
+func (rl *RateLimiter) Cleanup(idle time.Duration, now time.Time) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	for key, client := range rl.clients {
+		if now.Sub(client.lastSeen) > idle && now.After(client.throttledUntil) {
+			delete(rl.clients, key)
+		}
+	}
+}
+
+// Stats returns a copy of the counters
+func (rl *RateLimiter) Stats() RateLimitStats {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	return rl.stats
+}
+
+// newClientLimits creates the full buckets of a new address
+func newClientLimits(config RateLimitConfig, now time.Time) *clientLimits {
+	return &clientLimits{
+		address:     NewTokenBucket(config.PerAddress, now),
+		commands:    make(map[command.Command]*TokenBucket),
+		windowStart: now,
+	}
+}
+
+// client returns the limits of an address, and false for the shared overflow entry.
+// Verified addresses always get their own entry; there are at most as many as player slots.
+func (rl *RateLimiter) client(addr *net.UDPAddr, verified bool, now time.Time) (*clientLimits, bool) {
+	key := addr.String()
+	client, exists := rl.clients[key]
+	if !exists {
+		if !verified && rl.config.MaxAddresses > 0 && len(rl.clients) >= rl.config.MaxAddresses {
+			rl.overflow.lastSeen = now
+			return rl.overflow, false
+		}
+		client = newClientLimits(rl.config, now)
+		rl.clients[key] = client
+	}
+	client.lastSeen = now
+	return client, true
+}
+
+// violation records a limit violation and escalates repeat offenders.
+// Unverified senders are throttled at most. The shared overflow entry is never escalated, as
+// throttling it would lock out every new sender because of one flood.
+func (rl *RateLimiter) violation(addr *net.UDPAddr, client *clientLimits, verified bool, now time.Time) RateLimitVerdict {
+	if client == rl.overflow {
+		rl.stats.Dropped++
+		return VerdictDrop
+	}
+
+	if now.Sub(client.windowStart) > rl.config.ViolationWindow {
+		client.violations = 0
+		client.windowStart = now
+	}
+	client.violations++
+
+	if verified {
+		switch {
+		case rl.config.BanAfter > 0 && client.violations >= rl.config.BanAfter:
+			delete(rl.clients, addr.String())
+			rl.stats.Bans++
+			return VerdictBanned
+		case rl.config.KickAfter > 0 && client.violations >= rl.config.KickAfter && !client.kicked:
+			client.kicked = true
+			rl.stats.Kicks++
+			return VerdictKick
+		}
+	}
+
+	if rl.config.ThrottleAfter > 0 && client.violations >= rl.config.ThrottleAfter {
+		client.throttledUntil = now.Add(rl.config.ThrottleDuration)
+		rl.stats.Throttled++
+		return VerdictThrottled
+	}
+
+	rl.stats.Dropped++
+	return VerdictDrop
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func testLimits() RateLimitConfig {
+	return RateLimitConfig{
+		Global:           BucketConfig{Rate: 1e6, Burst: 1e6},
+		PerAddress:       BucketConfig{Rate: 1, Burst: 1},
+		PerPlayer:        BucketConfig{Rate: 1e6, Burst: 1e6},
+		MaxAddresses:     1,
+		ViolationWindow:  time.Minute,
+		ThrottleAfter:    3,
+		KickAfter:        5,
+		BanAfter:         7,
+		ThrottleDuration: time.Second,
+		BanDuration:      time.Minute,
+	}
+}
+
+func TestRateLimiterEscalation(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		verified bool
+		want     []RateLimitVerdict
+	}{
+		{
+			name:     "registered sender is throttled, kicked once, then banned",
+			verified: true,
+			want: []RateLimitVerdict{VerdictAllow, VerdictDrop, VerdictDrop, VerdictThrottled,
+				VerdictThrottled, VerdictKick, VerdictThrottled, VerdictBanned},
+		},
+		{
+			name:     "unverified sender is throttled at most",
+			verified: false,
+			want: []RateLimitVerdict{VerdictAllow, VerdictDrop, VerdictDrop, VerdictThrottled,
+				VerdictThrottled, VerdictThrottled, VerdictThrottled, VerdictThrottled},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := NewRateLimiter(testLimits())
+			addr := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 1000}
+			for i, want := range test.want {
+				if got := rl.AllowPacket(addr, test.verified, now); got != want {
+					t.Errorf("packet %d: verdict %d, want %d", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimiterOverflowIsNeverThrottled(t *testing.T) {
+	now := time.Now()
+	rl := NewRateLimiter(testLimits())
+
+	// The only tracked address fills the table, everyone else shares the overflow entry
+	tracked := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 1000}
+	if got := rl.AllowPacket(tracked, false, now); got != VerdictAllow {
+		t.Fatalf("tracked sender: verdict %d", got)
+	}
+
+	for i := 0; i < 100; i++ {
+		spoofed := &net.UDPAddr{IP: net.IPv4(10, 1, byte(i>>8), byte(i)), Port: 2000}
+		if got := rl.AllowPacket(spoofed, false, now); got == VerdictThrottled {
+			t.Fatalf("flood packet %d throttled the shared entry", i)
+		}
+	}
+	if stats := rl.Stats(); stats.Throttled != 0 {
+		t.Errorf("throttled %d times, want 0", stats.Throttled)
+	}
+
+	// Once the shared bucket refills, a new sender gets through
+	later := now.Add(time.Second)
+	if got := rl.AllowPacket(&net.UDPAddr{IP: net.IPv4(10, 2, 0, 1), Port: 3000}, false, later); got != VerdictAllow {
+		t.Errorf("new sender after the flood: verdict %d, want allow", got)
+	}
+	if got := rl.AllowPacket(tracked, false, later); got != VerdictAllow {
+		t.Errorf("tracked sender after the flood: verdict %d, want allow", got)
+	}
+}
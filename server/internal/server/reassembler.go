@@ -0,0 +1,137 @@
+package server
+
+import (
+	"errors"
+	"server/internal/message"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrFragmentMemoryLimit is returned when a sender has too many fragment bytes pending
+	ErrFragmentMemoryLimit = errors.New("fragment memory limit exceeded")
+	// ErrFragmentTotalLimit is returned when all senders together have too many fragment bytes pending
+	ErrFragmentTotalLimit = errors.New("total fragment memory limit exceeded")
+)
+
+// partialMessage collects the fragments of one message
+type partialMessage struct {
+	parts    [][]byte
+	received int
+	size     int
+	started  time.Time
+}
+
+// senderFragments holds all incomplete messages of one sender
+type senderFragments struct {
+	messages map[uint16]*partialMessage
+	bytes    int
+}
+
+// Reassembler rebuilds fragmented messages per sender with timeouts and memory limits
+type Reassembler struct {
+	timeout        time.Duration
+	maxSenderBytes int
+	maxTotalBytes  int
+	totalBytes     int
+	senders        map[string]*senderFragments
+	mu             sync.Mutex
+}
+
+// NewReassembler creates a reassembler that drops incomplete messages after timeout and never
+// buffers more than maxSenderBytes for a single sender or maxTotalBytes for all of them
+func NewReassembler(timeout time.Duration, maxSenderBytes, maxTotalBytes int) *Reassembler {
+	return &Reassembler{
+		timeout:        timeout,
+		maxSenderBytes: maxSenderBytes,
+		maxTotalBytes:  maxTotalBytes,
+		senders:        make(map[string]*senderFragments),
+	}
+}
+
+// Add stores a fragment and returns the full message once every fragment has arrived
+func (r *Reassembler) Add(sender string, frag message.Fragment, now time.Time) ([]byte, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fragments, exists := r.senders[sender]
+	if !exists {
+		fragments = &senderFragments{messages: make(map[uint16]*partialMessage)}
+		r.senders[sender] = fragments
+	}
+
+	partial, exists := fragments.messages[frag.MessageID]
+	if exists && (len(partial.parts) != int(frag.Count) || now.Sub(partial.started) > r.timeout) {
+		// A stale message with a reused ID is replaced
+		r.drop(fragments, frag.MessageID)
+		exists = false
+	}
+	if !exists {
+		partial = &partialMessage{parts: make([][]byte, frag.Count), started: now}
+		fragments.messages[frag.MessageID] = partial
+	}
+
+	if partial.parts[frag.Index] != nil {
+		return nil, false, nil // Duplicate
+	}
+	if fragments.bytes+len(frag.Payload) > r.maxSenderBytes {
+		r.drop(fragments, frag.MessageID)
+		r.forgetIdle(sender, fragments)
+		return nil, false, ErrFragmentMemoryLimit
+	}
+	if r.totalBytes+len(frag.Payload) > r.maxTotalBytes {
+		r.drop(fragments, frag.MessageID)
+		r.forgetIdle(sender, fragments)
+		return nil, false, ErrFragmentTotalLimit
+	}
+
+	// Payload aliases the receive buffer, so it is copied
+	partial.parts[frag.Index] = append([]byte(nil), frag.Payload...)
+	partial.received++
+	partial.size += len(frag.Payload)
+	fragments.bytes += len(frag.Payload)
+	r.totalBytes += len(frag.Payload)
+
+	if partial.received < len(partial.parts) {
+		return nil, false, nil
+	}
+
+	data := make([]byte, 0, partial.size)
+	for _, part := range partial.parts {
+		data = append(data, part...)
+	}
+	r.drop(fragments, frag.MessageID)
+	r.forgetIdle(sender, fragments)
+	return data, true, nil
+}
+
+// Expire drops incomplete messages older than the timeout
+func (r *Reassembler) Expire(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for sender, fragments := range r.senders {
+		for id, partial := range fragments.messages {
+			if now.Sub(partial.started) > r.timeout {
+				r.drop(fragments, id)
+			}
+		}
+		r.forgetIdle(sender, fragments)
+	}
+}
+
+// drop discards an incomplete message of a sender
+func (r *Reassembler) drop(fragments *senderFragments, messageID uint16) {
+	if partial, exists := fragments.messages[messageID]; exists {
+		fragments.bytes -= partial.size
+		r.totalBytes -= partial.size
+		delete(fragments.messages, messageID)
+	}
+}
+
+// forgetIdle removes a sender without incomplete messages
+func (r *Reassembler) forgetIdle(sender string, fragments *senderFragments) {
+	if len(fragments.messages) == 0 {
+		delete(r.senders, sender)
+	}
+}
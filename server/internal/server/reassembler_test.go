@@ -0,0 +1,93 @@
+package server
+
+import (
+	"errors"
+	"server/internal/command"
+	"server/internal/message"
+	"testing"
+	"time"
+)
+
+// fragment builds fragment index of count of a message
+func fragment(id uint16, index, count uint8, payload string) message.Fragment {
+	return message.Fragment{CommandID: command.FRAGMENT, MessageID: id, Index: index, Count: count, Payload: []byte(payload)}
+}
+
+func TestReassemblerAdd(t *testing.T) {
+	now := time.Now()
+	r := NewReassembler(time.Second, 100, 1000)
+
+	// Fragments complete a message in any order, and duplicates are ignored
+	for _, frag := range []message.Fragment{fragment(1, 2, 3, "ef"), fragment(1, 0, 3, "ab"), fragment(1, 2, 3, "xx")} {
+		if data, done, err := r.Add("a", frag, now); done || err != nil {
+			t.Fatalf("fragment %d: %q, %v, %v", frag.Index, data, done, err)
+		}
+	}
+	if r.totalBytes != 4 {
+		t.Errorf("%d bytes buffered after a duplicate, want 4", r.totalBytes)
+	}
+	data, done, err := r.Add("a", fragment(1, 1, 3, "cd"), now)
+	if !done || err != nil || string(data) != "abcdef" {
+		t.Fatalf("last fragment: %q, %v, %v, want abcdef", data, done, err)
+	}
+	if r.totalBytes != 0 || len(r.senders) != 0 {
+		t.Errorf("%d bytes and %d senders left after completing", r.totalBytes, len(r.senders))
+	}
+
+	// A message ID reused with another count replaces the old message
+	r.Add("a", fragment(2, 0, 2, "old"), now)
+	r.Add("a", fragment(2, 0, 3, "n"), now)
+	if r.totalBytes != 1 {
+		t.Errorf("%d bytes buffered after the ID was reused, want 1", r.totalBytes)
+	}
+	r.Add("a", fragment(2, 1, 3, "e"), now)
+	if data, done, _ := r.Add("a", fragment(2, 2, 3, "w"), now); !done || string(data) != "new" {
+		t.Errorf("reused ID completed as %q, %v, want new", data, done)
+	}
+}
+
+func TestReassemblerLimits(t *testing.T) {
+	now := time.Now()
+	r := NewReassembler(time.Second, 10, 15)
+
+	r.Add("a", fragment(1, 0, 2, "123456"), now)
+	if _, _, err := r.Add("a", fragment(2, 0, 2, "123456"), now); !errors.Is(err, ErrFragmentMemoryLimit) {
+		t.Errorf("sender over its limit: %v, want ErrFragmentMemoryLimit", err)
+	}
+	// The message that went over is dropped, the sender's other message is kept
+	if data, done, err := r.Add("a", fragment(1, 1, 2, "78"), now); !done || err != nil || string(data) != "12345678" {
+		t.Errorf("message under the limit: %q, %v, %v", data, done, err)
+	}
+
+	r.Add("a", fragment(3, 0, 2, "12345678"), now)
+	if _, _, err := r.Add("b", fragment(1, 0, 2, "12345678"), now); !errors.Is(err, ErrFragmentTotalLimit) {
+		t.Errorf("senders over the total limit: %v, want ErrFragmentTotalLimit", err)
+	}
+	if _, exists := r.senders["b"]; exists {
+		t.Error("rejected sender is still tracked")
+	}
+	if r.totalBytes != 8 {
+		t.Errorf("%d bytes buffered, want 8", r.totalBytes)
+	}
+}
+
+func TestReassemblerExpire(t *testing.T) {
+	now := time.Now()
+	r := NewReassembler(time.Second, 100, 1000)
+	r.Add("a", fragment(1, 0, 2, "old"), now)
+	r.Add("b", fragment(1, 0, 2, "new"), now.Add(time.Second))
+
+	r.Expire(now.Add(time.Second))
+	if r.totalBytes != 6 {
+		t.Fatalf("%d bytes left before the timeout, want 6", r.totalBytes)
+	}
+	r.Expire(now.Add(time.Second + time.Millisecond))
+	if _, exists := r.senders["a"]; exists || r.totalBytes != 3 {
+		t.Errorf("after the timeout: sender a kept %v, %d bytes, want 3", exists, r.totalBytes)
+	}
+
+	// A late fragment of an expired message starts over rather than completing it
+	if _, done, _ := r.Add("a", fragment(1, 1, 2, "er"), now.Add(2*time.Second)); done {
+		t.Error("late fragment completed an expired message")
+	}
+}
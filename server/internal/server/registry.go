@@ -0,0 +1,50 @@
+package server
+
+import (
+	"server/internal/game"
+	"sort"
+	"sync/atomic"
+)
+
+// playerSnapshot is an immutable view of the connected players. It is replaced, never changed,
+// when players join or leave, so readers can use it without locking.
+type playerSnapshot struct {
+	byID   map[uint8]*game.Player
+	byAddr map[string]*game.Player // IP:Port -> player
+	list   []*game.Player          // ordered by ID
+}
+
+// playerRegistry publishes copy-on-write snapshots of the connected players. The client manager
+// builds a new snapshot under its write lock whenever its player maps change; packet handlers and
+// broadcasts load the current one with a single atomic read.
+type playerRegistry struct {
+	current atomic.Pointer[playerSnapshot]
+}
+
+// newPlayerRegistry creates a registry holding an empty snapshot
+func newPlayerRegistry() *playerRegistry {
+	r := &playerRegistry{}
+	r.publish(nil)
+	return r
+}
+
+// load returns the current snapshot
+func (r *playerRegistry) load() *playerSnapshot {
+	return r.current.Load()
+}
+
+// publish replaces the snapshot with one of the given players
+func (r *playerRegistry) publish(players map[uint8]*game.Player) {
+	snapshot := &playerSnapshot{
+		byID:   make(map[uint8]*game.Player, len(players)),
+		byAddr: make(map[string]*game.Player, len(players)),
+		list:   make([]*game.Player, 0, len(players)),
+	}
+	for id, player := range players {
+		snapshot.byID[id] = player
+		snapshot.byAddr[player.Address.String()] = player
+		snapshot.list = append(snapshot.list, player)
+	}
+	sort.Slice(snapshot.list, func(i, j int) bool { return snapshot.list[i].ID < snapshot.list[j].ID })
+	r.current.Store(snapshot)
+}
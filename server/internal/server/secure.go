@@ -0,0 +1,321 @@
+package server
+
+import (
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"server/internal/command"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// secureHeaderSize is the SECURE command byte plus the 8-byte nonce counter
+const secureHeaderSize = 9
+
+// replayWindowSize is how many counters behind the highest one are still accepted
+const replayWindowSize = 64
+
+// keyExchangeContext prefixes the transcript the server signs, so the signature can't be reused elsewhere
+const keyExchangeContext = "masters-thesis key exchange"
+
+var (
+	ErrNoSession       = errors.New("no secure session for address")
+	ErrReplay          = errors.New("replayed or too old counter")
+	ErrNoIdentity      = errors.New("no identity key to sign the key exchange")
+	ErrSessionExists   = errors.New("session already established, proof of its key required")
+	ErrTooManySessions = errors.New("too many secure sessions")
+)
+
+// replayWindow tracks received counters with a sliding bitmap
+type replayWindow struct {
+	highest uint64
+	bitmap  uint64 // bit i set means highest-i was received
+}
+
+func (w *replayWindow) seen(counter uint64) bool {
+	if counter == 0 {
+		return true
+	}
+	if counter > w.highest {
+		return false
+	}
+	diff := w.highest - counter
+	if diff >= replayWindowSize {
+		return true
+	}
+	return w.bitmap&(1<<diff) != 0
+}
+
+func (w *replayWindow) accept(counter uint64) {
+	if counter > w.highest {
+		shift := counter - w.highest
+		if shift >= replayWindowSize {
+			w.bitmap = 0
+		} else {
+			w.bitmap <<= shift
+		}
+		w.bitmap |= 1
+		w.highest = counter
+		return
+	}
+	w.bitmap |= 1 << (w.highest - counter)
+}
+
+// SecureSession holds the keys and counters of one client after the key exchange
+type SecureSession struct {
+	recv     cipher.AEAD // client -> server
+	send     cipher.AEAD // server -> client
+	rekey    []byte      // proves a later key exchange comes from the same client
+	sendCtr  atomic.Uint64
+	window   replayWindow
+	lastSeen time.Time
+	mu       sync.Mutex
+}
+
+// newSecureSession derives both directional keys and the rekey key from the X25519 shared secret
+func newSecureSession(shared, clientPub, serverPub []byte) (*SecureSession, error) {
+	prk := hmacSHA256([]byte("masters-thesis secure transport"), shared)
+	transcript := append(append([]byte(nil), clientPub...), serverPub...)
+
+	recv, err := chacha20poly1305.New(hmacSHA256(prk, append([]byte("client->server"), transcript...)))
+	if err != nil {
+		return nil, err
+	}
+	send, err := chacha20poly1305.New(hmacSHA256(prk, append([]byte("server->client"), transcript...)))
+	if err != nil {
+		return nil, err
+	}
+	rekey := hmacSHA256(prk, append([]byte("rekey"), transcript...))
+	return &SecureSession{recv: recv, send: send, rekey: rekey, lastSeen: time.Now()}, nil
+}
+
+// RekeyProof is what a client sends with a new public key to replace this session:
+// a MAC of the key under a secret only the two ends of the session know
+func (ss *SecureSession) RekeyProof(clientPub []byte) []byte {
+	return hmacSHA256(ss.rekey, clientPub)
+}
+
+// Seal wraps a datagram into a SECURE envelope
+func (ss *SecureSession) Seal(plaintext []byte) []byte {
+	counter := ss.sendCtr.Add(1)
+
+	header := make([]byte, secureHeaderSize, secureHeaderSize+len(plaintext)+ss.send.Overhead())
+	header[0] = uint8(command.SECURE)
+	binary.LittleEndian.PutUint64(header[1:], counter)
+
+	return ss.send.Seal(header, nonceFor(counter), plaintext, header)
+}
+
+// Open authenticates and decrypts a SECURE envelope, rejecting replays
+func (ss *SecureSession) Open(envelope []byte) ([]byte, error) {
+	if len(envelope) < secureHeaderSize+ss.recv.Overhead() {
+		return nil, errors.New("insufficient data for secure envelope")
+	}
+
+	header := envelope[:secureHeaderSize]
+	counter := binary.LittleEndian.Uint64(header[1:])
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if ss.window.seen(counter) {
+		return nil, ErrReplay
+	}
+
+	plaintext, err := ss.recv.Open(nil, nonceFor(counter), envelope[secureHeaderSize:], header)
+	if err != nil {
+		return nil, err
+	}
+
+	ss.window.accept(counter)
+	ss.lastSeen = time.Now()
+	return plaintext, nil
+}
+
+// SessionManager performs key exchanges and maps addresses to secure sessions
+type SessionManager struct {
+	sessions    map[string]*SecureSession
+	identity    ed25519.PrivateKey // signs the server's key share, pinned by clients
+	maxSessions int
+	mu          sync.RWMutex
+}
+
+// NewSessionManager creates an empty session manager mapping at most maxSessions addresses
+func NewSessionManager(maxSessions int) *SessionManager {
+	return &SessionManager{
+		sessions:    make(map[string]*SecureSession),
+		maxSessions: maxSessions,
+	}
+}
+
+// SetIdentity sets the static key key exchanges are signed with
+func (sm *SessionManager) SetIdentity(identity ed25519.PrivateKey) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	sm.identity = identity
+}
+
+// KeyExchange completes an X25519 exchange with a client public key. It returns the server's ephemeral
+// public key and its signature by the identity key, so the client knows the share is the server's.
+// An address with a session can only replace it with the proof of that session's rekey key, so a spoofed
+// exchange can't take over a client's session.
+func (sm *SessionManager) KeyExchange(addr *net.UDPAddr, clientPub, proof []byte) ([]byte, []byte, error) {
+	sm.mu.RLock()
+	identity := sm.identity
+	existing, exists := sm.sessions[addr.String()]
+	full := len(sm.sessions) >= sm.maxSessions
+	sm.mu.RUnlock()
+
+	if identity == nil {
+		return nil, nil, ErrNoIdentity
+	}
+	if exists && !hmac.Equal(proof, existing.RekeyProof(clientPub)) {
+		return nil, nil, ErrSessionExists
+	}
+	if !exists && full {
+		return nil, nil, ErrTooManySessions
+	}
+
+	peer, err := ecdh.X25519().NewPublicKey(clientPub)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid client public key: %w", err)
+	}
+
+	priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	shared, err := priv.ECDH(peer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serverPub := priv.PublicKey().Bytes()
+	session, err := newSecureSession(shared, clientPub, serverPub)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	transcript := append(append([]byte(keyExchangeContext), clientPub...), serverPub...)
+	signature := ed25519.Sign(identity, transcript)
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	// Addresses bound to the old session, such as the player's listen port, move to the new one
+	if exists {
+		for key, bound := range sm.sessions {
+			if bound == existing {
+				sm.sessions[key] = session
+			}
+		}
+	}
+	sm.sessions[addr.String()] = session
+	return serverPub, signature, nil
+}
+
+// Bind makes an additional address, such as a player's listen port, use an existing session
+func (sm *SessionManager) Bind(from, to *net.UDPAddr) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session, exists := sm.sessions[from.String()]
+	if !exists {
+		return
+	}
+	if _, bound := sm.sessions[to.String()]; !bound && len(sm.sessions) >= sm.maxSessions {
+		return
+	}
+	sm.sessions[to.String()] = session
+}
+
+// Get returns the session for an address
+func (sm *SessionManager) Get(addr *net.UDPAddr) (*SecureSession, bool) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	session, exists := sm.sessions[addr.String()]
+	return session, exists
+}
+
+// Open decrypts a SECURE envelope received from addr
+func (sm *SessionManager) Open(addr *net.UDPAddr, envelope []byte) ([]byte, error) {
+	session, exists := sm.Get(addr)
+	if !exists {
+		return nil, ErrNoSession
+	}
+	return session.Open(envelope)
+}
+
+// CleanupIdle drops sessions that have not received anything within timeout
+func (sm *SessionManager) CleanupIdle(timeout time.Duration) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	for key, session := range sm.sessions {
+		session.mu.Lock()
+		idle := time.Since(session.lastSeen) > timeout
+		session.mu.Unlock()
+
+		if idle {
+			delete(sm.sessions, key)
+		}
+	}
+}
+
+// LoadIdentityKey reads the server's static Ed25519 key, stored as a hex seed. A missing file is
+// created with a new key, so the server keeps its identity across restarts once clients pinned it.
+// An empty path returns a new key that only lasts until the server stops.
+func LoadIdentityKey(path string) (ed25519.PrivateKey, error) {
+	if path == "" {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return key, os.WriteFile(path, []byte(hex.EncodeToString(key.Seed())+"\n"), 0o600)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	seed, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("%s does not hold a hex Ed25519 seed", path)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// nonceFor builds the 12-byte AEAD nonce from a counter.
+// Each direction has its own key, so counters never repeat under the same key.
+func nonceFor(counter uint64) []byte {
+	nonce := make([]byte, 12)
+	binary.LittleEndian.PutUint64(nonce[4:], counter)
+	return nonce
+}
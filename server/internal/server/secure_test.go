@@ -0,0 +1,223 @@
+package server
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"errors"
+	"net"
+	"server/internal/command"
+	"server/internal/message"
+	"testing"
+	"time"
+)
+
+// keyExchangeRequest builds a KEY_EXCHANGE request padded to message.KeyExchangeSize
+func keyExchangeRequest(t *testing.T, cookie [CookieSize]byte) []byte {
+	t.Helper()
+
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := make([]byte, message.KeyExchangeSize)
+	request[0] = byte(command.KEY_EXCHANGE)
+	copy(request[1:], key.PublicKey().Bytes())
+	copy(request[33:], cookie[:])
+	return request
+}
+
+func TestKeyExchangeRequiresCookie(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.SecureTransport = true
+	s := NewServerWithConfig(cfg)
+
+	identity, err := LoadIdentityKey("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.sessions.SetIdentity(identity)
+	if s.cookies, err = NewCookieIssuer(time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	loopback := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}
+	if s.conn, err = net.ListenUDP("udp", loopback); err != nil {
+		t.Fatal(err)
+	}
+	defer s.conn.Close()
+	client, err := net.ListenUDP("udp", loopback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	clientAddr := client.LocalAddr().(*net.UDPAddr)
+
+	// exchange hands the request to the server and returns its reply, nil for none
+	exchange := func(request []byte) []byte {
+		s.handleKeyExchange(clientAddr, request)
+
+		buffer := make([]byte, 1500)
+		client.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		n, err := client.Read(buffer)
+		if err != nil {
+			return nil
+		}
+		return buffer[:n]
+	}
+
+	if reply := exchange(keyExchangeRequest(t, [CookieSize]byte{})[:33]); reply != nil {
+		t.Errorf("unpadded request answered with %d bytes", len(reply))
+	}
+
+	request := keyExchangeRequest(t, [CookieSize]byte{})
+	challenge := exchange(request)
+	if len(challenge) == 0 || command.Command(challenge[0]) != command.CONNECT_CHALLENGE {
+		t.Fatalf("request without cookie answered with %v, want a challenge", challenge)
+	}
+	if len(challenge) > len(request) {
+		t.Errorf("challenge of %d bytes for a request of %d", len(challenge), len(request))
+	}
+	if _, exists := s.sessions.Get(clientAddr); exists {
+		t.Error("session allocated before the cookie was echoed")
+	}
+
+	var cookie [CookieSize]byte
+	copy(cookie[:], challenge[1:])
+	request = keyExchangeRequest(t, cookie)
+	reply := exchange(request)
+	if len(reply) == 0 || command.Command(reply[0]) != command.KEY_EXCHANGE {
+		t.Fatalf("request with cookie answered with %v, want a key exchange", reply)
+	}
+	if len(reply) > len(request) {
+		t.Errorf("reply of %d bytes for a request of %d", len(reply), len(request))
+	}
+	if _, exists := s.sessions.Get(clientAddr); !exists {
+		t.Error("no session after the key exchange")
+	}
+
+	// A cookie is bound to its address
+	other := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 2), Port: clientAddr.Port}
+	s.handleKeyExchange(other, keyExchangeRequest(t, cookie))
+	if _, exists := s.sessions.Get(other); exists {
+		t.Error("cookie accepted from another address")
+	}
+}
+
+// clientSealer returns a session sealing what server opens, as the client's end of the session would
+func clientSealer(t *testing.T) (server, client *SecureSession) {
+	t.Helper()
+
+	shared := make([]byte, 32)
+	rand.Read(shared)
+	server, err := newSecureSession(shared, []byte("client"), []byte("server"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server, &SecureSession{send: server.recv, recv: server.send}
+}
+
+// sealAt seals plaintext with the given counter
+func sealAt(client *SecureSession, counter uint64, plaintext []byte) []byte {
+	client.sendCtr.Store(counter - 1)
+	return client.Seal(plaintext)
+}
+
+func TestSecureSessionOpen(t *testing.T) {
+	server, client := clientSealer(t)
+
+	open := func(counter uint64) error {
+		_, err := server.Open(sealAt(client, counter, []byte{byte(command.POSITION)}))
+		return err
+	}
+
+	if err := open(0); !errors.Is(err, ErrReplay) {
+		t.Errorf("counter 0: %v, want ErrReplay", err)
+	}
+	if err := open(100); err != nil {
+		t.Fatalf("counter 100: %v", err)
+	}
+	if err := open(100); !errors.Is(err, ErrReplay) {
+		t.Errorf("duplicate counter: %v, want ErrReplay", err)
+	}
+
+	// Out of order but inside the window is accepted once
+	if err := open(90); err != nil {
+		t.Errorf("counter 90 after 100: %v", err)
+	}
+	if err := open(90); !errors.Is(err, ErrReplay) {
+		t.Errorf("duplicate counter 90: %v, want ErrReplay", err)
+	}
+	if err := open(100 - replayWindowSize + 1); err != nil {
+		t.Errorf("oldest counter in the window: %v", err)
+	}
+	if err := open(100 - replayWindowSize); !errors.Is(err, ErrReplay) {
+		t.Errorf("counter %d behind: %v, want ErrReplay", replayWindowSize, err)
+	}
+
+	// Moving far ahead forgets everything behind the new window
+	if err := open(1000); err != nil {
+		t.Fatalf("counter 1000: %v", err)
+	}
+	if err := open(101); !errors.Is(err, ErrReplay) {
+		t.Errorf("counter 101 after 1000: %v, want ErrReplay", err)
+	}
+
+	envelope := sealAt(client, 1001, []byte("payload"))
+	for _, i := range []int{1, secureHeaderSize, len(envelope) - 1} {
+		tampered := append([]byte(nil), envelope...)
+		tampered[i] ^= 1
+		if _, err := server.Open(tampered); err == nil {
+			t.Errorf("envelope with byte %d flipped was opened", i)
+		}
+	}
+	if plaintext, err := server.Open(envelope); err != nil || string(plaintext) != "payload" {
+		t.Errorf("untampered envelope: %q, %v", plaintext, err)
+	}
+}
+
+func TestKeyExchangeRekeyProof(t *testing.T) {
+	sm := NewSessionManager(4)
+	identity, err := LoadIdentityKey("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sm.SetIdentity(identity)
+
+	publicKey := func() []byte {
+		key, err := ecdh.X25519().GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key.PublicKey().Bytes()
+	}
+
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 5000}
+	listen := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 5001}
+	if _, _, err := sm.KeyExchange(addr, publicKey(), nil); err != nil {
+		t.Fatal(err)
+	}
+	sm.Bind(addr, listen)
+	original, _ := sm.Get(addr)
+
+	next := publicKey()
+	if _, _, err := sm.KeyExchange(addr, next, nil); !errors.Is(err, ErrSessionExists) {
+		t.Errorf("rekey without proof: %v, want ErrSessionExists", err)
+	}
+	if _, _, err := sm.KeyExchange(addr, next, original.RekeyProof(publicKey())); !errors.Is(err, ErrSessionExists) {
+		t.Errorf("rekey with the proof of another key: %v, want ErrSessionExists", err)
+	}
+	if session, _ := sm.Get(addr); session != original {
+		t.Fatal("a rejected rekey replaced the session")
+	}
+
+	if _, _, err := sm.KeyExchange(addr, next, original.RekeyProof(next)); err != nil {
+		t.Fatalf("rekey with proof: %v", err)
+	}
+	session, _ := sm.Get(addr)
+	if session == original {
+		t.Error("rekey kept the old session")
+	}
+	if bound, _ := sm.Get(listen); bound != session {
+		t.Error("bound address still uses the old session")
+	}
+}
@@ -1,11 +1,17 @@
 package server
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net"
+	"net/http"
+	"os"
 	"server/internal/command"
+	"server/internal/game"
 	"server/internal/message"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -14,27 +20,208 @@ type Server struct {
 	conn          *net.UDPConn
 	address       string
 	clientManager *ClientManager
+	matchmaker    *game.Matchmaker
 	serializer    *message.Serializer
-	tickRate      time.Duration
+	snapInterval  time.Duration
+	snapBudget    int   // entry bytes per snapshot and client, 0 for unlimited
+	configErr     error // reported by Start
+	matchInterval time.Duration
+	compact       *message.QuantizationConfig
+	outbox        *Outbox
+	flushInterval time.Duration
+	mtu           int
+	bufferSize    int
+	reassembler   *Reassembler
+	fragTimeout   time.Duration
+	nextFragID    atomic.Uint32
+	sessions      *SessionManager
+	secure        bool
+	identityPath  string
+	cookies       *CookieIssuer // nil when connect cookies are disabled
+	useCookies    bool
+	cookieLife    time.Duration
+	limiter       *RateLimiter
+	banDuration   time.Duration
+	access        *AccessList
+	players       PlayerStore
+	departed      map[string]PlayerRecord // left since the last savePlayers, by identity
+	departedMu    sync.Mutex
+	playerFile    *FilePlayerStore // nil when a custom store is configured
+	statePath     string
+	stateMaxAge   time.Duration
+	shards        *ShardPool
+	entityLast    []time.Time // last entity tick per shard, only touched by that shard
+	adminAddress  string
+	admin         *http.Server // nil when the admin interface is disabled
+	movement      *game.MovementValidator
+	audit         *game.AuditLog
+	auditPath     string
+	authoritative bool
+	historyRate   time.Duration
+	levelPath     string
+	entityRate    time.Duration
+	transformRate time.Duration
+	schemas       map[game.EntityKind]game.PropertySchema
+	propBudget    int
+	propPending   int // pending reliable messages past which property updates wait
+	resendRate    time.Duration
+	pingRate      time.Duration
+	chat          *Chat
 }
 
-// NewServer creates a new UDP game server
+// auditLogSize is how many movement violations are kept in memory for the admin interface
+const auditLogSize = 256
+
+// portRequestSize is the size of a PORT_REQUEST carrying a cookie, 1+16
+const portRequestSize = 1 + CookieSize
+
+// NewServer creates a new UDP game server with the default configuration
 func NewServer(address string, minPort, maxPort int) *Server {
-	return &Server{
-		address:       address,
-		clientManager: NewClientManager(minPort, maxPort),
+	cfg := DefaultConfig()
+	cfg.Address = address
+	cfg.MinPort = minPort
+	cfg.MaxPort = maxPort
+	return NewServerWithConfig(cfg)
+}
+
+// NewServerWithConfig creates a new UDP game server from the given configuration
+func NewServerWithConfig(cfg Config) *Server {
+	s := &Server{
+		address:       cfg.Address,
+		configErr:     cfg.Validate(),
+		clientManager: NewClientManager(cfg),
+		matchmaker:    game.NewMatchmaker(cfg.Matchmaking),
 		serializer:    message.NewSerializer(),
-		tickRate:      time.Millisecond, // 1ms tick rate
+		snapInterval:  cfg.SnapshotInterval,
+		snapBudget:    cfg.snapshotBudgetBytes(),
+		matchInterval: cfg.MatchmakingInterval,
+		compact:       cfg.CompactPositions,
+		flushInterval: cfg.FlushInterval,
+		mtu:           cfg.MTU,
+		bufferSize:    cfg.ReceiveBufferSize,
+		reassembler:   NewReassembler(cfg.FragmentTimeout, cfg.FragmentMemoryLimit, cfg.FragmentTotalLimit),
+		fragTimeout:   cfg.FragmentTimeout,
+		sessions:      NewSessionManager(cfg.SecureMaxSessions),
+		identityPath:  cfg.SecureIdentityPath,
+		secure:        cfg.SecureTransport,
+		useCookies:    cfg.ConnectCookies,
+		cookieLife:    cfg.CookieLifetime,
+		limiter:       NewRateLimiter(cfg.RateLimits),
+		banDuration:   cfg.RateLimits.BanDuration,
+		access:        NewAccessList(cfg.AccessListPath),
+		adminAddress:  cfg.AdminAddress,
+		movement:      game.NewMovementValidator(cfg.Movement),
+		auditPath:     cfg.MovementAuditPath,
+		authoritative: cfg.AuthoritativeMovement,
+		historyRate:   cfg.LagCompensation.HistoryInterval,
+		levelPath:     cfg.LevelPath,
+		entityRate:    cfg.EntityTickInterval,
+		transformRate: cfg.EntityTransformInterval,
+		schemas:       cfg.EntityProperties,
+		propBudget:    cfg.PropertyBudget,
+		propPending:   max(cfg.ReliableMaxPending/2, 1),
+		resendRate:    cfg.ReliableResendInterval,
+		pingRate:      cfg.PingInterval,
+		chat:          NewChat(cfg.Chat),
+		statePath:     cfg.StatePath,
+		stateMaxAge:   cfg.StateMaxAge,
+		shards:        NewShardPool(cfg.shardCount(), cfg.ShardQueueSize),
 	}
+	s.entityLast = make([]time.Time, s.shards.Count())
+	s.outbox = NewOutbox(s.serializer, cfg.MTU, s.writeDatagram)
+
+	s.players = cfg.PlayerStore
+	s.departed = make(map[string]PlayerRecord)
+	if s.players == nil {
+		s.playerFile = NewFilePlayerStore(cfg.PlayerStorePath, cfg.PlayerRecordMaxAge, cfg.PlayerRecordLimit)
+		s.players = s.playerFile
+	}
+	return s
 }
 
 // Start starts the UDP server
 func (s *Server) Start() error {
+	if s.configErr != nil {
+		return fmt.Errorf("invalid config: %w", s.configErr)
+	}
+
 	udpAddr, err := net.ResolveUDPAddr("udp", s.address)
 	if err != nil {
 		return fmt.Errorf("failed to resolve UDP address: %w", err)
 	}
 
+	if s.compact != nil {
+		quantizer, err := message.NewQuantizer(*s.compact)
+		if err != nil {
+			return fmt.Errorf("invalid compact position config: %w", err)
+		}
+		s.serializer.SetQuantizer(quantizer)
+
+		log.Printf("Compact positions: %d bytes instead of 18 (%.0f%% smaller), max error %.4f units, %.4f rad",
+			quantizer.MessageSize(), 100*(1-float64(quantizer.MessageSize())/18),
+			quantizer.MaxPositionError(), quantizer.MaxRotationError())
+	}
+
+	if s.secure {
+		identity, err := LoadIdentityKey(s.identityPath)
+		if err != nil {
+			return fmt.Errorf("failed to load identity key: %w", err)
+		}
+		s.sessions.SetIdentity(identity)
+		log.Printf("Secure transport identity: %x", identity.Public())
+	}
+
+	// Key exchanges always need a cookie, registrations only with ConnectCookies
+	if s.useCookies || s.secure {
+		s.cookies, err = NewCookieIssuer(s.cookieLife)
+		if err != nil {
+			return fmt.Errorf("failed to create cookie secret: %w", err)
+		}
+	}
+
+	if err := s.access.Load(); err != nil {
+		return fmt.Errorf("failed to load access list: %w", err)
+	}
+
+	if s.playerFile != nil {
+		if err := s.playerFile.Open(); err != nil {
+			return fmt.Errorf("failed to load player store: %w", err)
+		}
+	}
+
+	for kind, schema := range s.schemas {
+		if err := schema.Validate(); err != nil {
+			return fmt.Errorf("invalid %s properties: %w", kind, err)
+		}
+	}
+
+	if s.levelPath != "" {
+		level, err := game.LoadLevel(s.levelPath)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			log.Printf("Level %s not found, movement only limited by world bounds", s.levelPath)
+		case err != nil:
+			return fmt.Errorf("failed to load level: %w", err)
+		default:
+			s.clientManager.SetLevel(level)
+			log.Printf("Loaded level %q: %d boxes, %d planes, %d heightmaps",
+				level.Name, len(level.Boxes), len(level.Planes), len(level.Heightmaps))
+		}
+	}
+
+	if err := s.restoreState(); err != nil {
+		return fmt.Errorf("failed to restore state: %w", err)
+	}
+
+	auditOut := log.Writer()
+	if s.auditPath != "" {
+		auditOut, err = os.OpenFile(s.auditPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return fmt.Errorf("failed to open movement audit log: %w", err)
+		}
+	}
+	s.audit = game.NewAuditLog(auditLogSize, auditOut)
+
 	s.conn, err = net.ListenUDP("udp", udpAddr)
 	if err != nil {
 		return fmt.Errorf("failed to listen on UDP: %w", err)
@@ -42,24 +229,142 @@ func (s *Server) Start() error {
 
 	log.Printf("UDP server listening on %s", s.address)
 
+	// Restored players were admitted before bans added since the state was saved
+	s.disconnectDisallowed()
+
+	// Start the admin interface for the access list
+	if s.adminAddress != "" {
+		s.admin = &http.Server{Addr: s.adminAddress, Handler: s.adminHandler()}
+		go func() {
+			log.Printf("Admin interface listening on %s", s.adminAddress)
+			if err := s.admin.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Printf("Admin interface failed: %v", err)
+			}
+		}()
+	}
+
 	// Start cleanup routine
 	go s.cleanupRoutine()
 
+	// Start matchmaking routine
+	go s.matchmakingRoutine()
+
+	// Start measuring round-trip times
+	if s.pingRate > 0 {
+		go s.pingRoutine()
+	}
+
+	// Start recording position history for lag compensation
+	if s.historyRate > 0 {
+		go s.historyRoutine()
+	}
+
+	// Start the shards handling each room's packets and simulation
+	s.shards.Start()
+
+	// Start simulating non-player entities on each shard
+	if s.entityRate > 0 {
+		s.shards.Every(s.entityRate, s.entityTick)
+	}
+
+	// Start resending unacknowledged reliable messages
+	if s.resendRate > 0 {
+		go s.reliableRoutine()
+	}
+
+	// Start dropping incomplete fragmented messages
+	go s.reassemblyRoutine()
+
+	// Start outbox flushing when messages are coalesced
+	if s.flushInterval > 0 {
+		go s.flushRoutine()
+	}
+
+	// Start sending snapshots on each shard when delta snapshots are enabled, otherwise
+	// correct the entities clients extrapolate
+	if s.snapInterval > 0 {
+		s.shards.Every(s.snapInterval, s.snapshotTick)
+	} else if s.entityRate > 0 && s.transformRate > 0 {
+		s.shards.Every(s.transformRate, s.transformTick)
+	}
+
 	// Start main server loop
 	return s.run()
 }
 
 // Stop stops the server
 func (s *Server) Stop() error {
+	if s.admin != nil {
+		if err := s.admin.Close(); err != nil {
+			log.Printf("Error closing admin interface: %v", err)
+		}
+	}
+
+	var err error
 	if s.conn != nil {
-		return s.conn.Close()
+		err = s.conn.Close()
+	}
+	s.shards.Stop()
+
+	s.savePlayers()
+	s.saveState()
+	return err
+}
+
+// saveState writes the runtime state for the next start to restore
+func (s *Server) saveState() {
+	if s.statePath == "" {
+		return
 	}
+
+	state, err := s.clientManager.State(time.Now())
+	if err != nil {
+		log.Printf("Failed to capture state: %v", err)
+		return
+	}
+	state.Chat = s.chat.State()
+
+	if err := state.Save(s.statePath); err != nil {
+		log.Printf("Failed to save state: %v", err)
+		return
+	}
+	log.Printf("Saved state of %d players, %d rooms and %d entities to %s",
+		len(state.Players), len(state.Rooms), len(state.Entities), s.statePath)
+}
+
+// restoreState puts back the state saved by the last shutdown. The file is removed once read,
+// so a later crash does not bring back players that have long left.
+func (s *Server) restoreState() error {
+	if s.statePath == "" {
+		return nil
+	}
+
+	state, err := LoadState(s.statePath)
+	if err != nil || state == nil {
+		return err
+	}
+	if err := os.Remove(s.statePath); err != nil {
+		return err
+	}
+
+	if age := time.Since(state.SavedAt); age > s.stateMaxAge {
+		log.Printf("Discarding state saved %s ago", age.Round(time.Second))
+		return nil
+	}
+
+	if err := s.clientManager.Restore(state); err != nil {
+		log.Printf("State restored partially: %v", err)
+	}
+	s.chat.Restore(state.Chat)
+
+	log.Printf("Restored %d players, %d rooms and %d entities saved %s ago",
+		len(state.Players), len(state.Rooms), len(state.Entities), time.Since(state.SavedAt).Round(time.Millisecond))
 	return nil
 }
 
 // run is the main server loop
 func (s *Server) run() error {
-	buffer := make([]byte, 1024)
+	buffer := make([]byte, s.bufferSize)
 
 	for {
 		n, clientAddr, err := s.conn.ReadFromUDP(buffer)
@@ -68,23 +373,92 @@ func (s *Server) run() error {
 			continue
 		}
 
-		// Handle the incoming data
-		go s.handlePacket(clientAddr, buffer[:n])
+		// Denied and banned senders are dropped before any other work is done
+		if !s.access.AllowedAddress(clientAddr.IP, time.Now()) {
+			continue
+		}
+
+		// Handle a copy of the incoming data, the buffer is reused by the next read
+		packet := make([]byte, n)
+		copy(packet, buffer[:n])
+		s.dispatch(clientAddr, packet)
+	}
+}
+
+// dispatch queues a packet on the shard owning the sender's room, so each room's packets are
+// handled in order while rooms on other shards proceed in parallel. Packets from senders without
+// a room, mostly handshakes, are handled on their own goroutine.
+func (s *Server) dispatch(clientAddr *net.UDPAddr, packet []byte) {
+	shard, exists := s.clientManager.ShardOf(clientAddr)
+
+	// Flooding senders are dropped here, before they can fill a shard's queue
+	if verdict := s.limiter.AllowPacket(clientAddr, exists, time.Now()); verdict != VerdictAllow {
+		s.applyRateLimitVerdict(clientAddr, verdict)
+		return
+	}
+
+	if !exists {
+		go s.handlePacket(noShard, clientAddr, packet)
+		return
+	}
+	s.shards.Post(shard, func() {
+		s.handlePacket(shard, clientAddr, packet)
+	})
+}
+
+// handlePacket handles a datagram on the given shard
+func (s *Server) handlePacket(shard int, clientAddr *net.UDPAddr, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+
+	// In secure mode only key exchanges and authenticated envelopes are accepted
+	if s.secure {
+		switch command.Command(data[0]) {
+		case command.KEY_EXCHANGE:
+			s.handleKeyExchange(clientAddr, data)
+			return
+		case command.SECURE:
+			plaintext, err := s.sessions.Open(clientAddr, data)
+			if err != nil {
+				log.Printf("Rejected secure packet from %s: %v", clientAddr, err)
+				return
+			}
+			data = plaintext
+		default:
+			return
+		}
+	}
+
+	messages, err := s.serializer.Unpack(data)
+	if err != nil {
+		log.Printf("Unpack error: %v", err)
+		return
+	}
 
-		// Tick rate limiting
-		time.Sleep(s.tickRate)
+	for _, msg := range messages {
+		s.handleMessage(shard, clientAddr, msg)
 	}
 }
 
-// handlePacket processes incoming packets
-func (s *Server) handlePacket(clientAddr *net.UDPAddr, data []byte) {
+// handleMessage processes a single message
+func (s *Server) handleMessage(shard int, clientAddr *net.UDPAddr, data []byte) {
 	if len(data) == 0 {
 		return
 	}
 
+	var userID uint8
+	if player, exists := s.clientManager.GetPlayerByAddress(clientAddr); exists {
+		userID = player.ID
+	}
+	if verdict := s.limiter.AllowCommand(clientAddr, userID, command.Command(data[0]), time.Now()); verdict != VerdictAllow {
+		s.applyRateLimitVerdict(clientAddr, verdict)
+		return
+	}
+
 	// Check for port request (special case)
 	if command.Command(data[0]) == command.PORT_REQUEST {
-		s.handlePortRequest(clientAddr)
+		s.handlePortRequest(clientAddr, data)
 		return
 	}
 
@@ -97,153 +471,1368 @@ func (s *Server) handlePacket(clientAddr *net.UDPAddr, data []byte) {
 
 	// Handle different message types
 	switch cmd {
+	case command.FRAGMENT:
+		s.handleFragment(shard, clientAddr, messageData.(message.Fragment))
 	case command.POSITION:
 		s.handlePosition(messageData.(message.PositionData))
 	case command.POSITION_RTT:
-		s.handlePositionRTT(clientAddr, messageData.(message.PositionDataRTT))
+		s.handlePositionRTT(shard, clientAddr, messageData.(message.PositionDataRTT))
 	case command.MOVE:
 		s.handleMovement(messageData.(message.MoveData))
 	case command.MOVE_RTT:
 		s.handleMovementRTT(clientAddr, messageData.(message.MoveDataRTT))
+	case command.ROOM_CREATE:
+		s.handleRoomCreate(clientAddr, messageData.(message.RoomCreate))
+	case command.ROOM_JOIN:
+		s.handleRoomJoin(clientAddr, messageData.(message.RoomJoin))
+	case command.ROOM_LEAVE:
+		s.handleRoomLeave(clientAddr, messageData.(message.RoomRequest))
+	case command.ROOM_LIST_REQUEST:
+		s.handleRoomListRequest(clientAddr, messageData.(message.RoomRequest))
+	case command.MATCH_REQUEST:
+		s.handleMatchRequest(clientAddr, messageData.(message.MatchRequest))
+	case command.MATCH_CANCEL:
+		s.handleMatchCancel(clientAddr, messageData.(message.MatchCancel))
+	case command.SNAPSHOT_ACK:
+		s.handleSnapshotAck(clientAddr, messageData.(message.SnapshotAck))
+	case command.INPUT:
+		s.handleInput(shard, clientAddr, messageData.(message.InputData))
+	case command.RELIABLE:
+		s.handleReliable(shard, clientAddr, messageData.(message.Reliable))
+	case command.RELIABLE_ACK:
+		s.handleReliableAck(clientAddr, messageData.(message.ReliableAck))
+	case command.PONG:
+		s.handlePong(clientAddr, messageData.(message.Ping))
+	case command.CHAT:
+		s.handleChat(clientAddr, messageData.(message.ChatMessage))
 	default:
 		log.Printf("Unhandled command: %v", cmd)
 	}
 }
 
-// handlePortRequest handles new client registration
-func (s *Server) handlePortRequest(clientAddr *net.UDPAddr) {
-	player, err := s.clientManager.RegisterClient(clientAddr)
-	if err != nil {
-		log.Printf("Failed to register client: %v", err)
+// applyRateLimitVerdict disconnects clients the rate limiter decided to kick and bans the IP when told to
+func (s *Server) applyRateLimitVerdict(clientAddr *net.UDPAddr, verdict RateLimitVerdict) {
+	switch verdict {
+	case VerdictBanned:
+		ban := Ban{
+			Kind:    BanAddress,
+			Key:     clientAddr.IP.String(),
+			Reason:  "rate limit",
+			Expires: time.Now().Add(s.banDuration),
+		}
+		// The ban applies at once; the packet path does not wait for it to be written
+		saved, err := s.access.AddBan(ban)
+		if err != nil {
+			log.Printf("Failed to record ban of %s: %v", clientAddr.IP, err)
+		} else {
+			go func() {
+				if err := <-saved; err != nil {
+					log.Printf("Failed to save ban of %s, lifted again: %v", clientAddr.IP, err)
+				}
+			}()
+		}
+		s.disconnectAddress(clientAddr.IP, "exceeding rate limits")
+	case VerdictKick:
+		if player, exists := s.clientManager.GetPlayerByAddress(clientAddr); exists {
+			s.disconnectPlayer(player.ID, "exceeding rate limits")
+		}
+	}
+}
+
+// disconnectPlayer removes a player and forgets its per-player state
+func (s *Server) disconnectPlayer(userID uint8, reason string) {
+	player, exists := s.clientManager.GetPlayer(userID)
+	if !exists {
 		return
 	}
 
-	// Send port assignment
-	portAssignment := message.PortAssignment{
-		CommandID: command.PORT_ASSIGNMENT,
+	s.sendInterestChanges(s.clientManager.RemovePlayer(player.ID))
+	s.forgetPlayer(player)
+
+	log.Printf("Disconnected UserID=%d (%s) for %s", player.ID, player.Address, reason)
+}
+
+// forgetPlayer saves the progress of a removed player, drops its per-player state and tells everyone they left
+func (s *Server) forgetPlayer(player *game.Player) {
+	// Saving rewrites the whole store, so it is left to the next savePlayers off the packet path
+	if player.Identity != "" {
+		s.departedMu.Lock()
+		s.departed[player.Identity] = s.clientManager.Record(player, time.Now())
+		s.departedMu.Unlock()
+	}
+	s.limiter.ForgetPlayer(player.ID)
+	s.chat.Forget(player.ID)
+
+	data, err := s.serializer.SerializePlayerEvent(message.PlayerEvent{
+		CommandID: command.PLAYER_LEFT,
 		UserID:    player.ID,
-		Port:      uint16(player.ListenPort),
+	})
+	if err != nil {
+		log.Printf("Failed to serialize player event: %v", err)
+		return
 	}
+	// The player is already removed, so they are not among the recipients
+	for _, other := range s.clientManager.Players() {
+		s.sendReliable(other, data)
+	}
+}
 
-	data, err := s.serializer.SerializePortAssignment(portAssignment)
+// handleKeyExchange sets up a secure session and replies with the server's public key. Like a
+// PORT_REQUEST, the request must first echo a connect cookie, so spoofed requests cost no
+// asymmetric crypto or session and are never answered with more bytes than they carry.
+func (s *Server) handleKeyExchange(clientAddr *net.UDPAddr, data []byte) {
+	if len(data) < message.KeyExchangeSize {
+		return
+	}
+
+	messageData, _, err := s.serializer.Deserialize(data)
 	if err != nil {
-		log.Printf("Failed to serialize port assignment: %v", err)
+		log.Printf("Deserialization error: %v", err)
 		return
 	}
+	request := messageData.(message.KeyExchange)
 
-	s.conn.WriteToUDP(data, clientAddr)
+	if !s.cookies.Verify(clientAddr, request.Cookie, time.Now()) {
+		s.sendPlaintextChallenge(clientAddr)
+		return
+	}
 
-	// Send user assignment
-	userAssignment := message.UserAssignment{
-		CommandID: command.USER_ASSIGNMENT,
-		UserID:    player.ID,
+	var proof []byte
+	if request.HasProof {
+		proof = request.Proof[:]
 	}
 
-	data, err = s.serializer.SerializeUserAssignment(userAssignment)
+	serverPub, signature, err := s.sessions.KeyExchange(clientAddr, request.PublicKey[:], proof)
 	if err != nil {
-		log.Printf("Failed to serialize user assignment: %v", err)
+		log.Printf("Key exchange with %s failed: %v", clientAddr, err)
 		return
 	}
 
-	s.conn.WriteToUDP(data, player.GetListenAddress())
+	reply := message.KeyExchange{CommandID: command.KEY_EXCHANGE}
+	copy(reply.PublicKey[:], serverPub)
+	copy(reply.Signature[:], signature)
 
-	log.Printf("Registered new client: UserID=%d, Port=%d", player.ID, player.ListenPort)
-}
+	data, err = s.serializer.SerializeKeyExchange(reply)
+	if err != nil {
+		log.Printf("Failed to serialize key exchange: %v", err)
+		return
+	}
 
-// handlePosition handles position updates
-func (s *Server) handlePosition(pos message.PositionData) {
-	fmt.Printf("Position update: UserID=%d, X=%.2f, Y=%.2f, Z=%.2f, RotY=%.2f\n",
-		pos.UserID, pos.X, pos.Y, pos.Z, pos.RotY)
+	// The reply must stay in plaintext, the client cannot derive the keys without it
+	if _, err := s.conn.WriteToUDP(data, clientAddr); err != nil {
+		log.Printf("Failed to write to %s: %v", clientAddr, err)
+	}
 }
 
-// handlePositionRTT handles position updates with RTT
-func (s *Server) handlePositionRTT(clientAddr *net.UDPAddr, pos message.PositionDataRTT) {
-	// Auto-register if client not found
-	player, exists := s.clientManager.GetPlayerByAddress(clientAddr)
-	if !exists {
-		s.handlePortRequest(clientAddr)
+// sendPlaintextChallenge answers a key exchange without a valid cookie. There is no session
+// yet to seal it in, so it bypasses send.
+func (s *Server) sendPlaintextChallenge(clientAddr *net.UDPAddr) {
+	data, err := s.serializer.SerializeConnectChallenge(message.ConnectChallenge{
+		CommandID: command.CONNECT_CHALLENGE,
+		Cookie:    s.cookies.Issue(clientAddr, time.Now()),
+	})
+	if err != nil {
+		log.Printf("Failed to serialize connect challenge: %v", err)
 		return
 	}
 
-	// Update player position
-	s.clientManager.UpdatePlayerPosition(pos.UserID, pos)
+	if _, err := s.conn.WriteToUDP(data, clientAddr); err != nil {
+		log.Printf("Failed to write to %s: %v", clientAddr, err)
+	}
+}
 
-	// Broadcast to other players
-	s.broadcastPosition(message.PositionData{
-		CommandID: command.POSITION,
-		UserID:    pos.UserID,
-		X:         pos.X,
-		Y:         pos.Y,
-		Z:         pos.Z,
-		RotY:      pos.RotY,
-	}, pos.UserID)
+// handleFragment collects fragments and handles the message once it is complete
+func (s *Server) handleFragment(shard int, clientAddr *net.UDPAddr, frag message.Fragment) {
+	// Registration needs no fragments, so senders that have not passed the cookie check get no memory
+	if _, exists := s.clientManager.GetPlayerByAddress(clientAddr); !exists {
+		return
+	}
 
-	// Send RTT response
-	s.sendRTTResponse(player.GetListenAddress(), pos.TimestampRTT)
+	data, complete, err := s.reassembler.Add(clientAddr.String(), frag, time.Now())
+	if err != nil {
+		log.Printf("Dropping fragmented message %d from %s: %v", frag.MessageID, clientAddr, err)
+		return
+	}
+	if !complete {
+		return
+	}
 
-	fmt.Printf("PositionRTT update: UserID=%d, X=%.2f, Y=%.2f, Z=%.2f, RotY=%.2f, RTT=%d\n",
-		pos.UserID, pos.X, pos.Y, pos.Z, pos.RotY, pos.TimestampRTT)
+	// A reassembled message holding more fragments could nest without bound
+	if len(data) > 0 && command.Command(data[0]) == command.FRAGMENT {
+		log.Printf("Dropping nested fragmented message %d from %s", frag.MessageID, clientAddr)
+		return
+	}
+	s.handleMessage(shard, clientAddr, data)
 }
 
-// handleMovement handles movement commands
-func (s *Server) handleMovement(mov message.MoveData) {
-	fmt.Printf("Movement: UserID=%d, Direction=%s, Speed=%.2f\n",
-		mov.UserID, mov.DirectionID, mov.Speed)
-}
+// handlePortRequest registers a client once it has echoed a valid connect cookie
+func (s *Server) handlePortRequest(clientAddr *net.UDPAddr, data []byte) {
+	// Requests smaller than a challenge get no reply, so the server can't be used to amplify traffic
+	if s.cookiesEnabled() && len(data) < portRequestSize {
+		return
+	}
 
-// handleMovementRTT handles movement commands with RTT
-func (s *Server) handleMovementRTT(_ *net.UDPAddr, mov message.MoveDataRTT) {
-	player, exists := s.clientManager.GetPlayer(mov.UserID)
-	if !exists {
+	messageData, _, err := s.serializer.Deserialize(data)
+	if err != nil {
+		log.Printf("Deserialization error: %v", err)
 		return
 	}
+	request := messageData.(message.PortRequest)
 
-	// Send RTT response
-	s.sendRTTResponse(player.GetListenAddress(), mov.TimestampRTT)
+	if s.cookiesEnabled() && !s.cookies.Verify(clientAddr, request.Cookie, time.Now()) {
+		s.sendConnectChallenge(clientAddr)
+		return
+	}
 
-	fmt.Printf("MovementRTT: UserID=%d, Direction=%s, Speed=%.2f, RTT=%d\n",
-		mov.UserID, mov.DirectionID, mov.Speed, mov.TimestampRTT)
+	profile := game.Profile{Name: request.Name, Color: request.Color, Skin: request.Skin}
+	s.registerClient(clientAddr, profile, Identity(request.Secret))
 }
 
-// broadcastPosition sends position updates to all other players
-func (s *Server) broadcastPosition(pos message.PositionData, excludeUserID uint8) {
-	data, err := s.serializer.SerializePositionData(pos)
+// sendConnectChallenge replies with a cookie bound to the client's address without keeping any state
+func (s *Server) sendConnectChallenge(clientAddr *net.UDPAddr) {
+	data, err := s.serializer.SerializeConnectChallenge(message.ConnectChallenge{
+		CommandID: command.CONNECT_CHALLENGE,
+		Cookie:    s.cookies.Issue(clientAddr, time.Now()),
+	})
 	if err != nil {
-		log.Printf("Failed to serialize position for broadcast: %v", err)
+		log.Printf("Failed to serialize connect challenge: %v", err)
 		return
 	}
 
-	players := s.clientManager.GetAllPlayers(excludeUserID)
-	for _, player := range players {
-		s.conn.WriteToUDP(data, player.GetListenAddress())
-	}
+	s.send(clientAddr, data)
 }
 
-// sendRTTResponse sends an RTT response back to the client
-func (s *Server) sendRTTResponse(addr *net.UDPAddr, timestamp uint32) {
-	response := message.DefaultRTT{
-		CommandID:    command.DEFAULT_RTT,
-		TimestampRTT: timestamp,
+// cookiesEnabled reports whether registrations require a connect cookie
+func (s *Server) cookiesEnabled() bool {
+	return s.useCookies && s.cookies != nil
+}
+
+// registerClient handles new client registration. Players with an identity get their saved
+// progress back; if they are still connected from another address, that session is replaced.
+func (s *Server) registerClient(clientAddr *net.UDPAddr, profile game.Profile, identity string) {
+	var saved *PlayerRecord
+	if identity != "" {
+		if s.access.IdentityBanned(identity, time.Now()) {
+			s.sendProfileRejected(clientAddr, message.PROFILE_BANNED)
+			return
+		}
+		if old, exists := s.clientManager.GetPlayerByIdentity(identity); exists && old.Address.String() != clientAddr.String() {
+			s.disconnectPlayer(old.ID, "reconnected from "+clientAddr.String())
+		}
+
+		record, found, err := s.loadPlayer(identity)
+		if err != nil {
+			log.Printf("Failed to load player %s: %v", identity, err)
+		}
+		if found {
+			saved = &record
+		}
 	}
 
-	data, err := s.serializer.SerializeDefaultRTT(response)
+	player, created, err := s.clientManager.RegisterClient(clientAddr, profile, identity, saved)
+	switch {
+	case errors.Is(err, game.ErrNameInvalid):
+		s.sendProfileRejected(clientAddr, message.PROFILE_NAME_INVALID)
+		return
+	case errors.Is(err, game.ErrNameTaken):
+		s.sendProfileRejected(clientAddr, message.PROFILE_NAME_TAKEN)
+		return
+	case err != nil:
+		log.Printf("Failed to register client: %v", err)
+		return
+	}
+
+	// Updates sent to the listen port use the same secure session as the handshake
+	if s.secure {
+		s.sessions.Bind(clientAddr, player.GetListenAddress())
+	}
+
+	// Send port assignment
+	portAssignment := message.PortAssignment{
+		CommandID: command.PORT_ASSIGNMENT,
+		UserID:    player.ID,
+		Port:      uint16(player.ListenPort),
+	}
+
+	data, err := s.serializer.SerializePortAssignment(portAssignment)
 	if err != nil {
-		log.Printf("Failed to serialize RTT response: %v", err)
+		log.Printf("Failed to serialize port assignment: %v", err)
 		return
 	}
 
-	s.conn.WriteToUDP(data, addr)
-}
+	s.send(clientAddr, data)
 
-// cleanupRoutine periodically removes inactive players
-func (s *Server) cleanupRoutine() {
-	ticker := time.NewTicker(30 * time.Second)
-	defer ticker.Stop()
+	// Send user assignment
+	userAssignment := message.UserAssignment{
+		CommandID: command.USER_ASSIGNMENT,
+		UserID:    player.ID,
+	}
 
-	for range ticker.C {
-		s.clientManager.CleanupInactivePlayers(60 * time.Second)
+	data, err = s.serializer.SerializeUserAssignment(userAssignment)
+	if err != nil {
+		log.Printf("Failed to serialize user assignment: %v", err)
+		return
+	}
 
-		playerCount, availablePorts := s.clientManager.GetStats()
-		log.Printf("Active players: %d, Available ports: %d", playerCount, availablePorts)
+	s.send(player.GetListenAddress(), data)
+
+	// Late joiners see what was said before they arrived and who else is here; retried requests must not replay it
+	if created {
+		if pos, exists := s.clientManager.PlayerPosition(player.ID); exists && saved != nil && saved.Position != nil {
+			s.sendPositionCorrection(player, pos)
+		}
+		s.sendJoinSnapshot(player)
+		s.announceJoin(player)
+		s.sendChatHistory(player, s.chat.GlobalHistory())
+		s.sendChatHistory(player, s.chat.RoomHistory(game.LobbyRoomID))
+	}
+
+	log.Printf("Registered new client: UserID=%d, Port=%d, Name=%q, Identity=%s", player.ID, player.ListenPort, player.Profile.Name, player.Identity)
+}
+
+// sendProfileRejected tells a client its registration failed because of its profile
+func (s *Server) sendProfileRejected(clientAddr *net.UDPAddr, status message.ProfileStatus) {
+	data, err := s.serializer.SerializeProfileRejected(message.ProfileRejected{
+		CommandID: command.PROFILE_REJECTED,
+		Status:    status,
+	})
+	if err != nil {
+		log.Printf("Failed to serialize profile rejection: %v", err)
+		return
+	}
+
+	s.send(clientAddr, data)
+}
+
+// sendJoinSnapshot sends a newly registered player the players and entities they can already see,
+// so idle players show up before they next move
+func (s *Server) sendJoinSnapshot(player *game.Player) {
+	snapshot, properties, err := s.clientManager.JoinSnapshot(player.ID)
+	if err != nil {
+		log.Printf("Failed to build join snapshot for UserID=%d: %v", player.ID, err)
+		return
+	}
+
+	data, err := s.serializer.SerializeJoinSnapshot(snapshot)
+	if err != nil {
+		log.Printf("Failed to serialize join snapshot: %v", err)
+		return
+	}
+	s.sendReliable(player, data)
+
+	if len(properties) > 0 {
+		s.sendProperties(player, properties)
+	}
+}
+
+// announceJoin tells everyone a new player joined and who they are, and introduces everyone else to the new player
+func (s *Server) announceJoin(player *game.Player) {
+	info, err := s.serializer.SerializePlayerInfo(playerInfo(player))
+	if err != nil {
+		log.Printf("Failed to serialize player info: %v", err)
+		return
+	}
+	joined, err := s.serializer.SerializePlayerEvent(message.PlayerEvent{
+		CommandID: command.PLAYER_JOINED,
+		UserID:    player.ID,
+	})
+	if err != nil {
+		log.Printf("Failed to serialize player event: %v", err)
+		return
+	}
+	s.sendReliable(player, info)
+
+	for _, other := range s.clientManager.Players() {
+		if other.ID == player.ID {
+			continue
+		}
+		s.sendReliable(other, joined)
+		s.sendReliable(other, info)
+
+		otherData, err := s.serializer.SerializePlayerInfo(playerInfo(other))
+		if err != nil {
+			log.Printf("Failed to serialize player info: %v", err)
+			continue
+		}
+		s.sendReliable(player, otherData)
+	}
+}
+
+// playerInfo builds the message presenting a player to clients
+func playerInfo(player *game.Player) message.PlayerInfo {
+	return message.PlayerInfo{
+		CommandID: command.PLAYER_INFO,
+		UserID:    player.ID,
+		Color:     player.Profile.Color,
+		Skin:      player.Profile.Skin,
+		Name:      player.Profile.Name,
+	}
+}
+
+// handlePosition handles position updates
+func (s *Server) handlePosition(pos message.PositionData) {
+	fmt.Printf("Position update: UserID=%d, X=%.2f, Y=%.2f, Z=%.2f, RotY=%.2f\n",
+		pos.UserID, pos.X, pos.Y, pos.Z, pos.RotY)
+}
+
+// handlePositionRTT handles position updates with RTT
+func (s *Server) handlePositionRTT(shard int, clientAddr *net.UDPAddr, pos message.PositionDataRTT) {
+	// Auto-register if client not found; with cookies the client must prove its address first
+	player, exists := s.clientManager.GetPlayerByAddress(clientAddr)
+	if !exists {
+		if s.cookiesEnabled() {
+			s.sendConnectChallenge(clientAddr)
+		} else {
+			s.registerClient(clientAddr, game.Profile{}, "")
+		}
+		return
+	}
+
+	// With authoritative movement only INPUT moves players
+	if s.authoritative {
+		s.sendRTTResponse(player.GetListenAddress(), pos.TimestampRTT)
+		return
+	}
+
+	// A client may only move its own player
+	pos.UserID = player.ID
+
+	pos, accepted := s.validateMovement(player, pos)
+	if !accepted {
+		s.sendRTTResponse(player.GetListenAddress(), pos.TimestampRTT)
+		return
+	}
+
+	// Update player position and notify players entering or leaving the area of interest
+	changes := s.clientManager.UpdatePlayerPosition(shard, pos.UserID, pos)
+	s.sendInterestChanges(changes)
+	s.replicatePosition(pos)
+
+	// Send RTT response
+	s.sendRTTResponse(player.GetListenAddress(), pos.TimestampRTT)
+}
+
+// handleInput runs a client's input frames and replies with its authoritative state.
+// Without authoritative movement, clients move by POSITION_RTT through the movement validator
+// and INPUT is ignored, so a client cannot mix both to move twice.
+func (s *Server) handleInput(shard int, clientAddr *net.UDPAddr, in message.InputData) {
+	if !s.authoritative {
+		return
+	}
+
+	player, ok := s.requestingPlayer(clientAddr, in.UserID)
+	if !ok {
+		return
+	}
+
+	pos, lastSeq, changes, exists := s.clientManager.ApplyInput(shard, player.ID, in.Frames, time.Now())
+	if !exists {
+		return
+	}
+	s.sendInterestChanges(changes)
+	s.replicatePosition(pos)
+
+	data, err := s.serializer.SerializeAuthState(message.AuthState{
+		CommandID:    command.AUTH_STATE,
+		UserID:       player.ID,
+		LastSequence: lastSeq,
+		X:            pos.X,
+		Y:            pos.Y,
+		Z:            pos.Z,
+		RotY:         pos.RotY,
+	})
+	if err != nil {
+		log.Printf("Failed to serialize auth state: %v", err)
+		return
+	}
+	s.send(player.GetListenAddress(), data)
+}
+
+// replicatePosition broadcasts an accepted position to players in the area of interest,
+// unless the snapshot routine replicates it
+func (s *Server) replicatePosition(pos message.PositionDataRTT) {
+	if s.snapInterval != 0 {
+		return
+	}
+
+	s.broadcastPosition(message.PositionData{
+		CommandID: command.POSITION,
+		UserID:    pos.UserID,
+		X:         pos.X,
+		Y:         pos.Y,
+		Z:         pos.Z,
+		RotY:      pos.RotY,
+	}, pos.UserID)
+}
+
+// validateMovement checks a position update and applies the configured response to violations.
+// It returns the position to apply and whether to apply one at all.
+func (s *Server) validateMovement(player *game.Player, pos message.PositionDataRTT) (message.PositionDataRTT, bool) {
+	result := s.movement.Validate(player, pos, time.Now())
+	if result.Violation == game.ViolationNone {
+		return result.Position, true
+	}
+
+	s.audit.Record(game.AuditEntry{
+		Time:      time.Now(),
+		UserID:    player.ID,
+		Violation: result.Violation,
+		Action:    result.Action,
+		Detail:    result.Detail,
+	})
+
+	switch result.Action {
+	case game.ActionCorrect:
+		s.sendPositionCorrection(player, result.Position)
+		return result.Position, true
+	case game.ActionFlag:
+		// Non-finite coordinates would corrupt the interest grid, so they are never applied
+		if result.Violation == game.ViolationInvalid {
+			return pos, false
+		}
+		pos.RotY = game.NormalizeAngle(pos.RotY)
+		return pos, true
+	case game.ActionKick:
+		s.disconnectPlayer(player.ID, fmt.Sprintf("movement violation (%s)", result.Violation))
+		return pos, false
+	default:
+		return pos, false
+	}
+}
+
+// sendPositionCorrection tells a client where the server put its player
+func (s *Server) sendPositionCorrection(player *game.Player, pos message.PositionDataRTT) {
+	data, err := s.serializer.SerializePositionData(message.PositionData{
+		CommandID: command.POSITION,
+		UserID:    player.ID,
+		X:         pos.X,
+		Y:         pos.Y,
+		Z:         pos.Z,
+		RotY:      pos.RotY,
+	})
+	if err != nil {
+		log.Printf("Failed to serialize position correction: %v", err)
+		return
+	}
+	s.send(player.GetListenAddress(), data)
+}
+
+// handleMovement handles movement commands
+func (s *Server) handleMovement(mov message.MoveData) {
+	fmt.Printf("Movement: UserID=%d, Direction=%s, Speed=%.2f\n",
+		mov.UserID, mov.DirectionID, mov.Speed)
+}
+
+// handleMovementRTT handles movement commands with RTT
+func (s *Server) handleMovementRTT(_ *net.UDPAddr, mov message.MoveDataRTT) {
+	player, exists := s.clientManager.GetPlayer(mov.UserID)
+	if !exists {
+		return
+	}
+
+	// Send RTT response
+	s.sendRTTResponse(player.GetListenAddress(), mov.TimestampRTT)
+
+	fmt.Printf("MovementRTT: UserID=%d, Direction=%s, Speed=%.2f, RTT=%d\n",
+		mov.UserID, mov.DirectionID, mov.Speed, mov.TimestampRTT)
+}
+
+// requestingPlayer returns the registered player behind a request, rejecting spoofed user IDs
+func (s *Server) requestingPlayer(clientAddr *net.UDPAddr, userID uint8) (*game.Player, bool) {
+	player, exists := s.clientManager.GetPlayerByAddress(clientAddr)
+	if !exists || player.ID != userID {
+		return nil, false
+	}
+	return player, true
+}
+
+// handleRoomCreate opens a new room and moves the requesting player into it
+func (s *Server) handleRoomCreate(clientAddr *net.UDPAddr, rc message.RoomCreate) {
+	player, exists := s.requestingPlayer(clientAddr, rc.UserID)
+	if !exists {
+		return
+	}
+
+	room, changes, err := s.clientManager.CreateRoom(player.ID, rc.Name, int(rc.Capacity))
+	if err != nil {
+		log.Printf("Failed to create room for UserID=%d: %v", player.ID, err)
+		s.sendRoomAssignment(player, game.LobbyRoomID, message.ROOM_ERROR)
+		return
+	}
+
+	s.chat.ClearRoom(room.ID)
+	s.sendInterestChanges(changes)
+	s.sendRoomAssignment(player, room.ID, message.ROOM_OK)
+
+	log.Printf("UserID=%d created room %d (%q, capacity %d)", player.ID, room.ID, room.Name, room.Capacity)
+}
+
+// handleRoomJoin moves the requesting player into an existing room
+func (s *Server) handleRoomJoin(clientAddr *net.UDPAddr, rj message.RoomJoin) {
+	player, exists := s.requestingPlayer(clientAddr, rj.UserID)
+	if !exists {
+		return
+	}
+
+	changes, err := s.clientManager.JoinRoom(player.ID, rj.RoomID)
+	switch {
+	case errors.Is(err, game.ErrRoomNotFound):
+		s.sendRoomAssignment(player, rj.RoomID, message.ROOM_NOT_FOUND)
+		return
+	case errors.Is(err, game.ErrRoomFull):
+		s.sendRoomAssignment(player, rj.RoomID, message.ROOM_FULL)
+		return
+	case err != nil:
+		log.Printf("Failed to join room %d for UserID=%d: %v", rj.RoomID, player.ID, err)
+		s.sendRoomAssignment(player, rj.RoomID, message.ROOM_ERROR)
+		return
+	}
+
+	s.sendInterestChanges(changes)
+	s.sendRoomAssignment(player, rj.RoomID, message.ROOM_OK)
+	s.sendChatHistory(player, s.chat.RoomHistory(rj.RoomID))
+
+	log.Printf("UserID=%d joined room %d", player.ID, rj.RoomID)
+}
+
+// handleRoomLeave moves the requesting player back to the lobby
+func (s *Server) handleRoomLeave(clientAddr *net.UDPAddr, rr message.RoomRequest) {
+	player, exists := s.requestingPlayer(clientAddr, rr.UserID)
+	if !exists {
+		return
+	}
+
+	changes, err := s.clientManager.JoinRoom(player.ID, game.LobbyRoomID)
+	if err != nil {
+		log.Printf("Failed to return UserID=%d to the lobby: %v", player.ID, err)
+		return
+	}
+
+	s.sendInterestChanges(changes)
+	s.sendRoomAssignment(player, game.LobbyRoomID, message.ROOM_OK)
+	s.sendChatHistory(player, s.chat.RoomHistory(game.LobbyRoomID))
+}
+
+// handleRoomListRequest sends the list of open rooms to the requesting player
+func (s *Server) handleRoomListRequest(clientAddr *net.UDPAddr, rr message.RoomRequest) {
+	player, exists := s.requestingPlayer(clientAddr, rr.UserID)
+	if !exists {
+		return
+	}
+
+	roomList := message.RoomList{CommandID: command.ROOM_LIST}
+	for _, info := range s.clientManager.ListRooms() {
+		roomList.Rooms = append(roomList.Rooms, message.RoomEntry{
+			RoomID:      info.ID,
+			PlayerCount: uint8(info.PlayerCount),
+			Capacity:    uint8(info.Capacity),
+			Name:        info.Name,
+		})
+	}
+
+	data, err := s.serializer.SerializeRoomList(roomList)
+	if err != nil {
+		log.Printf("Failed to serialize room list: %v", err)
+		return
+	}
+
+	s.send(player.GetListenAddress(), data)
+}
+
+// sendRoomAssignment tells a player the outcome of a room request
+func (s *Server) sendRoomAssignment(player *game.Player, roomID uint8, status message.RoomStatus) {
+	data, err := s.serializer.SerializeRoomAssignment(message.RoomAssignment{
+		CommandID: command.ROOM_ASSIGNMENT,
+		UserID:    player.ID,
+		RoomID:    roomID,
+		Status:    status,
+	})
+	if err != nil {
+		log.Printf("Failed to serialize room assignment: %v", err)
+		return
+	}
+
+	s.send(player.GetListenAddress(), data)
+}
+
+// handleMatchRequest puts the requesting player into the matchmaking queue
+func (s *Server) handleMatchRequest(clientAddr *net.UDPAddr, mr message.MatchRequest) {
+	player, exists := s.requestingPlayer(clientAddr, mr.UserID)
+	if !exists {
+		return
+	}
+
+	// The client's own RTTMillis is ignored, as a client could lie its way into a better match
+	rtt := s.clientManager.PlayerRTT(player.ID)
+
+	s.matchmaker.Enqueue(game.MatchTicket{
+		UserID:     player.ID,
+		RTT:        rtt,
+		Skill:      int(mr.Skill),
+		HasSkill:   mr.HasSkill,
+		EnqueuedAt: time.Now(),
+	})
+
+	log.Printf("UserID=%d queued for matchmaking (RTT=%v, Skill=%d)", player.ID, rtt, mr.Skill)
+}
+
+// handleMatchCancel removes the requesting player from the matchmaking queue
+func (s *Server) handleMatchCancel(clientAddr *net.UDPAddr, mc message.MatchCancel) {
+	player, exists := s.requestingPlayer(clientAddr, mc.UserID)
+	if !exists {
+		return
+	}
+
+	if s.matchmaker.Cancel(player.ID) {
+		log.Printf("UserID=%d left the matchmaking queue", player.ID)
+	}
+}
+
+// startMatch opens a room for a match and tells every player where to go
+func (s *Server) startMatch(match game.Match) {
+	room, changes, err := s.clientManager.CreateMatchRoom("Match", match.Players)
+	if err != nil {
+		log.Printf("Failed to create match room: %v", err)
+		return
+	}
+
+	s.chat.ClearRoom(room.ID)
+	s.sendInterestChanges(changes)
+
+	for _, userID := range match.Players {
+		player, exists := s.clientManager.GetPlayer(userID)
+		if !exists {
+			continue
+		}
+
+		data, err := s.serializer.SerializeMatchAssignment(message.MatchAssignment{
+			CommandID:   command.MATCH_ASSIGNMENT,
+			UserID:      player.ID,
+			RoomID:      room.ID,
+			PlayerCount: uint8(len(match.Players)),
+		})
+		if err != nil {
+			log.Printf("Failed to serialize match assignment: %v", err)
+			continue
+		}
+		s.send(player.GetListenAddress(), data)
+	}
+
+	log.Printf("Started match in room %d with players %v", room.ID, match.Players)
+}
+
+// handleSnapshotAck records the latest snapshot a client received
+func (s *Server) handleSnapshotAck(clientAddr *net.UDPAddr, ack message.SnapshotAck) {
+	player, exists := s.requestingPlayer(clientAddr, ack.UserID)
+	if !exists {
+		return
+	}
+	player.Snapshots.Ack(ack.Sequence)
+}
+
+// sendSnapshot sends a player the delta between their acknowledged baseline and the current world
+func (s *Server) sendSnapshot(player *game.Player) {
+	current := s.clientManager.GetVisibleStates(player.ID)
+
+	var baselineSeq uint32
+	var baselineStates map[game.EntityID]game.EntityState
+	if baseline, ok := player.Snapshots.Baseline(); ok {
+		baselineSeq = baseline.Sequence
+		baselineStates = baseline.States
+	}
+
+	entries := game.DiffSnapshot(baselineStates, current)
+	if len(entries) == 0 {
+		return
+	}
+
+	// Over budget, the most pressing entries go now and the rest stay pending against the baseline
+	sent := current
+	if player.Priorities != nil {
+		entries = player.Priorities.Select(entries, current, player.Position.X, player.Position.Z, s.snapBudget)
+		sent = game.ApplySnapshot(baselineStates, current, entries)
+	}
+
+	snapshot := message.SnapshotData{
+		CommandID: command.SNAPSHOT,
+		Sequence:  player.Snapshots.Record(sent),
+		Baseline:  baselineSeq,
+		Entries:   entries,
+	}
+
+	data, err := s.serializer.SerializeSnapshotData(snapshot)
+	if err != nil {
+		log.Printf("Failed to serialize snapshot: %v", err)
+		return
+	}
+
+	s.send(player.GetListenAddress(), data)
+}
+
+// broadcastPosition sends position updates to the players that have the sender in their area of interest
+func (s *Server) broadcastPosition(pos message.PositionData, senderID uint8) {
+	data, err := s.serializePosition(pos)
+	if err != nil {
+		log.Printf("Failed to serialize position for broadcast: %v", err)
+		return
+	}
+
+	players := s.clientManager.GetVisiblePlayers(senderID)
+	for _, player := range players {
+		s.send(player.GetListenAddress(), data)
+	}
+}
+
+// serializePosition encodes a position update, using the compact encoding when it is enabled
+func (s *Server) serializePosition(pos message.PositionData) ([]byte, error) {
+	if s.serializer.CompactPositionsEnabled() {
+		return s.serializer.SerializeCompactPosition(pos)
+	}
+	return s.serializer.SerializePositionData(pos)
+}
+
+// sendInterestChanges notifies observers about players entering or leaving their area of interest
+func (s *Server) sendInterestChanges(changes []game.InterestChange) {
+	for _, change := range changes {
+		observer, exists := s.clientManager.GetPlayer(change.Observer)
+		if !exists {
+			continue
+		}
+
+		event := message.InterestEvent{
+			CommandID: command.INTEREST_LEAVE,
+			UserID:    change.Subject,
+		}
+		if change.Entered {
+			event.CommandID = command.INTEREST_ENTER
+		}
+
+		data, err := s.serializer.SerializeInterestEvent(event)
+		if err != nil {
+			log.Printf("Failed to serialize interest event: %v", err)
+			continue
+		}
+		s.send(observer.GetListenAddress(), data)
+
+		// Entering players are sent their current position right away
+		if !change.Entered {
+			continue
+		}
+		// The subject may be moved by another shard, after a room change
+		pos, exists := s.clientManager.PlayerPosition(change.Subject)
+		if !exists {
+			continue
+		}
+		data, err = s.serializePosition(message.PositionData{
+			CommandID: command.POSITION,
+			UserID:    change.Subject,
+			X:         pos.X,
+			Y:         pos.Y,
+			Z:         pos.Z,
+			RotY:      pos.RotY,
+		})
+		if err != nil {
+			log.Printf("Failed to serialize position for interest event: %v", err)
+			continue
+		}
+		s.send(observer.GetListenAddress(), data)
+	}
+}
+
+// sendEntityChanges sends spawns and despawns for entities entering or leaving players' views
+func (s *Server) sendEntityChanges(changes []game.EntityChange) {
+	for _, change := range changes {
+		observer, exists := s.clientManager.GetPlayer(change.Observer)
+		if !exists {
+			continue
+		}
+
+		var data []byte
+		var err error
+		if change.Spawned {
+			data, err = s.serializer.SerializeEntitySpawn(entitySpawn(change.Entity))
+		} else {
+			data, err = s.serializer.SerializeEntityDespawn(message.EntityDespawn{
+				CommandID: command.ENTITY_DESPAWN,
+				EntityID:  uint16(change.Entity.ID),
+			})
+		}
+		if err != nil {
+			log.Printf("Failed to serialize entity change: %v", err)
+			continue
+		}
+		// Nothing else repairs a lost spawn or despawn while snapshots are off
+		s.sendReliable(observer, data)
+		if !change.Spawned {
+			observer.Properties.Forget(uint16(change.Entity.ID))
+		}
+
+		// Clients that just started seeing an entity need all of its properties, after the spawn
+		if change.Spawned && change.Entity.Properties != nil {
+			s.sendProperties(observer, []message.EntityProperties{{
+				EntityID: uint16(change.Entity.ID),
+				Values:   change.Entity.Properties.Values(),
+			}})
+		}
+	}
+}
+
+// sendProperties sends a player property values of the entities they know about. Changed
+// properties are only collected once, so they go over the reliable channel: a lost update would
+// otherwise leave the value stale until it changes again.
+func (s *Server) sendProperties(player *game.Player, entities []message.EntityProperties) {
+	player.Properties.Add(entities)
+	s.flushProperties(player)
+}
+
+// flushProperties sends a player's queued property updates in one reliable message. While half of
+// the reliable channel is waiting for acknowledgements the updates stay queued, where newer values
+// replace older ones, so a lossy client can't fill the channel with property updates and leave no
+// room for chat, spawns and leaves.
+func (s *Server) flushProperties(player *game.Player) {
+	if player.Reliable.Pending() >= s.propPending {
+		return
+	}
+	entities := player.Properties.Take()
+	if len(entities) == 0 {
+		return
+	}
+
+	data, err := s.serializer.SerializePropertyUpdate(message.PropertyUpdate{
+		CommandID: command.ENTITY_PROPERTIES,
+		Entities:  entities,
+	})
+	if err != nil {
+		log.Printf("Failed to serialize property update: %v", err)
+		return
+	}
+	s.sendReliable(player, data)
+}
+
+// entitySpawn builds the spawn message introducing an entity to a client
+func entitySpawn(entity game.Entity) message.EntitySpawn {
+	spawn := message.EntitySpawn{
+		CommandID: command.ENTITY_SPAWN,
+		EntityID:  uint16(entity.ID),
+		Kind:      uint8(entity.Kind),
+		OwnerID:   entity.Owner,
+		X:         entity.Transform.Position.X,
+		Y:         entity.Transform.Position.Y,
+		Z:         entity.Transform.Position.Z,
+		RotY:      entity.Transform.RotY,
+	}
+	if entity.Motion != nil {
+		spawn.VX, spawn.VY, spawn.VZ = entity.Motion.Velocity.X, entity.Motion.Velocity.Y, entity.Motion.Velocity.Z
+	}
+	return spawn
+}
+
+// handleReliable acknowledges a reliable message and handles the messages it made deliverable, in order
+func (s *Server) handleReliable(shard int, clientAddr *net.UDPAddr, reliable message.Reliable) {
+	player, exists := s.clientManager.GetPlayerByAddress(clientAddr)
+	if !exists {
+		return
+	}
+
+	payloads, ack := player.Reliable.Receive(reliable.Sequence, reliable.Payload)
+	if ack {
+		data, err := s.serializer.SerializeReliableAck(message.ReliableAck{
+			CommandID: command.RELIABLE_ACK,
+			Sequence:  reliable.Sequence,
+		})
+		if err != nil {
+			log.Printf("Failed to serialize reliable ack: %v", err)
+			return
+		}
+		s.send(player.GetListenAddress(), data)
+	}
+
+	for _, payload := range payloads {
+		// Reliable messages cannot be nested
+		switch command.Command(payload[0]) {
+		case command.RELIABLE, command.RELIABLE_ACK:
+			continue
+		}
+		s.handleMessage(shard, clientAddr, payload)
+	}
+}
+
+// handleReliableAck stops resending a message the client received
+func (s *Server) handleReliableAck(clientAddr *net.UDPAddr, ack message.ReliableAck) {
+	if player, exists := s.clientManager.GetPlayerByAddress(clientAddr); exists {
+		player.Reliable.Ack(ack.Sequence)
+		s.flushProperties(player)
+	}
+}
+
+// handlePong times the echo of a ping
+func (s *Server) handlePong(clientAddr *net.UDPAddr, pong message.Ping) {
+	if player, exists := s.clientManager.GetPlayerByAddress(clientAddr); exists {
+		s.clientManager.Pong(player.ID, pong.Sequence, time.Now())
+	}
+}
+
+// sendReliable sends a message that is resent until the player acknowledges it. A player too far
+// behind to take it is disconnected, as they would otherwise silently miss it.
+func (s *Server) sendReliable(player *game.Player, data []byte) {
+	reliable, err := player.Reliable.Send(data, time.Now())
+	if err != nil {
+		s.disconnectPlayer(player.ID, err.Error())
+		return
+	}
+
+	data, err = s.serializer.SerializeReliable(reliable)
+	if err != nil {
+		log.Printf("Failed to serialize reliable message: %v", err)
+		return
+	}
+	s.send(player.GetListenAddress(), data)
+}
+
+// handleChat relays a chat message to the players of its channel
+func (s *Server) handleChat(clientAddr *net.UDPAddr, chat message.ChatMessage) {
+	sender, exists := s.requestingPlayer(clientAddr, chat.UserID)
+	if !exists {
+		return
+	}
+
+	now := time.Now()
+	text, err := s.chat.Accept(sender.ID, chat.Text, now)
+	if err != nil {
+		s.sendSystemMessage(sender, "Message not sent: "+err.Error())
+		return
+	}
+
+	relay := message.ChatMessage{
+		CommandID: command.CHAT,
+		UserID:    sender.ID,
+		Channel:   chat.Channel,
+		Timestamp: uint32(now.Unix()),
+		Text:      text,
+	}
+
+	var recipients []*game.Player
+	switch chat.Channel {
+	case message.CHAT_GLOBAL:
+		recipients = s.clientManager.Players()
+		s.chat.Record(relay, 0)
+	case message.CHAT_ROOM:
+		roomID, inRoom := s.clientManager.GetRoomID(sender.ID)
+		if !inRoom {
+			return
+		}
+		recipients = append(s.clientManager.GetRoomPlayers(sender.ID), sender)
+		s.chat.Record(relay, roomID)
+	case message.CHAT_WHISPER:
+		target, online := s.clientManager.GetPlayer(chat.Target)
+		if !online {
+			s.sendSystemMessage(sender, fmt.Sprintf("Message not sent: player %d is not online", chat.Target))
+			return
+		}
+		relay.Target = target.ID
+		recipients = []*game.Player{target}
+		if target.ID != sender.ID {
+			recipients = append(recipients, sender)
+		}
+	default:
+		return
+	}
+
+	data, err := s.serializer.SerializeChatMessage(relay)
+	if err != nil {
+		log.Printf("Failed to serialize chat message: %v", err)
+		return
+	}
+	for _, player := range recipients {
+		s.sendReliable(player, data)
+	}
+}
+
+// sendSystemMessage sends a player a server notice on the system channel
+func (s *Server) sendSystemMessage(player *game.Player, text string) {
+	data, err := s.serializer.SerializeChatMessage(message.ChatMessage{
+		CommandID: command.CHAT,
+		Channel:   message.CHAT_SYSTEM,
+		Timestamp: uint32(time.Now().Unix()),
+		Text:      text,
+	})
+	if err != nil {
+		log.Printf("Failed to serialize system message: %v", err)
+		return
+	}
+	s.sendReliable(player, data)
+}
+
+// sendChatHistory replays earlier chat messages to a player, oldest first
+func (s *Server) sendChatHistory(player *game.Player, history []message.ChatMessage) {
+	for _, chat := range history {
+		data, err := s.serializer.SerializeChatMessage(chat)
+		if err != nil {
+			log.Printf("Failed to serialize chat history: %v", err)
+			return
+		}
+		s.sendReliable(player, data)
+	}
+}
+
+// sendRTTResponse sends an RTT response back to the client
+func (s *Server) sendRTTResponse(addr *net.UDPAddr, timestamp uint32) {
+	response := message.DefaultRTT{
+		CommandID:    command.DEFAULT_RTT,
+		TimestampRTT: timestamp,
+	}
+
+	data, err := s.serializer.SerializeDefaultRTT(response)
+	if err != nil {
+		log.Printf("Failed to serialize RTT response: %v", err)
+		return
+	}
+
+	s.send(addr, data)
+}
+
+// snapshotTick sends every player of a shard a delta snapshot of their area of interest
+func (s *Server) snapshotTick(shard int, _ time.Time) {
+	for _, player := range s.clientManager.ShardPlayers(shard) {
+		s.sendSnapshot(player)
+	}
+}
+
+// entityTick simulates the non-player entities of a shard and keeps its players' view of them up to date
+func (s *Server) entityTick(shard int, now time.Time) {
+	last := s.entityLast[shard]
+	if last.IsZero() {
+		last = now.Add(-s.entityRate)
+	}
+	s.entityLast[shard] = now
+
+	s.sendEntityChanges(s.clientManager.TickEntities(shard, now, now.Sub(last)))
+
+	for userID, entities := range s.clientManager.CollectPropertyUpdates(shard, now, s.propBudget) {
+		if player, exists := s.clientManager.GetPlayer(userID); exists {
+			s.sendProperties(player, entities)
+		}
+	}
+}
+
+// transformTick sends the players of a shard the transforms of the moving entities they know
+func (s *Server) transformTick(shard int, _ time.Time) {
+	for userID, transforms := range s.clientManager.EntityTransforms(shard) {
+		player, exists := s.clientManager.GetPlayer(userID)
+		if !exists {
+			continue
+		}
+
+		data, err := s.serializer.SerializeEntityTransforms(message.EntityTransforms{
+			CommandID: command.ENTITY_TRANSFORM,
+			Entities:  transforms,
+		})
+		if err != nil {
+			log.Printf("Failed to serialize entity transforms: %v", err)
+			continue
+		}
+		s.send(player.GetListenAddress(), data)
+	}
+}
+
+// reliableRoutine periodically resends the reliable messages players have not acknowledged
+func (s *Server) reliableRoutine() {
+	ticker := time.NewTicker(s.resendRate)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		s.resendReliable(now, s.resendRate)
+	}
+}
+
+// resendReliable resends every reliable message that went unacknowledged for interval, and the
+// property updates that were waiting for room in the channel
+func (s *Server) resendReliable(now time.Time, interval time.Duration) {
+	for _, player := range s.clientManager.Players() {
+		s.flushProperties(player)
+		for _, reliable := range player.Reliable.Due(now, interval) {
+			data, err := s.serializer.SerializeReliable(reliable)
+			if err != nil {
+				log.Printf("Failed to serialize reliable message: %v", err)
+				continue
+			}
+			s.send(player.GetListenAddress(), data)
+		}
+	}
+}
+
+// pingRoutine periodically pings every player to measure its round-trip time
+func (s *Server) pingRoutine() {
+	ticker := time.NewTicker(s.pingRate)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		for _, player := range s.clientManager.Players() {
+			sequence, exists := s.clientManager.Ping(player.ID, now)
+			if !exists {
+				continue
+			}
+
+			data, err := s.serializer.SerializePing(message.Ping{CommandID: command.PING, Sequence: sequence})
+			if err != nil {
+				log.Printf("Failed to serialize ping: %v", err)
+				continue
+			}
+			s.send(player.GetListenAddress(), data)
+		}
+	}
+}
+
+// historyRoutine records every player's position each tick for lag compensation
+func (s *Server) historyRoutine() {
+	ticker := time.NewTicker(s.historyRate)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		s.clientManager.RecordHistory(now)
+	}
+}
+
+// matchmakingRoutine periodically groups queued players into matches
+func (s *Server) matchmakingRoutine() {
+	ticker := time.NewTicker(s.matchInterval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		// Players that timed out no longer need a match
+		s.matchmaker.Prune(func(userID uint8) bool {
+			_, exists := s.clientManager.GetPlayer(userID)
+			return exists
+		})
+
+		for _, match := range s.matchmaker.Process(now) {
+			s.startMatch(match)
+		}
+	}
+}
+
+// send queues a message for the given address, or writes it immediately when coalescing is off.
+// Messages larger than the MTU are split into fragments first.
+func (s *Server) send(addr *net.UDPAddr, data []byte) {
+	if len(data) > s.mtu {
+		fragments, err := s.serializer.Fragment(data, uint16(s.nextFragID.Add(1)), s.mtu)
+		if err != nil {
+			log.Printf("Failed to fragment message for %s: %v", addr, err)
+			return
+		}
+		for _, fragment := range fragments {
+			s.send(addr, fragment)
+		}
+		return
+	}
+
+	if s.flushInterval > 0 {
+		s.outbox.Queue(addr, data)
+		return
+	}
+	s.writeDatagram(data, addr)
+}
+
+// writeDatagram writes a finished datagram to the socket, sealing it in secure mode
+func (s *Server) writeDatagram(data []byte, addr *net.UDPAddr) {
+	if s.secure {
+		session, exists := s.sessions.Get(addr)
+		if !exists {
+			log.Printf("Dropping datagram to %s: %v", addr, ErrNoSession)
+			return
+		}
+		data = session.Seal(data)
+	}
+
+	if _, err := s.conn.WriteToUDP(data, addr); err != nil {
+		log.Printf("Failed to write to %s: %v", addr, err)
+	}
+}
+
+// flushRoutine periodically sends the coalesced outgoing messages
+func (s *Server) flushRoutine() {
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.outbox.Flush()
+	}
+}
+
+// reassemblyRoutine periodically drops fragmented messages that never completed
+func (s *Server) reassemblyRoutine() {
+	ticker := time.NewTicker(s.fragTimeout)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		s.reassembler.Expire(now)
+	}
+}
+
+// cleanupRoutine periodically removes inactive players
+func (s *Server) cleanupRoutine() {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		changes, removed := s.clientManager.CleanupInactivePlayers(60 * time.Second)
+		s.sendInterestChanges(changes)
+		for _, player := range removed {
+			s.forgetPlayer(player)
+		}
+		s.sessions.CleanupIdle(60 * time.Second)
+		s.savePlayers()
+
+		s.limiter.Cleanup(60*time.Second, time.Now())
+		if err := s.access.PruneExpired(time.Now()); err != nil {
+			log.Printf("Failed to prune expired bans: %v", err)
+		}
+
+		playerCount, availablePorts := s.clientManager.GetStats()
+		log.Printf("Active players: %d, Available ports: %d", playerCount, availablePorts)
+
+		limits := s.limiter.Stats()
+		log.Printf("Rate limiting: allowed %d, dropped %d, throttled %d, kicked %d, banned %d",
+			limits.Allowed, limits.Dropped, limits.Throttled, limits.Kicks, limits.Bans)
+	}
+}
+
+// savePlayers saves the progress of every connected player and of those that left since the last
+// save, so a crash loses at most one cleanup interval
+func (s *Server) savePlayers() {
+	s.departedMu.Lock()
+	departed := s.departed
+	s.departed = make(map[string]PlayerRecord)
+	s.departedMu.Unlock()
+
+	// Connected players come last, so a player who left and came back is saved as it is now
+	records := make([]PlayerRecord, 0, len(departed))
+	for _, record := range departed {
+		records = append(records, record)
+	}
+	records = append(records, s.clientManager.Records(time.Now())...)
+
+	if err := s.players.Save(records...); err != nil {
+		log.Printf("Failed to save players: %v", err)
+
+		// Keep the departed players for the next attempt unless they left again since
+		s.departedMu.Lock()
+		for identity, record := range departed {
+			if _, newer := s.departed[identity]; !newer {
+				s.departed[identity] = record
+			}
+		}
+		s.departedMu.Unlock()
+	}
+}
+
+// loadPlayer returns the saved progress of an identity, including players that left since the last save
+func (s *Server) loadPlayer(identity string) (PlayerRecord, bool, error) {
+	s.departedMu.Lock()
+	record, departed := s.departed[identity]
+	s.departedMu.Unlock()
+	if departed {
+		return record, true, nil
 	}
+	return s.players.Load(identity)
 }
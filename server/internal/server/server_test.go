@@ -0,0 +1,219 @@
+package server
+
+import (
+	"math"
+	"net"
+	"runtime"
+	"server/internal/command"
+	"server/internal/game"
+	"server/internal/message"
+	"testing"
+	"time"
+)
+
+// moveInBackground keeps moving every player on its shard, in and out of the other players' areas
+// of interest, until the benchmark ends. Run with -race, it checks that the lock-free broadcast
+// paths never read what the shards write.
+func moveInBackground(b *testing.B, s *Server, players []*game.Player) {
+	b.Helper()
+
+	reach := 2 * DefaultConfig().InterestRadius
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			player := players[i%len(players)]
+			angle := float64(i) * 0.37
+			pos := message.PositionDataRTT{
+				CommandID: command.POSITION_RTT,
+				UserID:    player.ID,
+				X:         reach * float32(math.Cos(angle)),
+				Z:         reach * float32(math.Sin(angle)),
+			}
+			shard, exists := s.clientManager.ShardOf(player.Address)
+			if !exists {
+				continue
+			}
+			if !s.shards.Post(shard, func() {
+				s.sendInterestChanges(s.clientManager.UpdatePlayerPosition(shard, player.ID, pos))
+			}) {
+				runtime.Gosched()
+			}
+		}
+	}()
+	b.Cleanup(func() {
+		close(done)
+		<-stopped
+	})
+}
+
+// BenchmarkBroadcastPosition sends position updates to the players that see the sender
+func BenchmarkBroadcastPosition(b *testing.B) {
+	s, players := newBenchServer(b, runtime.GOMAXPROCS(0))
+	moveInBackground(b, s, players)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			player := players[i%len(players)]
+			s.broadcastPosition(message.PositionData{CommandID: command.POSITION, UserID: player.ID}, player.ID)
+		}
+	})
+}
+
+// BenchmarkBroadcastInterestChanges tells players about room mates entering their area of
+// interest, which sends the position of a player another shard may be moving
+func BenchmarkBroadcastInterestChanges(b *testing.B) {
+	s, players := newBenchServer(b, runtime.GOMAXPROCS(0))
+	moveInBackground(b, s, players)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			observer, subject := players[i%len(players)], players[(i^1)%len(players)]
+			s.sendInterestChanges([]game.InterestChange{{Observer: observer.ID, Subject: subject.ID, Entered: true}})
+		}
+	})
+}
+
+// BenchmarkBroadcastReliable resends unacknowledged reliable messages to every player
+func BenchmarkBroadcastReliable(b *testing.B) {
+	s, players := newBenchServer(b, runtime.GOMAXPROCS(0))
+	for _, player := range players {
+		s.sendReliable(player, []byte{byte(command.CHAT)})
+	}
+	moveInBackground(b, s, players)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			s.resendReliable(time.Now(), 0)
+		}
+	})
+}
+
+// BenchmarkBroadcastPlayerLeft captures the record of a leaving player, as is done before
+// everyone is told they left
+func BenchmarkBroadcastPlayerLeft(b *testing.B) {
+	s, players := newBenchServer(b, runtime.GOMAXPROCS(0))
+	moveInBackground(b, s, players)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			s.clientManager.Record(players[i%len(players)], time.Now())
+		}
+	})
+}
+
+// newTestServer returns a server writing to a loopback socket, with one registered player
+func newTestServer(t *testing.T, cfg Config) (*Server, *game.Player) {
+	t.Helper()
+
+	s := NewServerWithConfig(cfg)
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	s.conn = conn
+
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 40000}
+	player, _, err := s.clientManager.RegisterClient(addr, game.Profile{}, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, player
+}
+
+func TestPropertyUpdatesCoalesceForLossyClients(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ReliableMaxPending = 8
+	s, player := newTestServer(t, cfg)
+
+	health := func(value int32) []message.EntityProperties {
+		return []message.EntityProperties{{EntityID: 300, Values: []message.PropertyValue{{ID: 0, Value: value}}}}
+	}
+
+	// The client acknowledges nothing, so updates beyond half the channel wait and coalesce
+	for i := int32(0); i < 100; i++ {
+		s.sendProperties(player, health(i))
+	}
+	if pending := player.Reliable.Pending(); pending != cfg.ReliableMaxPending/2 {
+		t.Fatalf("%d messages pending, want %d", pending, cfg.ReliableMaxPending/2)
+	}
+
+	// The remaining half still carries messages that must arrive
+	for i := 0; i < cfg.ReliableMaxPending/2; i++ {
+		s.sendReliable(player, []byte{byte(command.CHAT)})
+	}
+	if _, exists := s.clientManager.GetPlayer(player.ID); !exists {
+		t.Fatal("player disconnected before the channel was full")
+	}
+
+	// One acknowledgement leaves the channel over the properties' share
+	player.Reliable.Ack(0)
+	s.flushProperties(player)
+	queued := player.Reliable.State().Pending[uint16(cfg.ReliableMaxPending)]
+	if queued != nil {
+		t.Fatalf("properties sent while the channel was over their share: %v", queued)
+	}
+	// Once there is room, only the latest queued value is sent
+	for seq := uint16(1); seq <= 4; seq++ {
+		player.Reliable.Ack(seq)
+	}
+	s.flushProperties(player)
+	sent := player.Reliable.State().Pending[uint16(cfg.ReliableMaxPending)]
+	update, _, err := s.serializer.Deserialize(sent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := update.(message.PropertyUpdate).Entities
+	if len(values) != 1 || len(values[0].Values) != 1 || values[0].Values[0].Value != int32(99) {
+		t.Errorf("flushed %v, want only health 99", values)
+	}
+	if rest := player.Properties.Take(); rest != nil {
+		t.Errorf("%v still queued after the flush", rest)
+	}
+
+	// A message that finds the channel full disconnects the player instead of being dropped
+	for player.Reliable.Pending() < cfg.ReliableMaxPending {
+		s.sendReliable(player, []byte{byte(command.CHAT)})
+	}
+	s.sendReliable(player, []byte{byte(command.CHAT)})
+	if _, exists := s.clientManager.GetPlayer(player.ID); exists {
+		t.Error("player with a full reliable channel is still connected")
+	}
+}
+
+// BenchmarkPingPong measures players' round-trip times and records their history, as the ping and
+// history routines do, while the shards move them
+func BenchmarkPingPong(b *testing.B) {
+	s, players := newBenchServer(b, runtime.GOMAXPROCS(0))
+	moveInBackground(b, s, players)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			player := players[i%len(players)]
+			now := time.Now()
+			if sequence, exists := s.clientManager.Ping(player.ID, now); exists {
+				s.clientManager.Pong(player.ID, sequence, now.Add(time.Millisecond))
+			}
+			if i%len(players) == 0 {
+				s.clientManager.RecordHistory(now)
+			}
+		}
+	})
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// noShard is the shard of work that belongs to no room, such as packets from unregistered senders
+const noShard = -1
+
+// shardOf maps a room to the shard that owns it
+func shardOf(roomID uint8, count int) int {
+	return int(roomID) % count
+}
+
+// Shard runs the work of the rooms it owns on a single goroutine, in the order it was posted.
+// A room's players and entities are only moved by their shard, so rooms on different shards
+// are simulated in parallel without waiting for each other.
+type Shard struct {
+	ID      int
+	queue   chan func()
+	dropped atomic.Uint64
+}
+
+// ShardStats reports the load of one shard
+type ShardStats struct {
+	ID      int    `json:"id"`
+	Queued  int    `json:"queued"`
+	Dropped uint64 `json:"dropped"`
+}
+
+// ShardPool is a fixed set of shards rooms are spread over by ID
+type ShardPool struct {
+	shards []*Shard
+	done   chan struct{}
+	stop   sync.Once
+	wg     sync.WaitGroup
+}
+
+// NewShardPool creates count shards that each queue up to queueSize jobs
+func NewShardPool(count, queueSize int) *ShardPool {
+	sp := &ShardPool{
+		shards: make([]*Shard, count),
+		done:   make(chan struct{}),
+	}
+	for i := range sp.shards {
+		sp.shards[i] = &Shard{ID: i, queue: make(chan func(), queueSize)}
+	}
+	return sp
+}
+
+// Count returns the number of shards
+func (sp *ShardPool) Count() int {
+	return len(sp.shards)
+}
+
+// Start runs every shard on its own goroutine until Stop
+func (sp *ShardPool) Start() {
+	for _, shard := range sp.shards {
+		sp.wg.Add(1)
+		go func(shard *Shard) {
+			defer sp.wg.Done()
+			for {
+				select {
+				case job := <-shard.queue:
+					job()
+				case <-sp.done:
+					return
+				}
+			}
+		}(shard)
+	}
+}
+
+// Stop ends the shard goroutines after their current job and waits for them. Queued jobs are dropped.
+// Later calls only wait.
+func (sp *ShardPool) Stop() {
+	sp.stop.Do(func() { close(sp.done) })
+	sp.wg.Wait()
+}
+
+// Post queues a job on a shard. A full queue drops the job, as the network would drop a packet,
+// rather than stalling the receive loop behind one busy shard.
+func (sp *ShardPool) Post(shard int, job func()) bool {
+	s := sp.shards[shard]
+	select {
+	case s.queue <- job:
+		return true
+	default:
+		s.dropped.Add(1)
+		return false
+	}
+}
+
+// Every posts tick to each shard every interval until Stop, so each shard runs its own simulation loop.
+// A shard that has not caught up misses the tick instead of queueing several.
+func (sp *ShardPool) Every(interval time.Duration, tick func(shard int, now time.Time)) {
+	pending := make([]atomic.Bool, len(sp.shards))
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				for i := range sp.shards {
+					if !pending[i].CompareAndSwap(false, true) {
+						continue
+					}
+					shard := i
+					if !sp.Post(shard, func() {
+						tick(shard, time.Now())
+						pending[shard].Store(false)
+					}) {
+						pending[shard].Store(false)
+					}
+				}
+			case <-sp.done:
+				return
+			}
+		}
+	}()
+}
+
+// Stats returns the queue length and dropped jobs of every shard
+func (sp *ShardPool) Stats() []ShardStats {
+	stats := make([]ShardStats, len(sp.shards))
+	for i, shard := range sp.shards {
+		stats[i] = ShardStats{ID: shard.ID, Queued: len(shard.queue), Dropped: shard.dropped.Load()}
+	}
+	return stats
+}
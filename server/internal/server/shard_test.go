@@ -0,0 +1,121 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"runtime"
+	"server/internal/command"
+	"server/internal/game"
+	"server/internal/message"
+	"sync"
+	"testing"
+)
+
+// benchRooms and benchRoomSize spread the benchmark players over enough rooms to keep every shard busy
+const (
+	benchRooms    = 32
+	benchRoomSize = 4
+)
+
+// newBenchServer returns a server writing to a loopback socket, without rate limits, with
+// benchRooms rooms of benchRoomSize players each
+func newBenchServer(b *testing.B, shards int) (*Server, []*game.Player) {
+	b.Helper()
+
+	cfg := DefaultConfig()
+	cfg.Shards = shards
+	cfg.ShardQueueSize = 4096
+	cfg.MaxPort = cfg.MinPort + benchRooms*benchRoomSize
+	unlimited := BucketConfig{Rate: 1e12, Burst: 1e12}
+	cfg.RateLimits.Global, cfg.RateLimits.PerAddress, cfg.RateLimits.PerPlayer = unlimited, unlimited, unlimited
+	cfg.RateLimits.PerCommand = nil
+
+	s := NewServerWithConfig(cfg)
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { conn.Close() })
+	s.conn = conn
+
+	var players []*game.Player
+	for r := 0; r < benchRooms; r++ {
+		var roomID uint8
+		for i := 0; i < benchRoomSize; i++ {
+			addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 40000 + len(players)}
+			player, _, err := s.clientManager.RegisterClient(addr, game.Profile{}, "", nil)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if i == 0 {
+				room, _, err := s.clientManager.CreateRoom(player.ID, fmt.Sprintf("bench %d", r), benchRoomSize)
+				if err != nil {
+					b.Fatal(err)
+				}
+				roomID = room.ID
+			} else if _, err := s.clientManager.JoinRoom(player.ID, roomID); err != nil {
+				b.Fatal(err)
+			}
+			players = append(players, player)
+		}
+	}
+
+	s.shards.Start()
+	b.Cleanup(s.shards.Stop)
+	return s, players
+}
+
+// shardCounts are the shard counts benchmarks compare: 1, 2, 4 and one per CPU
+func shardCounts() []int {
+	counts := []int{1, 2, 4}
+	if procs := runtime.GOMAXPROCS(0); procs > 4 {
+		counts = append(counts, procs)
+	}
+	return counts
+}
+
+// BenchmarkShardedPositionUpdates measures how position updates, each validated, applied and
+// broadcast to the room, scale with the number of shards
+func BenchmarkShardedPositionUpdates(b *testing.B) {
+	for _, count := range shardCounts() {
+		b.Run(fmt.Sprintf("shards=%d", count), func(b *testing.B) {
+			s, players := newBenchServer(b, count)
+
+			packets := make([][]byte, len(players))
+			for i, player := range players {
+				packet, err := s.serializer.SerializePositionDataRTT(message.PositionDataRTT{
+					CommandID: command.POSITION_RTT,
+					UserID:    player.ID,
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+				packets[i] = packet
+			}
+
+			var wg sync.WaitGroup
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				player, packet := players[i%len(players)], packets[i%len(players)]
+				shard, _ := s.clientManager.ShardOf(player.Address)
+
+				// Unlike dispatch, wait for room in the queue, so every update is measured
+				wg.Add(1)
+				for !s.shards.Post(shard, func() {
+					s.handlePacket(shard, player.Address, packet)
+					wg.Done()
+				}) {
+					runtime.Gosched()
+				}
+			}
+			wg.Wait()
+		})
+	}
+}
+
+func TestShardPoolStopTwice(t *testing.T) {
+	sp := NewShardPool(2, 1)
+	sp.Start()
+	sp.Stop()
+	sp.Stop()
+}
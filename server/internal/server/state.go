@@ -0,0 +1,100 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"server/internal/game"
+	"server/internal/message"
+	"time"
+)
+
+// ServerState is the runtime state written on shutdown, so a restarted server carries on where it stopped.
+// Clients keep their sockets across a quick restart, so their packets reach their restored players by
+// address, with the same user IDs, ports and reliable sequence numbers. Clients that come back from
+// another address register again with their PortRequest secret and get their saved progress back.
+//
+// Secure sessions, rate limits, matchmaking queues, snapshot baselines and position history are not
+// saved: clients using SecureTransport must redo the key exchange, and queued players must queue again.
+type ServerState struct {
+	SavedAt      time.Time        `json:"savedAt"`
+	Players      []SavedPlayer    `json:"players"`
+	NextRoomID   uint8            `json:"nextRoomId"`
+	Rooms        []game.RoomState `json:"rooms"`
+	NextEntityID game.EntityID    `json:"nextEntityId"`
+	Entities     []SavedEntity    `json:"entities"`
+	Chat         ChatState        `json:"chat"`
+}
+
+// SavedPlayer is a connected player in a ServerState
+type SavedPlayer struct {
+	ID            uint8                   `json:"id"`
+	Identity      string                  `json:"identity,omitempty"`
+	Profile       game.Profile            `json:"profile"`
+	Address       string                  `json:"address"`
+	ListenPort    int                     `json:"listenPort"`
+	Position      message.PositionDataRTT `json:"position"`
+	HasPosition   bool                    `json:"hasPosition"`
+	RTT           time.Duration           `json:"rtt"`
+	LastInput     uint32                  `json:"lastInput"` // last authoritative input frame applied
+	KnownEntities []game.EntityID         `json:"knownEntities,omitempty"`
+	Reliable      game.ReliableState      `json:"reliable"`
+}
+
+// SavedEntity is a non-player entity in a ServerState. Properties are set by name on restore,
+// so values of properties its kind no longer declares are rejected.
+type SavedEntity struct {
+	game.Entity
+	Properties map[string]json.RawMessage `json:"properties,omitempty"`
+}
+
+// LoadState reads a saved state. A missing file returns nil and no error.
+func LoadState(path string) (*ServerState, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var state ServerState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return &state, nil
+}
+
+// Save writes the state atomically via a temporary file. It is readable by the owner only,
+// as it holds the addresses and identities of every player.
+func (st *ServerState) Save(path string) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// savedEntity captures an entity with its property values
+func savedEntity(entity game.Entity) (SavedEntity, error) {
+	saved := SavedEntity{Entity: entity}
+	if entity.Properties == nil {
+		return saved, nil
+	}
+
+	data, err := json.Marshal(entity.Properties)
+	if err != nil {
+		return SavedEntity{}, err
+	}
+	if err := json.Unmarshal(data, &saved.Properties); err != nil {
+		return SavedEntity{}, err
+	}
+	saved.Entity.Properties = nil
+	return saved, nil
+}
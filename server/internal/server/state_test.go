@@ -0,0 +1,109 @@
+package server
+
+import (
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"server/internal/command"
+	"server/internal/game"
+	"server/internal/message"
+	"sort"
+	"testing"
+	"time"
+)
+
+// stateJSON returns a state with its players and their known entities in a fixed order, as JSON
+func stateJSON(t *testing.T, state ServerState) string {
+	t.Helper()
+
+	sort.Slice(state.Players, func(i, j int) bool { return state.Players[i].ID < state.Players[j].ID })
+	for _, player := range state.Players {
+		sort.Slice(player.KnownEntities, func(i, j int) bool { return player.KnownEntities[i] < player.KnownEntities[j] })
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestStateRestoreRoundTrip(t *testing.T) {
+	cfg := DefaultConfig()
+	before := NewServerWithConfig(cfg)
+	cm := before.clientManager
+
+	var players []*game.Player
+	for i := 0; i < 3; i++ {
+		addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 40000 + i}
+		player, _, err := cm.RegisterClient(addr, game.Profile{}, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		players = append(players, player)
+	}
+	players[0].Identity = "first"
+	players[0].UpdatePosition(message.PositionDataRTT{UserID: players[0].ID, X: 1, Y: 2, Z: 3, RotY: 90})
+	players[0].Latency.SetRTT(40 * time.Millisecond)
+	players[0].Reliable.Send([]byte{byte(command.CHAT)}, time.Now())
+	players[2].Reliable.Receive(3, []byte{byte(command.CHAT)})
+
+	arena, _, err := cm.CreateRoom(players[0].ID, "arena", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cm.JoinRoom(players[1].ID, arena.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	npc, err := cm.SpawnEntity(game.Entity{Kind: game.EntityNPC, RoomID: arena.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := npc.Properties.Set("health", int32(42)); err != nil {
+		t.Fatal(err)
+	}
+	players[0].KnownEntities[npc.ID] = struct{}{}
+
+	before.chat.Record(message.ChatMessage{CommandID: command.CHAT, UserID: players[0].ID, Text: "hello"}, game.LobbyRoomID)
+	before.chat.Record(message.ChatMessage{CommandID: command.CHAT, UserID: players[1].ID, Channel: message.CHAT_ROOM, Text: "arena"}, arena.ID)
+
+	saved, err := cm.State(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved.Chat = before.chat.State()
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := saved.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after := NewServerWithConfig(cfg)
+	if err := after.clientManager.Restore(loaded); err != nil {
+		t.Fatal(err)
+	}
+	after.chat.Restore(loaded.Chat)
+
+	restored, err := after.clientManager.State(saved.SavedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored.Chat = after.chat.State()
+	if got, want := stateJSON(t, restored), stateJSON(t, saved); got != want {
+		t.Errorf("restored state differs\n got: %s\nwant: %s", got, want)
+	}
+
+	// The restored players are reachable the way packets find them
+	for _, player := range players {
+		got, exists := after.clientManager.GetPlayerByAddress(player.Address)
+		if !exists || got.ID != player.ID {
+			t.Errorf("player %d not found by address %s", player.ID, player.Address)
+		}
+	}
+	if room, _ := after.clientManager.rooms.RoomOf(players[1].ID); room == nil || room.ID != arena.ID {
+		t.Errorf("player %d not restored into the arena", players[1].ID)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"log"
 	"os"
 	"os/signal"
@@ -9,16 +10,34 @@ import (
 )
 
 func main() {
-	// Default server address
-	address := ":8080"
+	cfg := server.DefaultConfig()
+	flag.DurationVar(&cfg.SnapshotInterval, "snapshot-interval", cfg.SnapshotInterval,
+		"send delta snapshots this often instead of broadcasting every position update; 0 disables them")
+	flag.IntVar(&cfg.SnapshotBudget, "snapshot-budget", cfg.SnapshotBudget,
+		"cap the snapshot bytes per second sent to each client, e.g. 16384; requires -snapshot-interval, 0 is unlimited")
+	flag.DurationVar(&cfg.FlushInterval, "flush-interval", cfg.FlushInterval,
+		"coalesce outgoing messages into batched datagrams sent this often; 0 sends each message right away")
+	compact := flag.Bool("compact-positions", false,
+		"broadcast quantized 10-byte positions instead of 18-byte ones")
+	flag.Parse()
+
+	if *compact {
+		cfg.CompactPositions = server.DefaultCompactPositions()
+	}
 
-	// Override with command line argument if provided
-	if len(os.Args) > 1 {
-		address = os.Args[1]
+	// Default server address, overridden by the first argument
+	cfg.Address = ":8080"
+	if flag.NArg() > 0 {
+		cfg.Address = flag.Arg(0)
 	}
 
 	// Create server with port range 22222-22321
-	gameServer := server.NewServer(address, 22222, 22321)
+	cfg.MinPort = 22222
+	cfg.MaxPort = 22321
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	gameServer := server.NewServerWithConfig(cfg)
 
 	// Setup graceful shutdown
 	sigChan := make(chan os.Signal, 1)